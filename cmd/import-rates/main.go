@@ -0,0 +1,81 @@
+// Command import-rates loads currency exchange rates from a CSV file into the database.
+// Every record is "currency,rate" where rate is the amount of the currency worth one USD:
+//
+//	currency,rate
+//	USD,1
+//	EUR,0.92
+//
+// Usage: import-rates -file rates.csv (reads stdin when -file is omitted)
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"rest-api-marketplace/internal/config"
+	"rest-api-marketplace/internal/repository"
+	"rest-api-marketplace/internal/service"
+	postgres "rest-api-marketplace/pkg/client/postgresdb"
+)
+
+func main() {
+	path := flag.String("file", "", "CSV file with exchange rates, stdin if empty")
+	flag.Parse()
+
+	log := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	if err := run(log, *path); err != nil {
+		log.Error("failed to import exchange rates", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+}
+
+// run connects to the database and imports rates from the file or stdin
+func run(log *slog.Logger, path string) error {
+	if err := godotenv.Load(); err != nil {
+		log.Warn("no .env file loaded, using environment", slog.String("error", err.Error()))
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	var input io.Reader = os.Stdin
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("open rates file: %w", err)
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		input = file
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	db, err := postgres.NewClient(ctx, cfg.DB, log)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer postgres.CloseDatabase(db, log)
+
+	rates := service.NewExchangeRateService(repository.NewExchangeRatesRepo(db), log)
+
+	imported, err := rates.Import(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	log.Info("exchange rates imported", slog.Int("count", imported))
+	return nil
+}
@@ -0,0 +1,96 @@
+// Command set-role changes the role of a user directly in the database, it is meant to create the first admin:
+//
+//	set-role -login john -role admin
+//
+// Roles are user, moderator and admin. The change is recorded in the audit log without an actor.
+// Access tokens of the user are revoked like with PUT /users/{id}/role, so the old role stops
+// working at once and the new one takes effect on the next token refresh
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"rest-api-marketplace/internal/config"
+	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/internal/repository"
+	"rest-api-marketplace/pkg/auth"
+	postgres "rest-api-marketplace/pkg/client/postgresdb"
+)
+
+func main() {
+	login := flag.String("login", "", "login of the user")
+	role := flag.String("role", string(entity.RoleAdmin), "new role: user, moderator or admin")
+	flag.Parse()
+
+	log := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	if err := run(log, *login, entity.Role(*role)); err != nil {
+		log.Error("failed to set role", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+}
+
+// run connects to the database, changes the role of the user and records the change
+func run(log *slog.Logger, login string, role entity.Role) error {
+	if login == "" {
+		return errors.New("-login is required")
+	}
+	if !role.Valid() {
+		return fmt.Errorf("unknown role %q", role)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Warn("no .env file loaded, using environment", slog.String("error", err.Error()))
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	db, err := postgres.NewClient(ctx, cfg.DB, log)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer postgres.CloseDatabase(db, log)
+
+	repos := repository.NewRepositories(db)
+
+	user, err := repos.Users.GetByLogin(ctx, login)
+	if err != nil {
+		return err
+	}
+
+	if err := repos.Users.UpdateRole(ctx, user.ID, role); err != nil {
+		return err
+	}
+
+	if err := auth.RevokeAllTokens(ctx, repos.RevokedTokens, user.ID, cfg.Auth.AccessTokenTTL); err != nil {
+		return fmt.Errorf("revoke access tokens: %w", err)
+	}
+
+	err = repos.AuditLog.Create(ctx, entity.AuditEntry{
+		ActorRole:  entity.RoleAdmin,
+		Action:     entity.AuditUserRoleChange,
+		TargetType: entity.AuditTargetUser,
+		TargetID:   user.ID,
+		Details:    map[string]string{"login": user.Login, "from": string(user.Role), "to": string(role), "source": "set-role"},
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info("role changed", slog.String("login", user.Login), slog.String("from", string(user.Role)), slog.String("to", string(role)))
+	return nil
+}
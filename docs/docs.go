@@ -15,6 +15,26 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/.well-known/jwks.json": {
+            "get": {
+                "description": "Public keys access tokens are signed with, the token kid header selects the key. Rotated out keys stay here while tokens signed with them may still be valid",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "JSON Web Key Set",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.jwksResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/api/v1/ads": {
             "get": {
                 "description": "Retrieve a paginated list of advertisements",
@@ -29,10 +49,16 @@ const docTemplate = `{
                     {
                         "type": "integer",
                         "default": 1,
-                        "description": "Page number",
+                        "description": "Page number, ignored when cursor is set",
                         "name": "page",
                         "in": "query"
                     },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from next_cursor of the previous page, sorting must stay the same",
+                        "name": "cursor",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
                         "default": 10,
@@ -41,22 +67,27 @@ const docTemplate = `{
                         "in": "query"
                     },
                     {
-                        "type": "number",
-                        "format": "float64",
-                        "description": "Minimum price",
+                        "type": "string",
+                        "default": "USD",
+                        "description": "Currency to convert prices to for filtering, sorting and converted_price",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Minimum price in the requested currency as a decimal, e.g. 10.50",
                         "name": "min_price",
                         "in": "query"
                     },
                     {
-                        "type": "number",
-                        "format": "float64",
-                        "description": "Maximum price",
+                        "type": "string",
+                        "description": "Maximum price in the requested currency as a decimal, e.g. 99.99",
                         "name": "max_price",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Sort by field (e.g., price, created_at)",
+                        "description": "Sort by field (price, date or relevance, relevance requires q)",
                         "name": "sort_by",
                         "in": "query"
                     },
@@ -65,20 +96,58 @@ const docTemplate = `{
                         "description": "Sort direction (asc or desc)",
                         "name": "sort_dir",
                         "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "Category ID, ads from its subcategories are included",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Full-text search over title and description",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "published",
+                        "description": "Ad status, anything but published requires mine=true",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "List only ads of the current user in any status",
+                        "name": "mine",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Count all matching ads, pass false to skip the total for faster responses",
+                        "name": "count",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/entity.Ad"
-                            }
+                            "$ref": "#/definitions/v1.adsPageResponse"
                         }
                     },
                     "400": {
-                        "description": "Incorrect price parameter",
+                        "description": "Incorrect cursor, currency, price, category, search, sorting or status parameter",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {}
                     },
                     "500": {
@@ -88,7 +157,7 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "Create a new advertisement",
+                "description": "Create a new advertisement, published unless status is \"draft\"",
                 "consumes": [
                     "application/json"
                 ],
@@ -121,17 +190,21 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/entity.Ad"
+                            "$ref": "#/definitions/v1.adResponse"
                         }
                     },
                     "400": {
                         "description": "Invalid request body or input",
                         "schema": {}
                     },
-                    "409": {
+                    "401": {
                         "description": "Unauthorized",
                         "schema": {}
                     },
+                    "403": {
+                        "description": "Email is not verified",
+                        "schema": {}
+                    },
                     "500": {
                         "description": "Failed to create ad",
                         "schema": {}
@@ -157,17 +230,24 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "default": "USD",
+                        "description": "Currency to convert the price to for converted_price",
+                        "name": "currency",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/entity.Ad"
+                            "$ref": "#/definitions/v1.adResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid ad ID",
+                        "description": "Invalid ad ID or currency",
                         "schema": {}
                     },
                     "404": {
@@ -181,7 +261,7 @@ const docTemplate = `{
                 }
             },
             "put": {
-                "description": "Update an existing advertisement",
+                "description": "Update an existing advertisement, moderators can update any ad",
                 "consumes": [
                     "application/json"
                 ],
@@ -222,7 +302,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/entity.Ad"
+                            "$ref": "#/definitions/v1.adResponse"
                         }
                     },
                     "400": {
@@ -248,7 +328,7 @@ const docTemplate = `{
                 }
             },
             "delete": {
-                "description": "Delete an advertisement by its ID",
+                "description": "Delete an advertisement by its ID, moderators can delete any ad. It can be restored for a limited time",
                 "produces": [
                     "application/json"
                 ],
@@ -300,55 +380,88 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/users/auth/refresh": {
+        "/api/v1/ads/{id}/images": {
             "post": {
-                "description": "Refresh JWT access and refresh tokens",
+                "description": "Upload a JPEG, PNG, GIF or WebP image to the end of an advertisement gallery",
                 "consumes": [
-                    "application/json"
+                    "multipart/form-data"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "ads"
                 ],
-                "summary": "Refresh Tokens",
+                "summary": "Upload Ad Image",
                 "parameters": [
                     {
-                        "description": "Refresh token",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/v1.refreshInput"
-                        }
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "Ad ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Image file",
+                        "name": "image",
+                        "in": "formData",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/v1.tokenResponse"
+                            "$ref": "#/definitions/v1.adImageResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid request body",
+                        "description": "Invalid ad ID or missing image",
                         "schema": {}
                     },
                     "401": {
-                        "description": "Invalid or expired refresh token",
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "Ad not found",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Gallery was changed concurrently",
+                        "schema": {}
+                    },
+                    "413": {
+                        "description": "Image is too large",
+                        "schema": {}
+                    },
+                    "415": {
+                        "description": "Unsupported image type",
                         "schema": {}
                     },
                     "500": {
-                        "description": "Internal server error",
+                        "description": "Failed to upload image",
                         "schema": {}
                     }
                 }
             }
         },
-        "/api/v1/users/sign-in": {
-            "post": {
-                "description": "User sign-in",
+        "/api/v1/ads/{id}/images/order": {
+            "put": {
+                "description": "Set the order of all images in an advertisement gallery",
                 "consumes": [
                     "application/json"
                 ],
@@ -356,17 +469,32 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "ads"
                 ],
-                "summary": "User Sign In",
+                "summary": "Reorder Ad Images",
                 "parameters": [
                     {
-                        "description": "User credentials for login",
-                        "name": "user",
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "Ad ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Image IDs in the new order",
+                        "name": "order",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/v1.userInput"
+                            "$ref": "#/definitions/v1.reorderImagesInput"
                         }
                     }
                 ],
@@ -374,119 +502,2055 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/v1.tokenResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/v1.adImageResponse"
+                            }
                         }
                     },
                     "400": {
-                        "description": "Invalid request body",
+                        "description": "Invalid request body or input",
                         "schema": {}
                     },
                     "401": {
-                        "description": "Invalid login or password",
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "Ad or image not found",
                         "schema": {}
                     },
                     "500": {
-                        "description": "Failed to sign in",
+                        "description": "Failed to reorder images",
                         "schema": {}
                     }
                 }
             }
         },
-        "/api/v1/users/sign-up": {
-            "post": {
-                "description": "Register a new user",
-                "consumes": [
-                    "application/json"
-                ],
+        "/api/v1/ads/{id}/images/{image_id}": {
+            "delete": {
+                "description": "Remove an image from an advertisement gallery",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "ads"
                 ],
-                "summary": "User Sign Up",
+                "summary": "Delete Ad Image",
                 "parameters": [
                     {
-                        "description": "User credentials for registration",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/v1.userInput"
-                        }
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "Ad ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "Image ID",
+                        "name": "image_id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
-                        "schema": {
-                            "$ref": "#/definitions/entity.User"
-                        }
+                    "204": {
+                        "description": "No content"
                     },
                     "400": {
-                        "description": "Invalid request body",
+                        "description": "Invalid ad or image ID",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "Ad or image not found",
                         "schema": {}
                     },
                     "409": {
-                        "description": "User with this login already exists",
+                        "description": "Gallery was changed concurrently",
                         "schema": {}
                     },
                     "500": {
-                        "description": "Failed to create user",
+                        "description": "Failed to delete image",
                         "schema": {}
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "entity.Ad": {
-            "type": "object",
-            "properties": {
-                "created_at": {
-                    "type": "string"
-                },
-                "description": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "integer"
-                },
-                "image_url": {
-                    "type": "string"
-                },
-                "price": {
-                    "type": "number"
-                },
-                "title": {
-                    "type": "string"
-                },
-                "user_id": {
-                    "type": "integer"
-                }
-            }
-        },
-        "entity.User": {
-            "type": "object",
-            "properties": {
-                "created_at": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "integer"
-                },
-                "login": {
-                    "type": "string"
-                }
-            }
         },
-        "v1.createAdInput": {
-            "type": "object",
-            "required": [
-                "description",
-                "title"
-            ],
+        "/api/v1/ads/{id}/images/{image_id}/cover": {
+            "post": {
+                "description": "Make an image the cover of an advertisement gallery",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ads"
+                ],
+                "summary": "Set Ad Cover Image",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "Ad ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "Image ID",
+                        "name": "image_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/v1.adImageResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ad or image ID",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "Ad or image not found",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Gallery was changed concurrently",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to set cover image",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/ads/{id}/restore": {
+            "post": {
+                "description": "Restore a deleted advertisement during the restore window, moderators can restore any ad",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ads"
+                ],
+                "summary": "Restore Ad",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "Ad ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.adResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ad ID",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "Deleted ad not found",
+                        "schema": {}
+                    },
+                    "410": {
+                        "description": "Restore window has expired",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to restore ad",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/ads/{id}/transition": {
+            "post": {
+                "description": "Move an advertisement to another lifecycle status. Moderators can hide any ad and move a hidden ad back to draft",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ads"
+                ],
+                "summary": "Transition Ad",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "Ad ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Target status",
+                        "name": "transition",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.transitionAdInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.adResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or input",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "Ad not found",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Transition is not allowed",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to change ad status",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/categories": {
+            "get": {
+                "description": "Retrieve the full category tree",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "List Categories",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/v1.categoryNodeResponse"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get categories",
+                        "schema": {}
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a new category, optionally nested under a parent category. Admins only",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "Create Category",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Category details",
+                        "name": "category",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.categoryInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/v1.categoryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or input, or parent category not found",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to create category",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/categories/{id}": {
+            "get": {
+                "description": "Retrieve a single category by its ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "Get Category by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.categoryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid category ID",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "Category not found",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to get category",
+                        "schema": {}
+                    }
+                }
+            },
+            "put": {
+                "description": "Rename a category or move it under another parent. Admins only",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "Update Category",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Category details",
+                        "name": "category",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.categoryInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.categoryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or input, parent category not found or nesting cycle",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "Category not found",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to update category",
+                        "schema": {}
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a category without subcategories, its ads become uncategorized. Admins only",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "Delete Category",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No content"
+                    },
+                    "400": {
+                        "description": "Invalid category ID",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "Category not found",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Category has subcategories",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to delete category",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/exchange-rates": {
+            "get": {
+                "description": "Retrieve exchange rates used to convert ad prices, each rate is the amount of the currency worth one USD",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "exchange-rates"
+                ],
+                "summary": "List Exchange Rates",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/v1.exchangeRateResponse"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get exchange rates",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/media/{path}": {
+            "get": {
+                "description": "Download an uploaded file by its path. Images of unpublished ads are only served to their owner",
+                "produces": [
+                    "image/jpeg",
+                    "image/png",
+                    "image/gif",
+                    "image/webp"
+                ],
+                "tags": [
+                    "media"
+                ],
+                "summary": "Get Media",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "File path",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to get file",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/auth/refresh": {
+            "post": {
+                "description": "Exchange a refresh token for a new token pair, the old refresh token stops working. Reusing it revokes the session",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Refresh Tokens",
+                "parameters": [
+                    {
+                        "description": "Refresh token",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.refreshInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.tokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Invalid, expired or reused refresh token",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/me/2fa/recovery-codes": {
+            "post": {
+                "description": "Replace all recovery codes of the current user with new ones, confirmed with a code from the authenticator app or a recovery code",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Regenerate Recovery Codes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Code from the authenticator app or a recovery code",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.twoFactorCodeInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.recoveryCodesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or code",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "Two-factor authentication is not enabled",
+                        "schema": {}
+                    },
+                    "429": {
+                        "description": "Too many wrong codes, the Retry-After header tells how many seconds to wait",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to regenerate recovery codes",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/me/2fa/totp": {
+            "post": {
+                "description": "Generate an authenticator app secret for the current user. Two-factor authentication is enabled once the secret is confirmed with a code, enrolling again before that replaces the secret",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Enroll TOTP",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.totpEnrollmentResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Two-factor authentication is already enabled",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to enroll",
+                        "schema": {}
+                    }
+                }
+            },
+            "delete": {
+                "description": "Turn two-factor authentication of the current user off with a code from the authenticator app or a recovery code",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Disable TOTP",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Code from the authenticator app or a recovery code",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.twoFactorCodeInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No content"
+                    },
+                    "400": {
+                        "description": "Invalid request body or code",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "Two-factor authentication is not enabled",
+                        "schema": {}
+                    },
+                    "429": {
+                        "description": "Too many wrong codes, the Retry-After header tells how many seconds to wait",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to disable",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/me/2fa/totp/confirm": {
+            "post": {
+                "description": "Enable two-factor authentication of the current user with a code from the authenticator app. The response holds recovery codes, they are only shown once",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Confirm TOTP",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Code from the authenticator app",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.twoFactorCodeInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.recoveryCodesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or code",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "No enrollment to confirm",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Two-factor authentication is already enabled",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to confirm",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/me/email": {
+            "post": {
+                "description": "Change the email of the current user and send a verification link to it. Sending the current unverified email again resends the link",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Set User Email",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "New email",
+                        "name": "email",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.emailInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    },
+                    "400": {
+                        "description": "Invalid request body or email",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Email is already used",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to set email",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/me/identities": {
+            "get": {
+                "description": "List identity provider accounts linked to the current user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "List Linked Identities",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/v1.identityResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to get identities",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/me/identities/{id}": {
+            "delete": {
+                "description": "Unlink an identity provider account from the current user. Users without a password can't unlink their last identity",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Unlink Identity",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "Identity ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No content"
+                    },
+                    "400": {
+                        "description": "Invalid identity ID",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "Identity not found",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "The identity is the last way to sign in",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to unlink identity",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/me/identities/{provider}": {
+            "post": {
+                "description": "Start linking an identity provider account to the current user. The browser has to open the returned URL, the callback returns the linked identity. The response sets a short-lived cookie the callback checks, so it must be kept by the browser",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Link Identity",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Identity provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.oauthStartResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "Unknown identity provider",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to start linking",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/me/lockouts": {
+            "get": {
+                "description": "List the latest lockouts of the current user account after too many failed sign-in attempts",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "List User Lockouts",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/v1.lockoutResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to get lockouts",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/me/password": {
+            "put": {
+                "description": "Change the password of the current user. The new password must follow the password policy. All sessions of the user are ended, including the current one, so the user has to sign in again",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Change Password",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Current and new password",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.changePasswordInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No content"
+                    },
+                    "400": {
+                        "description": "Invalid request body or new password rejected by the password policy",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "403": {
+                        "description": "Current password is wrong",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to change password",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/me/sessions": {
+            "get": {
+                "description": "List devices the current user is signed in on",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "List User Sessions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/v1.sessionResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to get sessions",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/me/sessions/{id}": {
+            "delete": {
+                "description": "Sign the current user out on another device, its refresh token stops working",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Revoke User Session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "Session ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No content"
+                    },
+                    "400": {
+                        "description": "Invalid session ID",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "Session not found",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to revoke session",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/oauth/providers": {
+            "get": {
+                "description": "List identity providers users can sign in with",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "List Identity Providers",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.oauthProvidersResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/oauth/{provider}/callback": {
+            "get": {
+                "description": "The identity provider redirects the browser here after the user signed in. The user the provider account is linked to is signed in, a user with the same email verified on both sides gets the account linked, otherwise a new user without a password is registered. Users with two-factor authentication get a challenge token as with a password sign-in. When the flow was started to link an account, the linked identity is returned instead",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "External Sign In Callback",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Identity provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Authorization code",
+                        "name": "code",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "State from the start of the flow",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Error reported by the provider",
+                        "name": "error",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.signInResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "Provider account linked to the user who started the flow",
+                        "schema": {
+                            "$ref": "#/definitions/v1.identityResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid or expired state, or the sign-in was denied at the provider",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "The provider rejected the code or returned an invalid ID token",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "Unknown identity provider",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Email or provider account belongs to another user, or a provider account is already linked",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to sign in",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/oauth/{provider}/start": {
+            "get": {
+                "description": "Redirect the browser to the identity provider to sign in with an OpenID Connect authorization code flow with PKCE. A short-lived cookie ties the callback to this browser",
+                "tags": [
+                    "users"
+                ],
+                "summary": "Start External Sign In",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Identity provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Redirect to the identity provider"
+                    },
+                    "404": {
+                        "description": "Unknown identity provider",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to start sign-in",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/password/forgot": {
+            "post": {
+                "description": "Send a single-use password reset link to the email if it is a verified email of a user. The response is the same for unknown emails",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Forgot Password",
+                "parameters": [
+                    {
+                        "description": "Email of the account",
+                        "name": "email",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.forgotPasswordInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    },
+                    "400": {
+                        "description": "Invalid request body or email",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to send password reset link",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/password/reset": {
+            "post": {
+                "description": "Set a new password with a token from a password reset link. The password must follow the password policy, the token works once and all sessions of the user are ended",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Reset Password",
+                "parameters": [
+                    {
+                        "description": "Reset token and new password",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.resetPasswordInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No content"
+                    },
+                    "400": {
+                        "description": "Invalid request body or token, or password rejected by the password policy",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to reset password",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/sign-in": {
+            "post": {
+                "description": "User sign-in. Users with two-factor authentication get a challenge token instead of tokens, the sign-in is finished with POST /users/sign-in/2fa. Failed attempts slow down further ones for the login and the client IP and eventually lock them out for a while",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "User Sign In",
+                "parameters": [
+                    {
+                        "description": "User credentials for login",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.userInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.signInResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Invalid login or password",
+                        "schema": {}
+                    },
+                    "429": {
+                        "description": "Too many failed attempts, the Retry-After header tells how many seconds to wait",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to sign in",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/sign-in/2fa": {
+            "post": {
+                "description": "Finish the sign-in of a user with two-factor authentication with a code from the authenticator app or a recovery code. The challenge token works once and stops working after 5 wrong codes. Wrong codes count as failed sign-ins of the login and are throttled the same way",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Two-Factor Sign In",
+                "parameters": [
+                    {
+                        "description": "Challenge token from sign-in and code",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.signInTwoFactorInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.tokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Invalid code or invalid, expired or used challenge",
+                        "schema": {}
+                    },
+                    "429": {
+                        "description": "Too many wrong codes, the Retry-After header tells how many seconds to wait",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to sign in",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/sign-out": {
+            "post": {
+                "description": "Sign out on the current device, the access token and the refresh token of its session stop working",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "User Sign Out",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to sign out",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/sign-out-all": {
+            "post": {
+                "description": "Sign out on all devices, every access and refresh token issued to the user so far stops working",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "User Sign Out Everywhere",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to sign out",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/sign-up": {
+            "post": {
+                "description": "Register a new user, a verification link is sent to the email. The password must follow the password policy, the error tells which rule it breaks. The response is the same when the email already belongs to a user, the owner of the email is told about the attempt by email instead",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "User Sign Up",
+                "parameters": [
+                    {
+                        "description": "User credentials for registration",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.signUpInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    },
+                    "400": {
+                        "description": "Invalid request body or password rejected by the password policy",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "User with this login already exists",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to create user",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/verify-email": {
+            "post": {
+                "description": "Confirm the email of a user with the token from the verification email",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Verify Email",
+                "parameters": [
+                    {
+                        "description": "Verification token",
+                        "name": "token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.verifyEmailInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No content"
+                    },
+                    "400": {
+                        "description": "Invalid, expired or outdated token",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to verify email",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}/role": {
+            "put": {
+                "description": "Make a user a moderator or an admin or take the role away, admins only. The change is recorded in the audit log and takes effect on the next token refresh of the user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Set User Role",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003ctoken\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New role",
+                        "name": "role",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.roleInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.userResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or user ID",
+                        "schema": {}
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {}
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Failed to set role",
+                        "schema": {}
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "money.Money": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "1234.50"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "USD"
+                }
+            }
+        },
+        "v1.adImageResponse": {
+            "type": "object",
+            "properties": {
+                "ad_id": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "content_type": {
+                    "type": "string",
+                    "example": "image/jpeg"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 15
+                },
+                "is_cover": {
+                    "type": "boolean"
+                },
+                "position": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 204800
+                },
+                "url": {
+                    "type": "string",
+                    "example": "/api/v1/media/ads/42/1f2e3d.jpg"
+                },
+                "variants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.adImageVariantResponse"
+                    }
+                }
+            }
+        },
+        "v1.adImageVariantResponse": {
+            "type": "object",
+            "properties": {
+                "height": {
+                    "type": "integer",
+                    "example": 300
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 400
+                },
+                "url": {
+                    "type": "string",
+                    "example": "/api/v1/media/ads/42/1f2e3d_400.jpg"
+                },
+                "width": {
+                    "type": "integer",
+                    "example": 400
+                }
+            }
+        },
+        "v1.adResponse": {
+            "type": "object",
+            "properties": {
+                "author_login": {
+                    "type": "string",
+                    "example": "john"
+                },
+                "category_id": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "converted_price": {
+                    "$ref": "#/definitions/money.Money"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Aluminium frame, 21 speed"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "image_url": {
+                    "type": "string"
+                },
+                "images": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.adImageResponse"
+                    }
+                },
+                "is_owner": {
+                    "type": "boolean"
+                },
+                "price": {
+                    "$ref": "#/definitions/money.Money"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "draft",
+                        "published",
+                        "reserved",
+                        "sold",
+                        "archived",
+                        "hidden"
+                    ],
+                    "example": "published"
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Road bike"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 7
+                }
+            }
+        },
+        "v1.adsPageResponse": {
+            "type": "object",
+            "properties": {
+                "has_next": {
+                    "type": "boolean"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.adResponse"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "links": {
+                    "$ref": "#/definitions/v1.pageLinks"
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "v1.categoryInput": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "minLength": 1
+                },
+                "parent_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "v1.categoryNodeResponse": {
+            "type": "object",
+            "properties": {
+                "children": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.categoryNodeResponse"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Bicycles"
+                },
+                "parent_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "v1.categoryResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Bicycles"
+                },
+                "parent_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "v1.changePasswordInput": {
+            "type": "object",
+            "required": [
+                "new_password",
+                "old_password"
+            ],
+            "properties": {
+                "new_password": {
+                    "type": "string",
+                    "maxLength": 128
+                },
+                "old_password": {
+                    "type": "string",
+                    "maxLength": 128
+                }
+            }
+        },
+        "v1.createAdInput": {
+            "type": "object",
+            "required": [
+                "description",
+                "price",
+                "title"
+            ],
             "properties": {
+                "category_id": {
+                    "type": "integer"
+                },
                 "description": {
                     "type": "string",
                     "maxLength": 1000
@@ -495,8 +2559,14 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "price": {
-                    "type": "number",
-                    "minimum": 0
+                    "$ref": "#/definitions/money.Money"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "draft",
+                        "published"
+                    ]
                 },
                 "title": {
                     "type": "string",
@@ -505,6 +2575,187 @@ const docTemplate = `{
                 }
             }
         },
+        "v1.emailInput": {
+            "type": "object",
+            "required": [
+                "email"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "maxLength": 254
+                }
+            }
+        },
+        "v1.exchangeRateResponse": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string",
+                    "example": "EUR"
+                },
+                "rate": {
+                    "type": "string",
+                    "example": "0.92"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.forgotPasswordInput": {
+            "type": "object",
+            "required": [
+                "email"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "maxLength": 254
+                }
+            }
+        },
+        "v1.identityResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "jane@example.com"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 4
+                },
+                "provider": {
+                    "type": "string",
+                    "example": "google"
+                },
+                "subject": {
+                    "type": "string",
+                    "example": "110248495921238986420"
+                }
+            }
+        },
+        "v1.jwkResponse": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string",
+                    "example": "EdDSA"
+                },
+                "crv": {
+                    "type": "string",
+                    "example": "Ed25519"
+                },
+                "e": {
+                    "type": "string",
+                    "example": "AQAB"
+                },
+                "kid": {
+                    "type": "string",
+                    "example": "2026-10"
+                },
+                "kty": {
+                    "type": "string",
+                    "example": "OKP"
+                },
+                "n": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string",
+                    "example": "sig"
+                },
+                "x": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.jwksResponse": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.jwkResponse"
+                    }
+                }
+            }
+        },
+        "v1.lockoutResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "failures": {
+                    "type": "integer",
+                    "example": 10
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "ip": {
+                    "type": "string",
+                    "example": "203.0.113.10"
+                },
+                "locked_until": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.oauthProvidersResponse": {
+            "type": "object",
+            "properties": {
+                "providers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "google",
+                        "mock"
+                    ]
+                }
+            }
+        },
+        "v1.oauthStartResponse": {
+            "type": "object",
+            "properties": {
+                "authorization_url": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.pageLinks": {
+            "type": "object",
+            "properties": {
+                "next": {
+                    "type": "string"
+                },
+                "prev": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.recoveryCodesResponse": {
+            "type": "object",
+            "properties": {
+                "recovery_codes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
         "v1.refreshInput": {
             "type": "object",
             "required": [
@@ -516,6 +2767,138 @@ const docTemplate = `{
                 }
             }
         },
+        "v1.reorderImagesInput": {
+            "type": "object",
+            "required": [
+                "image_ids"
+            ],
+            "properties": {
+                "image_ids": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "v1.resetPasswordInput": {
+            "type": "object",
+            "required": [
+                "password",
+                "token"
+            ],
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "maxLength": 128
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.roleInput": {
+            "type": "object",
+            "required": [
+                "role"
+            ],
+            "properties": {
+                "role": {
+                    "type": "string",
+                    "enum": [
+                        "user",
+                        "moderator",
+                        "admin"
+                    ]
+                }
+            }
+        },
+        "v1.sessionResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "ip": {
+                    "type": "string",
+                    "example": "203.0.113.10"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "user_agent": {
+                    "type": "string",
+                    "example": "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)"
+                }
+            }
+        },
+        "v1.signInResponse": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "type": "string"
+                },
+                "challenge_expires_at": {
+                    "type": "string"
+                },
+                "challenge_token": {
+                    "type": "string"
+                },
+                "refresh_token": {
+                    "type": "string"
+                },
+                "two_factor_required": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "v1.signInTwoFactorInput": {
+            "type": "object",
+            "required": [
+                "challenge_token",
+                "code"
+            ],
+            "properties": {
+                "challenge_token": {
+                    "type": "string"
+                },
+                "code": {
+                    "type": "string",
+                    "maxLength": 32
+                }
+            }
+        },
+        "v1.signUpInput": {
+            "type": "object",
+            "required": [
+                "email",
+                "login",
+                "password"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "maxLength": 254
+                },
+                "login": {
+                    "type": "string",
+                    "maxLength": 64,
+                    "minLength": 3
+                },
+                "password": {
+                    "type": "string",
+                    "maxLength": 128
+                }
+            }
+        },
         "v1.tokenResponse": {
             "type": "object",
             "properties": {
@@ -527,6 +2910,48 @@ const docTemplate = `{
                 }
             }
         },
+        "v1.totpEnrollmentResponse": {
+            "type": "object",
+            "properties": {
+                "otpauth_uri": {
+                    "type": "string"
+                },
+                "secret": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.transitionAdInput": {
+            "type": "object",
+            "required": [
+                "status"
+            ],
+            "properties": {
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "draft",
+                        "published",
+                        "reserved",
+                        "sold",
+                        "archived",
+                        "hidden"
+                    ]
+                }
+            }
+        },
+        "v1.twoFactorCodeInput": {
+            "type": "object",
+            "required": [
+                "code"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "maxLength": 32
+                }
+            }
+        },
         "v1.updateAdInput": {
             "type": "object",
             "required": [
@@ -534,6 +2959,9 @@ const docTemplate = `{
                 "title"
             ],
             "properties": {
+                "category_id": {
+                    "type": "integer"
+                },
                 "description": {
                     "type": "string",
                     "maxLength": 1000
@@ -542,8 +2970,7 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "price": {
-                    "type": "number",
-                    "minimum": 0
+                    "$ref": "#/definitions/money.Money"
                 },
                 "title": {
                     "type": "string",
@@ -566,8 +2993,45 @@ const docTemplate = `{
                 },
                 "password": {
                     "type": "string",
-                    "maxLength": 64,
-                    "minLength": 8
+                    "maxLength": 128
+                }
+            }
+        },
+        "v1.userResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "john@example.com"
+                },
+                "email_verified": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 7
+                },
+                "login": {
+                    "type": "string",
+                    "example": "john"
+                },
+                "role": {
+                    "type": "string",
+                    "example": "user"
+                }
+            }
+        },
+        "v1.verifyEmailInput": {
+            "type": "object",
+            "required": [
+                "token"
+            ],
+            "properties": {
+                "token": {
+                    "type": "string"
                 }
             }
         }
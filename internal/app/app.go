@@ -10,6 +10,8 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -18,18 +20,28 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
-	"golang.org/x/crypto/bcrypt"
 
 	_ "rest-api-marketplace/docs"
 	"rest-api-marketplace/internal/config"
 	"rest-api-marketplace/internal/repository"
 	"rest-api-marketplace/internal/service"
 	v1 "rest-api-marketplace/internal/transport/http/v1"
+	"rest-api-marketplace/internal/worker"
 	"rest-api-marketplace/pkg/auth"
+	"rest-api-marketplace/pkg/breached"
 	postgres "rest-api-marketplace/pkg/client/postgresdb"
 	"rest-api-marketplace/pkg/hash"
+	"rest-api-marketplace/pkg/mailer"
+	"rest-api-marketplace/pkg/oidc"
+	"rest-api-marketplace/pkg/storage"
 )
 
+// shutdownTimeout is how long requests in flight are given to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
+// mockProviderPath is where the mock identity provider is served, under the public URL of the API
+const mockProviderPath = "/oauth-mock"
+
 const (
 	envLocal = "local"
 	envDev   = "dev"
@@ -82,35 +94,170 @@ func Run() {
 	}
 	defer postgres.CloseDatabase(db, log)
 
-	tokenManager, err := auth.NewManager(cfg.Auth.SigningKey)
+	signingKeys := make([]*auth.Key, 0, len(cfg.Auth.KeyFiles))
+	for _, path := range cfg.Auth.KeyFiles {
+		key, err := auth.LoadKeyFile(path)
+		if err != nil {
+			log.Error("failed to load JWT key", slog.String("path", path), slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		signingKeys = append(signingKeys, key)
+	}
+
+	tokenManager, err := auth.NewManager(cfg.Auth.SigningKey, signingKeys, auth.ClaimsOptions{
+		Issuer:   cfg.Auth.Issuer,
+		Audience: cfg.Auth.Audience,
+		Leeway:   cfg.Auth.Leeway,
+	})
 	if err != nil {
 		log.Error("failed to init token manager", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
-	passwordHasher := hash.NewBcryptHasher(bcrypt.DefaultCost)
+	bcryptHasher := hash.NewBcryptHasher(cfg.Password.BcryptCost)
+	argon2Hasher := hash.NewArgon2idHasher(hash.Argon2Params{
+		Memory:      cfg.Password.Argon2Memory,
+		Iterations:  cfg.Password.Argon2Iterations,
+		Parallelism: cfg.Password.Argon2Parallelism,
+		SaltLength:  hash.DefaultArgon2Params.SaltLength,
+		KeyLength:   hash.DefaultArgon2Params.KeyLength,
+	}, cfg.Password.Argon2Concurrency)
+	// hashes of the other algorithm still work and are upgraded on the next sign-in
+	passwordHasher := hash.NewRegistry(argon2Hasher, bcryptHasher)
+	if cfg.Password.Hasher == "bcrypt" {
+		passwordHasher = hash.NewRegistry(bcryptHasher, argon2Hasher)
+	}
+
+	passwordPolicy := service.PasswordPolicy{
+		MinLength:            cfg.Password.MinLength,
+		MaxLength:            cfg.Password.MaxLength,
+		MinCharClasses:       cfg.Password.MinCharClasses,
+		RejectSimilarToLogin: cfg.Password.RejectSimilarToLogin,
+	}
+	if cfg.Password.Hasher == "bcrypt" {
+		passwordPolicy.MaxBytes = hash.BcryptMaxPasswordBytes
+	}
+	if cfg.Password.BreachedFile != "" {
+		breachedList, err := breached.LoadFile(cfg.Password.BreachedFile)
+		if err != nil {
+			log.Error("failed to load breached passwords", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		passwordPolicy.Breached = breachedList
+		log.Info("breached passwords loaded", slog.Int("count", breachedList.Len()))
+	}
+
+	oauthProviders, mockProvider, err := newOAuthProviders(cfg.OAuth)
+	if err != nil {
+		log.Error("failed to init identity providers", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	if mockProvider != nil {
+		log.Warn("mock identity provider is enabled, it signs anyone in", slog.String("issuer", cfg.OAuth.RedirectBaseURL+mockProviderPath))
+	}
+
+	fileStorage, err := storage.NewLocalStorage(cfg.Storage.LocalDir, cfg.Storage.PublicURL)
+	if err != nil {
+		log.Error("failed to init file storage", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	var emailSender mailer.Mailer
+	switch cfg.Email.Mailer {
+	case "smtp":
+		emailSender, err = mailer.NewSMTPMailer(cfg.Email.SMTPHost, cfg.Email.SMTPPort, cfg.Email.SMTPUsername, cfg.Email.SMTPPassword, cfg.Email.From)
+	default:
+		emailSender, err = mailer.NewFileOutbox(cfg.Email.OutboxDir, cfg.Email.From)
+	}
+	if err != nil {
+		log.Error("failed to init mailer", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	// emails are sent in the background, requests don't wait for the mail server
+	mailQueue := worker.NewMailQueue(emailSender, log, 2, 100, cfg.Email.SendTimeout)
 	v := validator.New()
 
 	repos := repository.NewRepositories(db)
+	revocations := auth.NewMemoryRevocationStore(repos.RevokedTokens)
+
+	var attemptStore auth.AttemptStore = auth.NewMemoryAttemptStore()
+	if cfg.Login.Store == "postgres" {
+		attemptStore = repos.LoginAttempts
+	}
+	loginLimiter := auth.NewLoginLimiter(attemptStore, auth.LimitPolicy{
+		FreeAttempts:    cfg.Login.LoginFreeAttempts,
+		BaseDelay:       cfg.Login.BackoffBase,
+		MaxDelay:        cfg.Login.BackoffMax,
+		MaxFailures:     cfg.Login.LoginMaxFailures,
+		LockoutDuration: cfg.Login.LockoutDuration,
+		Window:          cfg.Login.Window,
+	}, auth.LimitPolicy{
+		FreeAttempts:    cfg.Login.IPFreeAttempts,
+		BaseDelay:       cfg.Login.BackoffBase,
+		MaxDelay:        cfg.Login.BackoffMax,
+		MaxFailures:     cfg.Login.IPMaxFailures,
+		LockoutDuration: cfg.Login.LockoutDuration,
+		Window:          cfg.Login.Window,
+	})
+
+	imagePool := worker.NewImagePool(log, cfg.Storage.ImageWorkers, 100, time.Minute*10)
 
 	services := service.NewServices(service.Deps{
 		Logger:          log,
 		Repos:           repos,
 		Hasher:          passwordHasher,
+		PasswordPolicy:  passwordPolicy,
 		TokenManager:    tokenManager,
+		Revocations:     revocations,
+		LoginLimiter:    loginLimiter,
+		Storage:         fileStorage,
 		AccessTokenTTL:  cfg.Auth.AccessTokenTTL,
 		RefreshTokenTTL: cfg.Auth.RefreshTokenTTL,
+		AdRestoreWindow: cfg.Ads.RestoreWindow,
+		Images: service.ImageOptions{
+			MaxSize:      cfg.Storage.MaxImageSize,
+			VariantSizes: cfg.Storage.ImageVariantSizes,
+			Queue:        imagePool,
+		},
+		Email: service.EmailOptions{
+			Mailer:           mailQueue,
+			VerificationTTL:  cfg.Email.VerificationTTL,
+			VerificationURL:  cfg.Email.VerificationURL,
+			RequireVerified:  cfg.Email.RequireVerified,
+			PasswordResetTTL: cfg.Email.PasswordResetTTL,
+			PasswordResetURL: cfg.Email.PasswordResetURL,
+		},
+		TwoFactor: service.TwoFactorOptions{
+			Issuer:       cfg.Auth.TOTPIssuer,
+			ChallengeTTL: cfg.Auth.TwoFactorChallengeTTL,
+		},
+		OAuth: service.OAuthOptions{
+			Providers: oauthProviders,
+			StateTTL:  cfg.OAuth.StateTTL,
+		},
 	})
 
-	handler := v1.NewHandler(services, tokenManager)
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	adsPurger := worker.NewAdsPurger(services.Ads, log, cfg.Ads.PurgeInterval, cfg.Ads.DeletedRetention)
+	go adsPurger.Run(workersCtx)
+	go imagePool.Run(workersCtx, services.Ads)
+	go mailQueue.Run(workersCtx)
+
+	handler := v1.NewHandler(services, tokenManager, revocations, cfg.Storage.MaxImageSize)
 
 	e := echo.New()
 	e.Validator = &CustomValidator{validator: v}
 	e.HTTPErrorHandler = customErrorHandler(log)
+	e.IPExtractor = ipExtractor(cfg.Server.TrustedProxies)
 
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 	//e.Logger.Fatal(e.Start(":1323"))
 
+	handler.InitWellKnown(e.Group("/.well-known"))
 	handler.Init(e.Group("/api"))
+	if mockProvider != nil {
+		e.Any(mockProviderPath+"/*", echo.WrapHandler(mockProvider))
+	}
 
 	listener, err := net.Listen("tcp", cfg.Server.Host+":"+cfg.Server.Port)
 	if err != nil {
@@ -124,10 +271,32 @@ func Run() {
 		ReadHeaderTimeout: 15 * time.Second,
 	}
 
-	err = server.Serve(listener)
-	if err != nil && !errors.Is(err, http.ErrServerClosed) {
-		log.Error("failed to start server", slog.String("error", err.Error()))
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Serve(listener)
+	}()
+
+	stopCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serverErr:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("failed to start server", slog.String("error", err.Error()))
+		}
+	case <-stopCtx.Done():
+		log.Info("shutting down server")
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Error("failed to shut down server", slog.String("error", err.Error()))
+		}
+		cancelShutdown()
 	}
+
+	// requests are done by now, so the workers get no new jobs and only finish the started ones
+	stopWorkers()
+	imagePool.Wait()
+	mailQueue.Wait()
 }
 
 // setupLogger configures logger based on the environment
@@ -194,3 +363,68 @@ func runMigrations(cfg config.PostgresConfig, log *slog.Logger) {
 
 	log.Info("migrations successfully applied")
 }
+
+// newOAuthProviders creates clients of the configured identity providers and, when enabled,
+// the mock provider along with its client
+func newOAuthProviders(cfg config.OAuthConfig) (map[string]service.OAuthProvider, *oidc.MockProvider, error) {
+	providers := make(map[string]service.OAuthProvider, len(cfg.Providers)+1)
+	for _, p := range cfg.Providers {
+		client, err := oidc.NewClient(oidc.Config{
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  oauthCallbackURL(cfg.RedirectBaseURL, p.Name),
+			Scopes:       p.Scopes,
+		}, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("provider %q: %w", p.Name, err)
+		}
+		providers[p.Name] = client
+	}
+
+	if !cfg.MockProvider {
+		return providers, nil, nil
+	}
+
+	mockCfg := oidc.Config{
+		Issuer:       cfg.RedirectBaseURL + mockProviderPath,
+		ClientID:     "mock-client",
+		ClientSecret: "mock-secret",
+		RedirectURL:  oauthCallbackURL(cfg.RedirectBaseURL, "mock"),
+		Scopes:       []string{"email", "profile"},
+	}
+	mock, err := oidc.NewMockProvider(mockCfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("mock provider: %w", err)
+	}
+	client, err := oidc.NewClient(mockCfg, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("mock provider: %w", err)
+	}
+	providers["mock"] = client
+
+	return providers, mock, nil
+}
+
+// oauthCallbackURL returns the URL a provider redirects users back to
+func oauthCallbackURL(baseURL, provider string) string {
+	return baseURL + "/api/v1/users/oauth/" + provider + "/callback"
+}
+
+// ipExtractor picks how the client IP is found. Without trusted proxies it is the address of the connection,
+// headers can be forged by anyone. Behind proxies X-Forwarded-For is read, skipping only the listed proxies
+func ipExtractor(trustedProxies []*net.IPNet) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, proxy := range trustedProxies {
+		options = append(options, echo.TrustIPRange(proxy))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
@@ -2,22 +2,36 @@
 package config
 
 import (
+	"fmt"
+	"net"
 	"os"
+	"runtime"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Config holds all application configurations
 type Config struct {
-	Env    string
-	Server ServerConfig
-	DB     PostgresConfig
-	Auth   AuthConfig
+	Env      string
+	Server   ServerConfig
+	DB       PostgresConfig
+	Auth     AuthConfig
+	Login    LoginLimitConfig
+	Password PasswordConfig
+	Ads      AdsConfig
+	Storage  StorageConfig
+	Email    EmailConfig
+	OAuth    OAuthConfig
 }
 
 // ServerConfig holds HTTP server settings
 type ServerConfig struct {
 	Host string
 	Port string
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For is believed, without them
+	// the client IP is the address of the connection
+	TrustedProxies []*net.IPNet
 }
 
 // PostgresConfig holds PostgreSQL connection settings
@@ -29,11 +43,98 @@ type PostgresConfig struct {
 	DBName   string
 }
 
-// AuthConfig holds JWT authentication settings
+// AuthConfig holds JWT and two-factor authentication settings
 type AuthConfig struct {
 	SigningKey      string
+	KeyFiles        []string
+	Issuer          string
+	Audience        []string
+	Leeway          time.Duration
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
+	// TOTPIssuer names the service in authenticator apps
+	TOTPIssuer            string
+	TwoFactorChallengeTTL time.Duration
+}
+
+// LoginLimitConfig holds brute-force protection settings of sign-in
+type LoginLimitConfig struct {
+	Store             string // "memory" keeps attempts per instance, "postgres" shares them between instances
+	LoginFreeAttempts int
+	LoginMaxFailures  int
+	IPFreeAttempts    int
+	IPMaxFailures     int
+	BackoffBase       time.Duration
+	BackoffMax        time.Duration
+	LockoutDuration   time.Duration
+	Window            time.Duration
+}
+
+// PasswordConfig holds password hashing and password policy settings
+type PasswordConfig struct {
+	Hasher               string // "argon2id" or "bcrypt", hashes of the other algorithm are still accepted and upgraded on sign-in
+	BcryptCost           int
+	Argon2Memory         uint32 // KiB
+	Argon2Iterations     uint32
+	Argon2Parallelism    uint8
+	Argon2Concurrency    int // hashes computed at once, the others wait
+	MinLength            int
+	MaxLength            int
+	MinCharClasses       int
+	RejectSimilarToLogin bool
+	BreachedFile         string // empty disables the breached passwords check
+}
+
+// OAuthConfig holds settings of signing in with external OpenID Connect identity providers
+type OAuthConfig struct {
+	Providers []OAuthProviderConfig
+	// RedirectBaseURL is the public URL of the API, providers redirect to RedirectBaseURL/api/v1/users/oauth/{provider}/callback
+	RedirectBaseURL string
+	StateTTL        time.Duration
+	// MockProvider serves an in-process provider named "mock" under RedirectBaseURL/oauth-mock, it is refused in prod
+	MockProvider bool
+}
+
+// OAuthProviderConfig holds the registration of the service at an identity provider
+type OAuthProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// AdsConfig holds settings of deleted ads retention
+type AdsConfig struct {
+	RestoreWindow    time.Duration
+	DeletedRetention time.Duration
+	PurgeInterval    time.Duration
+}
+
+// StorageConfig holds settings of uploaded files storage and image processing
+type StorageConfig struct {
+	LocalDir          string
+	PublicURL         string
+	MaxImageSize      int64
+	ImageVariantSizes []int
+	ImageWorkers      int
+}
+
+// EmailConfig holds mailer, email verification and password reset settings
+type EmailConfig struct {
+	Mailer           string // "outbox" writes emails into OutboxDir, "smtp" sends them
+	From             string
+	OutboxDir        string
+	SMTPHost         string
+	SMTPPort         string
+	SMTPUsername     string
+	SMTPPassword     string
+	SendTimeout      time.Duration // time one email has to reach the mail server, they are sent in the background
+	VerificationTTL  time.Duration
+	VerificationURL  string
+	RequireVerified  bool
+	PasswordResetTTL time.Duration
+	PasswordResetURL string
 }
 
 // LoadConfig reads environment variables and returns Config
@@ -47,11 +148,258 @@ func LoadConfig() (*Config, error) {
 	if err != nil {
 		refreshTTL = time.Hour * 24 * 30
 	}
+
+	restoreWindow, err := time.ParseDuration(os.Getenv("ADS_RESTORE_WINDOW"))
+	if err != nil {
+		restoreWindow = time.Hour * 24 * 3
+	}
+
+	deletedRetention, err := time.ParseDuration(os.Getenv("ADS_DELETED_RETENTION"))
+	if err != nil {
+		deletedRetention = time.Hour * 24 * 30
+	}
+
+	purgeInterval, err := time.ParseDuration(os.Getenv("ADS_PURGE_INTERVAL"))
+	if err != nil || purgeInterval <= 0 {
+		purgeInterval = time.Hour * 1
+	}
+	if deletedRetention < restoreWindow {
+		deletedRetention = restoreWindow
+	}
+
+	storageDir := os.Getenv("STORAGE_LOCAL_DIR")
+	if storageDir == "" {
+		storageDir = "./uploads"
+	}
+
+	storageURL := os.Getenv("STORAGE_PUBLIC_URL")
+	if storageURL == "" {
+		storageURL = "/api/v1/media"
+	}
+
+	maxImageSize, err := strconv.ParseInt(os.Getenv("MAX_IMAGE_SIZE"), 10, 64)
+	if err != nil || maxImageSize <= 0 {
+		maxImageSize = 5 << 20
+	}
+
+	variantSizes := []int{150, 400, 1024}
+	if raw := os.Getenv("IMAGE_VARIANT_SIZES"); raw != "" {
+		variantSizes = variantSizes[:0]
+		for _, part := range strings.Split(raw, ",") {
+			size, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || size <= 0 {
+				return nil, fmt.Errorf("invalid IMAGE_VARIANT_SIZES value %q", part)
+			}
+			variantSizes = append(variantSizes, size)
+		}
+	}
+
+	imageWorkers, err := strconv.Atoi(os.Getenv("IMAGE_WORKERS"))
+	if err != nil || imageWorkers <= 0 {
+		imageWorkers = 4
+	}
+
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		issuer = "rest-api-marketplace"
+	}
+
+	audience := splitList(os.Getenv("JWT_AUDIENCE"))
+	if len(audience) == 0 {
+		audience = []string{"rest-api-marketplace"}
+	}
+
+	leeway, err := time.ParseDuration(os.Getenv("JWT_LEEWAY"))
+	if err != nil || leeway < 0 {
+		leeway = time.Second * 30
+	}
+
+	totpIssuer := os.Getenv("TOTP_ISSUER")
+	if totpIssuer == "" {
+		totpIssuer = "rest-api-marketplace"
+	}
+
+	challengeTTL, err := time.ParseDuration(os.Getenv("TWO_FACTOR_CHALLENGE_TTL"))
+	if err != nil || challengeTTL <= 0 {
+		challengeTTL = time.Minute * 5
+	}
+
+	loginStore := os.Getenv("LOGIN_ATTEMPTS_STORE")
+	if loginStore == "" {
+		loginStore = "memory"
+	}
+
+	loginFreeAttempts, err := strconv.Atoi(os.Getenv("LOGIN_FREE_ATTEMPTS"))
+	if err != nil || loginFreeAttempts < 0 {
+		loginFreeAttempts = 3
+	}
+
+	loginMaxFailures, err := strconv.Atoi(os.Getenv("LOGIN_MAX_FAILURES"))
+	if err != nil || loginMaxFailures < 0 {
+		loginMaxFailures = 10
+	}
+
+	ipFreeAttempts, err := strconv.Atoi(os.Getenv("IP_FREE_ATTEMPTS"))
+	if err != nil || ipFreeAttempts < 0 {
+		ipFreeAttempts = 20
+	}
+
+	ipMaxFailures, err := strconv.Atoi(os.Getenv("IP_MAX_FAILURES"))
+	if err != nil || ipMaxFailures < 0 {
+		ipMaxFailures = 100
+	}
+
+	backoffBase, err := time.ParseDuration(os.Getenv("LOGIN_BACKOFF_BASE"))
+	if err != nil || backoffBase < 0 {
+		backoffBase = time.Second * 1
+	}
+
+	backoffMax, err := time.ParseDuration(os.Getenv("LOGIN_BACKOFF_MAX"))
+	if err != nil || backoffMax < backoffBase {
+		backoffMax = max(time.Minute*1, backoffBase)
+	}
+
+	lockoutDuration, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_DURATION"))
+	if err != nil || lockoutDuration <= 0 {
+		lockoutDuration = time.Minute * 15
+	}
+
+	attemptWindow, err := time.ParseDuration(os.Getenv("LOGIN_ATTEMPT_WINDOW"))
+	if err != nil || attemptWindow <= 0 {
+		attemptWindow = time.Hour * 1
+	}
+
+	passwordHasher := os.Getenv("PASSWORD_HASHER")
+	switch passwordHasher {
+	case "":
+		passwordHasher = "argon2id"
+	case "argon2id", "bcrypt":
+	default:
+		return nil, fmt.Errorf("invalid PASSWORD_HASHER value %q", passwordHasher)
+	}
+
+	bcryptCost := 10
+	if raw := os.Getenv("BCRYPT_COST"); raw != "" {
+		bcryptCost, err = strconv.Atoi(raw)
+		if err != nil || bcryptCost < 4 || bcryptCost > 31 {
+			return nil, fmt.Errorf("invalid BCRYPT_COST value %q", raw)
+		}
+	}
+
+	argon2Memory, err := strconv.ParseUint(os.Getenv("ARGON2_MEMORY"), 10, 32)
+	if err != nil || argon2Memory < 8 {
+		argon2Memory = 19 * 1024
+	}
+
+	argon2Iterations, err := strconv.ParseUint(os.Getenv("ARGON2_ITERATIONS"), 10, 32)
+	if err != nil || argon2Iterations == 0 {
+		argon2Iterations = 2
+	}
+
+	argon2Parallelism, err := strconv.ParseUint(os.Getenv("ARGON2_PARALLELISM"), 10, 8)
+	if err != nil || argon2Parallelism == 0 {
+		argon2Parallelism = 1
+	}
+
+	argon2Concurrency, err := strconv.Atoi(os.Getenv("ARGON2_MAX_CONCURRENCY"))
+	if err != nil || argon2Concurrency <= 0 {
+		argon2Concurrency = runtime.NumCPU()
+	}
+
+	passwordMinLength, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH"))
+	if err != nil || passwordMinLength <= 0 {
+		passwordMinLength = 8
+	}
+
+	passwordMaxLength, err := strconv.Atoi(os.Getenv("PASSWORD_MAX_LENGTH"))
+	if err != nil || passwordMaxLength <= 0 {
+		passwordMaxLength = 64
+	}
+	if passwordMaxLength < passwordMinLength {
+		return nil, fmt.Errorf("PASSWORD_MAX_LENGTH %d is less than PASSWORD_MIN_LENGTH %d", passwordMaxLength, passwordMinLength)
+	}
+
+	passwordMinCharClasses, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_CHAR_CLASSES"))
+	if err != nil || passwordMinCharClasses < 0 || passwordMinCharClasses > 4 {
+		passwordMinCharClasses = 2
+	}
+
+	rejectSimilarToLogin, err := strconv.ParseBool(os.Getenv("PASSWORD_REJECT_SIMILAR_TO_LOGIN"))
+	if err != nil {
+		rejectSimilarToLogin = true
+	}
+
+	mailerKind := os.Getenv("MAILER")
+	if mailerKind == "" {
+		mailerKind = "outbox"
+	}
+	if mailerKind != "outbox" && mailerKind != "smtp" {
+		return nil, fmt.Errorf("invalid MAILER value %q", mailerKind)
+	}
+
+	mailFrom := os.Getenv("MAIL_FROM")
+	if mailFrom == "" {
+		mailFrom = "no-reply@localhost"
+	}
+
+	outboxDir := os.Getenv("MAIL_OUTBOX_DIR")
+	if outboxDir == "" {
+		outboxDir = "./outbox"
+	}
+
+	smtpPort := os.Getenv("SMTP_PORT")
+	if smtpPort == "" {
+		smtpPort = "587"
+	}
+
+	mailSendTimeout, err := time.ParseDuration(os.Getenv("MAIL_SEND_TIMEOUT"))
+	if err != nil || mailSendTimeout <= 0 {
+		mailSendTimeout = time.Second * 30
+	}
+
+	verificationTTL, err := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_TTL"))
+	if err != nil || verificationTTL <= 0 {
+		verificationTTL = time.Hour * 24
+	}
+
+	passwordResetTTL, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_TTL"))
+	if err != nil || passwordResetTTL <= 0 {
+		passwordResetTTL = time.Hour * 1
+	}
+
+	requireVerified, _ := strconv.ParseBool(os.Getenv("REQUIRE_VERIFIED_EMAIL"))
+
+	oauthProviders, err := loadOAuthProviders(splitList(os.Getenv("OAUTH_PROVIDERS")))
+	if err != nil {
+		return nil, err
+	}
+
+	oauthRedirectBaseURL := strings.TrimSuffix(os.Getenv("OAUTH_REDIRECT_BASE_URL"), "/")
+	if oauthRedirectBaseURL == "" {
+		oauthRedirectBaseURL = "http://localhost:" + os.Getenv("SERVER_PORT")
+	}
+
+	oauthStateTTL, err := time.ParseDuration(os.Getenv("OAUTH_STATE_TTL"))
+	if err != nil || oauthStateTTL <= 0 {
+		oauthStateTTL = time.Minute * 10
+	}
+
+	oauthMock, _ := strconv.ParseBool(os.Getenv("OAUTH_MOCK_PROVIDER"))
+	if oauthMock && os.Getenv("ENV_LOG") == "prod" {
+		return nil, fmt.Errorf("OAUTH_MOCK_PROVIDER signs anyone in and can't be enabled in prod")
+	}
+
+	trustedProxies, err := parseTrustedProxies(splitList(os.Getenv("TRUSTED_PROXIES")))
+	if err != nil {
+		return nil, err
+	}
+
 	cfg := &Config{
 		Env: os.Getenv("ENV_LOG"),
 		Server: ServerConfig{
-			Host: os.Getenv("SERVER_HOST"),
-			Port: os.Getenv("SERVER_PORT"),
+			Host:           os.Getenv("SERVER_HOST"),
+			Port:           os.Getenv("SERVER_PORT"),
+			TrustedProxies: trustedProxies,
 		},
 		DB: PostgresConfig{
 			Host:     os.Getenv("POSTGRES_HOST"),
@@ -61,11 +409,139 @@ func LoadConfig() (*Config, error) {
 			DBName:   os.Getenv("POSTGRES_DB"),
 		},
 		Auth: AuthConfig{
-			SigningKey:      os.Getenv("SIGNING_KEY"),
-			AccessTokenTTL:  accessTTL,
-			RefreshTokenTTL: refreshTTL,
+			SigningKey:            os.Getenv("SIGNING_KEY"),
+			KeyFiles:              splitList(os.Getenv("JWT_KEY_FILES")),
+			Issuer:                issuer,
+			Audience:              audience,
+			Leeway:                leeway,
+			AccessTokenTTL:        accessTTL,
+			RefreshTokenTTL:       refreshTTL,
+			TOTPIssuer:            totpIssuer,
+			TwoFactorChallengeTTL: challengeTTL,
+		},
+		Login: LoginLimitConfig{
+			Store:             loginStore,
+			LoginFreeAttempts: loginFreeAttempts,
+			LoginMaxFailures:  loginMaxFailures,
+			IPFreeAttempts:    ipFreeAttempts,
+			IPMaxFailures:     ipMaxFailures,
+			BackoffBase:       backoffBase,
+			BackoffMax:        backoffMax,
+			LockoutDuration:   lockoutDuration,
+			Window:            attemptWindow,
+		},
+		Password: PasswordConfig{
+			Hasher:               passwordHasher,
+			BcryptCost:           bcryptCost,
+			Argon2Memory:         uint32(argon2Memory),
+			Argon2Iterations:     uint32(argon2Iterations),
+			Argon2Parallelism:    uint8(argon2Parallelism),
+			Argon2Concurrency:    argon2Concurrency,
+			MinLength:            passwordMinLength,
+			MaxLength:            passwordMaxLength,
+			MinCharClasses:       passwordMinCharClasses,
+			RejectSimilarToLogin: rejectSimilarToLogin,
+			BreachedFile:         os.Getenv("BREACHED_PASSWORDS_FILE"),
+		},
+		Ads: AdsConfig{
+			RestoreWindow:    restoreWindow,
+			DeletedRetention: deletedRetention,
+			PurgeInterval:    purgeInterval,
+		},
+		Storage: StorageConfig{
+			LocalDir:          storageDir,
+			PublicURL:         storageURL,
+			MaxImageSize:      maxImageSize,
+			ImageVariantSizes: variantSizes,
+			ImageWorkers:      imageWorkers,
+		},
+		Email: EmailConfig{
+			Mailer:           mailerKind,
+			From:             mailFrom,
+			OutboxDir:        outboxDir,
+			SMTPHost:         os.Getenv("SMTP_HOST"),
+			SMTPPort:         smtpPort,
+			SMTPUsername:     os.Getenv("SMTP_USERNAME"),
+			SMTPPassword:     os.Getenv("SMTP_PASSWORD"),
+			SendTimeout:      mailSendTimeout,
+			VerificationTTL:  verificationTTL,
+			VerificationURL:  os.Getenv("EMAIL_VERIFICATION_URL"),
+			RequireVerified:  requireVerified,
+			PasswordResetTTL: passwordResetTTL,
+			PasswordResetURL: os.Getenv("PASSWORD_RESET_URL"),
+		},
+		OAuth: OAuthConfig{
+			Providers:       oauthProviders,
+			RedirectBaseURL: oauthRedirectBaseURL,
+			StateTTL:        oauthStateTTL,
+			MockProvider:    oauthMock,
 		},
 	}
 
 	return cfg, nil
 }
+
+// loadOAuthProviders reads OAUTH_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET and _SCOPES of every named provider
+func loadOAuthProviders(names []string) ([]OAuthProviderConfig, error) {
+	providers := make([]OAuthProviderConfig, 0, len(names))
+	for _, name := range names {
+		if name == "mock" || strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			return nil, fmt.Errorf("invalid OAUTH_PROVIDERS name %q", name)
+		}
+
+		prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := OAuthProviderConfig{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return nil, fmt.Errorf("%sISSUER and %sCLIENT_ID are required", prefix, prefix)
+		}
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = []string{"email", "profile"}
+		}
+
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// parseTrustedProxies parses proxy addresses given as CIDR ranges or single IPs
+func parseTrustedProxies(items []string) ([]*net.IPNet, error) {
+	proxies := make([]*net.IPNet, 0, len(items))
+	for _, item := range items {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid TRUSTED_PROXIES address %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES range %q", item)
+		}
+		proxies = append(proxies, ipNet)
+	}
+	return proxies, nil
+}
+
+// splitList splits a comma separated env value, dropping blank items
+func splitList(raw string) []string {
+	var items []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			items = append(items, part)
+		}
+	}
+	return items
+}
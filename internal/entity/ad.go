@@ -3,33 +3,56 @@ package entity
 
 import (
 	"time"
+
+	"rest-api-marketplace/pkg/money"
+)
+
+// AdStatus represents a lifecycle state of an ad
+type AdStatus string
+
+// Ad lifecycle states, only published ads are visible to everyone. Hidden ads were taken down by a moderator
+const (
+	AdStatusDraft     AdStatus = "draft"
+	AdStatusPublished AdStatus = "published"
+	AdStatusReserved  AdStatus = "reserved"
+	AdStatusSold      AdStatus = "sold"
+	AdStatusArchived  AdStatus = "archived"
+	AdStatusHidden    AdStatus = "hidden"
 )
 
 // Ad represents an advertisement
 type Ad struct {
-	ID          int64     `json:"id"`
-	UserID      int64     `json:"user_id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	ImageURL    string    `json:"image_url"`
-	Price       float64   `json:"price"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          int64       `json:"id"`
+	UserID      int64       `json:"user_id"`
+	CategoryID  *int64      `json:"category_id"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	ImageURL    string      `json:"image_url"`
+	Images      []AdImage   `json:"images"`
+	Price       money.Money `json:"price"`
+	Status      AdStatus    `json:"status"`
+	CreatedAt   time.Time   `json:"created_at"`
+	DeletedAt   *time.Time  `json:"deleted_at,omitempty"`
 }
 
 // AdWithAuthor represents an ad along with author's login
 type AdWithAuthor struct {
-	ID          int64     `json:"id"`
-	UserID      int64     `json:"user_id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	ImageURL    string    `json:"image_url"`
-	Price       float64   `json:"price"`
-	CreatedAt   time.Time `json:"created_at"`
-	AuthorLogin string    `json:"author_login"`
+	ID             int64        `json:"id"`
+	UserID         int64        `json:"user_id"`
+	CategoryID     *int64       `json:"category_id"`
+	Title          string       `json:"title"`
+	Description    string       `json:"description"`
+	ImageURL       string       `json:"image_url"`
+	Images         []AdImage    `json:"images"`
+	Price          money.Money  `json:"price"`
+	ConvertedPrice *money.Money `json:"converted_price,omitempty"` // in the requested currency, nil if no rate is known
+	Status         AdStatus     `json:"status"`
+	CreatedAt      time.Time    `json:"created_at"`
+	AuthorLogin    string       `json:"author_login"`
 }
 
-// AdResponse represents ad response for API with ownership info
+// AdResponse represents an ad with author info and ownership info of the current user
 type AdResponse struct {
-	AdWithAuthor AdWithAuthor
-	IsOwner      *bool `json:"is_owner"`
+	AdWithAuthor
+	IsOwner *bool `json:"is_owner"`
 }
@@ -0,0 +1,37 @@
+package entity
+
+import "time"
+
+// AuditAction names a privileged action recorded in the audit log
+type AuditAction string
+
+// Privileged actions, ad actions are recorded when a moderator acts on an ad of another user
+const (
+	AuditAdUpdate       AuditAction = "ad.update"
+	AuditAdTransition   AuditAction = "ad.transition"
+	AuditAdDelete       AuditAction = "ad.delete"
+	AuditAdRestore      AuditAction = "ad.restore"
+	AuditCategoryCreate AuditAction = "category.create"
+	AuditCategoryUpdate AuditAction = "category.update"
+	AuditCategoryDelete AuditAction = "category.delete"
+	AuditUserRoleChange AuditAction = "user.role_change"
+)
+
+// Kinds of objects privileged actions are performed on
+const (
+	AuditTargetAd       = "ad"
+	AuditTargetCategory = "category"
+	AuditTargetUser     = "user"
+)
+
+// AuditEntry represents a privileged action performed by a moderator or an admin
+type AuditEntry struct {
+	ID         int64             `json:"id"`
+	ActorID    int64             `json:"actor_id"`
+	ActorRole  Role              `json:"actor_role"`
+	Action     AuditAction       `json:"action"`
+	TargetType string            `json:"target_type"`
+	TargetID   int64             `json:"target_id"`
+	Details    map[string]string `json:"details,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+// Category represents an ad category, optionally nested under a parent category
+type Category struct {
+	ID        int64     `json:"id"`
+	ParentID  *int64    `json:"parent_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CategoryNode represents a category together with its subcategories
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
+}
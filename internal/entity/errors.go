@@ -1,6 +1,10 @@
 package entity
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 // Errors represents the most common errors in the service's responses to user requests
 var (
@@ -9,6 +13,70 @@ var (
 	ErrInvalidCreds = errors.New("invalid login or password")
 	ErrInvalidInput = errors.New("invalid input")
 
-	ErrAdNotFound = errors.New("ad not found")
-	ErrForbidden  = errors.New("forbidden: not enough rights")
+	ErrTooManyAttempts = errors.New("too many failed sign-in attempts")
+	ErrWeakPassword    = errors.New("password does not meet the password policy")
+
+	ErrEmailTaken               = errors.New("email is already used by another user")
+	ErrEmailNotVerified         = errors.New("email is not verified")
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrInvalidResetToken        = errors.New("invalid or expired password reset token")
+
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrRefreshTokenReused = errors.New("refresh token was already used")
+
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled  = errors.New("two-factor authentication is not enabled")
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor authentication code")
+	ErrInvalidChallenge     = errors.New("invalid or expired two-factor challenge")
+
+	ErrUnknownProvider    = errors.New("unknown identity provider")
+	ErrInvalidOAuthState  = errors.New("invalid or expired external sign-in state")
+	ErrExternalAuthFailed = errors.New("external sign-in failed")
+	ErrIdentityNotFound   = errors.New("linked identity not found")
+	ErrIdentityLinked     = errors.New("external account is already linked to another user")
+	ErrProviderLinked     = errors.New("an account of this provider is already linked")
+	ErrLastSignInMethod   = errors.New("cannot unlink the last way to sign in")
+
+	ErrAdNotFound          = errors.New("ad not found")
+	ErrForbidden           = errors.New("forbidden: not enough rights")
+	ErrInvalidAdTransition = errors.New("ad status transition is not allowed")
+	ErrAdRestoreExpired    = errors.New("ad can no longer be restored")
+	ErrInvalidCursor       = errors.New("invalid pagination cursor")
+
+	ErrImageNotFound    = errors.New("image not found")
+	ErrImageTooLarge    = errors.New("image is too large")
+	ErrUnsupportedImage = errors.New("unsupported image type")
+	ErrGalleryConflict  = errors.New("ad gallery was changed concurrently")
+
+	ErrCategoryNotFound       = errors.New("category not found")
+	ErrParentCategoryNotFound = errors.New("parent category not found")
+	ErrCategoryHasChildren    = errors.New("category has subcategories")
+	ErrCategoryCycle          = errors.New("category cannot be nested under itself")
 )
+
+// ThrottledError tells the client how long to wait before trying again, it wraps ErrTooManyAttempts
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// PasswordPolicyError names the password policy rule a password failed, it wraps ErrWeakPassword
+type PasswordPolicyError struct {
+	Rule   string
+	Reason string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return e.Reason
+}
+
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
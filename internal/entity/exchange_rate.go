@@ -0,0 +1,15 @@
+package entity
+
+import (
+	"time"
+
+	"rest-api-marketplace/pkg/money"
+)
+
+// ExchangeRate is the amount of a currency worth one unit of money.DefaultCurrency
+type ExchangeRate struct {
+	Currency   money.Currency `json:"currency" swaggertype:"string" example:"EUR"`
+	Rate       string         `json:"rate" example:"0.92"`
+	MinorUnits int            `json:"-"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
@@ -0,0 +1,27 @@
+package entity
+
+import "time"
+
+// Identity is an account at an external identity provider linked to a user, the user can sign in with it
+type Identity struct {
+	ID       int64
+	UserID   int64
+	Provider string
+	// Subject is the account ID at the provider, it never changes unlike the email
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+}
+
+// OAuthState represents a pending external sign-in, only the hash of the state parameter is stored.
+// It binds the provider callback to the request that started the flow
+type OAuthState struct {
+	StateHash    string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	// UserID is set when a signed-in user links an identity instead of signing in
+	UserID    *int64
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
@@ -0,0 +1,27 @@
+package entity
+
+import "time"
+
+// AdImage represents an image in an ad gallery, images are ordered by position and one of them is the cover
+type AdImage struct {
+	ID          int64            `json:"id"`
+	AdID        int64            `json:"ad_id"`
+	StorageKey  string           `json:"-"`
+	URL         string           `json:"url"`
+	ContentType string           `json:"content_type"`
+	Size        int64            `json:"size"`
+	Position    int              `json:"position"`
+	IsCover     bool             `json:"is_cover"`
+	Variants    []AdImageVariant `json:"variants"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+// AdImageVariant represents a downscaled copy of an ad image, its longest side is at most Size pixels
+type AdImageVariant struct {
+	ImageID    int64  `json:"-"`
+	Size       int    `json:"size"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	StorageKey string `json:"-"`
+	URL        string `json:"url"`
+}
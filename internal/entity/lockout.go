@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// Lockout records an account locked out after too many failed sign-in attempts, for its owner to review
+type Lockout struct {
+	ID          int64
+	UserID      int64
+	IP          string
+	Failures    int
+	LockedUntil time.Time
+	CreatedAt   time.Time
+}
@@ -1,11 +1,35 @@
 package entity
 
+import "rest-api-marketplace/pkg/money"
+
 // GetAdsQuery represents query parameters for fetching ads
 type GetAdsQuery struct {
-	Page     int
-	Limit    int
-	SortBy   string // "date" or "price"
-	SortDir  string // "desc" or "asc"
-	MinPrice float64
-	MaxPrice float64
+	Page       int
+	Limit      int
+	SortBy     string         // "date", "price" or "relevance"
+	SortDir    string         // "desc" or "asc"
+	MinPrice   *money.Money   // nil means no lower bound
+	MaxPrice   *money.Money   // nil means no upper bound
+	Currency   money.Currency // prices are converted to it for filtering and sorting, empty means no conversion
+	CategoryID int64          // includes subcategories, 0 means any
+	Search     string         // full-text query over title and description
+	Status     AdStatus       // empty means any
+	UserID     int64          // author filter, 0 means any
+	After      *AdsCursor     // continue after this ad instead of skipping pages
+	SkipCount  bool           // do not count all matching ads
+}
+
+// AdsCursor points at the last ad of a page in an ads list sorted by SortBy and SortDir
+type AdsCursor struct {
+	SortBy  string
+	SortDir string
+	Key     *string // sort key of the ad as text, nil if the ad has no converted price
+	ID      int64
+}
+
+// AdsPage is a page of listed ads
+type AdsPage struct {
+	Items      []AdResponse
+	Total      *int64     // number of all matching ads, nil if counting was skipped
+	NextCursor *AdsCursor // nil on the last page
 }
@@ -2,8 +2,23 @@ package entity
 
 import "time"
 
-// Session represents a user session with refresh token and expiry
+// Session represents a signed in device of a user, all refresh tokens rotated within it form one family
 type Session struct {
-	RefreshToken string    `json:"refreshToken"`
-	ExpiresAt    time.Time `json:"expires_at"`
+	ID               int64     `json:"id"`
+	UserID           int64     `json:"user_id"`
+	RefreshTokenHash string    `json:"-"` // hash of the current refresh token
+	UserAgent        string    `json:"user_agent"`
+	IP               string    `json:"ip"`
+	CreatedAt        time.Time `json:"created_at"`
+	LastUsedAt       time.Time `json:"last_used_at"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// RefreshToken represents a refresh token issued within a session, only its hash is stored
+type RefreshToken struct {
+	ID        int64
+	SessionID int64
+	TokenHash string
+	CreatedAt time.Time
+	RotatedAt *time.Time // set once the token was exchanged for a new one
 }
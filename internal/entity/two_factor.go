@@ -0,0 +1,27 @@
+package entity
+
+import "time"
+
+// TOTP is the authenticator app secret of a user, two-factor authentication is enabled once it is confirmed
+type TOTP struct {
+	UserID int64
+	Secret string
+	// LastStep is the time step of the last accepted code, codes of this and earlier steps are rejected
+	LastStep    int64
+	CreatedAt   time.Time
+	ConfirmedAt *time.Time
+}
+
+// Enabled reports whether the user confirmed the secret with a code and sign-in requires a second factor
+func (t TOTP) Enabled() bool {
+	return t.ConfirmedAt != nil
+}
+
+// TwoFactorChallenge is the pending second step of a sign-in, only the token hash is stored
+type TwoFactorChallenge struct {
+	TokenHash string
+	UserID    int64
+	Attempts  int
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
@@ -2,10 +2,60 @@ package entity
 
 import "time"
 
+// Role defines what a user is allowed to do besides managing their own ads
+type Role string
+
+// User roles, moderators can edit, hide and delete any ad, admins can also manage categories and roles
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Valid reports whether the role is known
+func (r Role) Valid() bool {
+	switch r {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// CanModerate reports whether the role may act on ads of other users
+func (r Role) CanModerate() bool {
+	return r == RoleModerator || r == RoleAdmin
+}
+
 // User represents a service's user
 type User struct {
-	ID           int64     `json:"id"`
-	Login        string    `json:"login"`
-	PasswordHash string    `json:"-"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID              int64      `json:"id"`
+	Login           string     `json:"login"`
+	Email           string     `json:"email"` // empty for users registered before emails were collected
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	PasswordHash    string     `json:"-"`
+	Role            Role       `json:"role"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// EmailVerified reports whether the user confirmed their current email
+func (u User) EmailVerified() bool {
+	return u.Email != "" && u.EmailVerifiedAt != nil
+}
+
+// EmailVerification represents a pending confirmation of a user email, only the token hash is stored
+type EmailVerification struct {
+	TokenHash string
+	UserID    int64
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// PasswordReset represents a pending password reset of a user, only the token hash is stored
+type PasswordReset struct {
+	TokenHash string
+	UserID    int64
+	CreatedAt time.Time
+	ExpiresAt time.Time
 }
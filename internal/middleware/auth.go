@@ -3,21 +3,25 @@ package middleware
 
 import (
 	"net/http"
+	"slices"
 	"strings"
 
+	"rest-api-marketplace/internal/entity"
 	"rest-api-marketplace/pkg/auth"
 
 	"github.com/labstack/echo/v4"
 )
 
-// AuthHeader and CtxUserID constants used for JWT authentication and context storage
+// AuthHeader, CtxUserID, CtxUserRole and CtxTokenClaims constants used for JWT authentication and context storage
 const (
-	AuthHeader = "Authorization"
-	CtxUserID  = "user_id"
+	AuthHeader     = "Authorization"
+	CtxUserID      = "user_id"
+	CtxUserRole    = "user_role"
+	CtxTokenClaims = "token_claims"
 )
 
-// JWTAuth enforces JWT authentication and sets user ID in context
-func JWTAuth(tm auth.TokenManager) echo.MiddlewareFunc {
+// JWTAuth enforces JWT authentication, rejects revoked tokens and sets user ID and token claims in context
+func JWTAuth(tm auth.TokenManager, revocations auth.RevocationStore) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			authHeader := c.Request().Header.Get(AuthHeader)
@@ -29,18 +33,27 @@ func JWTAuth(tm auth.TokenManager) echo.MiddlewareFunc {
 			if len(parts) != 2 || parts[0] != "Bearer" {
 				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid auth header format"})
 			}
-			userID, err := tm.ParseJWTToken(parts[1])
+			claims, err := tm.ParseJWTToken(parts[1])
 			if err != nil {
 				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid token"})
 			}
-			c.Set(CtxUserID, userID)
+
+			revoked, err := revocations.IsRevoked(c.Request().Context(), claims)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to check token"})
+			}
+			if revoked {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "token has been revoked"})
+			}
+
+			setClaims(c, claims)
 			return next(c)
 		}
 	}
 }
 
-// JWTOptionalAuth optionally parses JWT token if provided
-func JWTOptionalAuth(tm auth.TokenManager) echo.MiddlewareFunc {
+// JWTOptionalAuth optionally parses JWT token if provided, revoked tokens are treated as absent
+func JWTOptionalAuth(tm auth.TokenManager, revocations auth.RevocationStore) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			authHeader := c.Request().Header.Get(AuthHeader)
@@ -53,12 +66,44 @@ func JWTOptionalAuth(tm auth.TokenManager) echo.MiddlewareFunc {
 				return next(c)
 			}
 
-			userID, err := tm.ParseJWTToken(parts[1])
-			if err == nil {
-				c.Set(CtxUserID, userID)
+			claims, err := tm.ParseJWTToken(parts[1])
+			if err != nil {
+				return next(c)
+			}
+
+			if revoked, err := revocations.IsRevoked(c.Request().Context(), claims); err == nil && !revoked {
+				setClaims(c, claims)
 			}
 
 			return next(c)
 		}
 	}
 }
+
+// RequireRole allows the request only if the authenticated user has one of the roles, it must follow JWTAuth
+func RequireRole(roles ...entity.Role) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role, ok := c.Get(CtxUserRole).(entity.Role)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user context"})
+			}
+			if !slices.Contains(roles, role) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "not enough rights"})
+			}
+			return next(c)
+		}
+	}
+}
+
+// setClaims stores the user ID, role and claims of a valid token in context, tokens without role belong to regular users
+func setClaims(c echo.Context, claims *auth.TokenClaims) {
+	role := entity.Role(claims.Role)
+	if !role.Valid() {
+		role = entity.RoleUser
+	}
+
+	c.Set(CtxUserID, claims.UserID)
+	c.Set(CtxUserRole, role)
+	c.Set(CtxTokenClaims, claims)
+}
@@ -5,8 +5,12 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/pkg/money"
+
+	"github.com/lib/pq"
 )
 
 // AdsRepo provides DB operations for ads
@@ -23,11 +27,14 @@ func NewAdsRepo(db *sql.DB) *AdsRepo {
 func (r AdsRepo) Create(ctx context.Context, ad entity.Ad) (int64, error) {
 	const op = "repository.AdsRepo.Create"
 
-	query := `INSERT INTO ads (user_id, title, description, image_url, price) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	query := `INSERT INTO ads (user_id, category_id, title, description, image_url, price, currency, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
 
 	var id int64
-	err := r.db.QueryRowContext(ctx, query, ad.UserID, ad.Title, ad.Description, ad.ImageURL, ad.Price).Scan(&id)
+	err := r.db.QueryRowContext(ctx, query, ad.UserID, ad.CategoryID, ad.Title, ad.Description, ad.ImageURL, ad.Price.Amount, ad.Price.Currency, ad.Status).Scan(&id)
 	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return 0, fmt.Errorf("%s: %w", op, entity.ErrCategoryNotFound)
+		}
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -38,10 +45,13 @@ func (r AdsRepo) Create(ctx context.Context, ad entity.Ad) (int64, error) {
 func (r AdsRepo) Update(ctx context.Context, id int64, ad entity.Ad) error {
 	const op = "repository.AdsRepo.Update"
 
-	query := `UPDATE ads SET title = $1, description = $2, image_url = $3, price = $4 WHERE id = $5`
+	query := `UPDATE ads SET category_id = $1, title = $2, description = $3, image_url = $4, price = $5, currency = $6 WHERE id = $7 AND deleted_at IS NULL`
 
-	res, err := r.db.ExecContext(ctx, query, ad.Title, ad.Description, ad.ImageURL, ad.Price, id)
+	res, err := r.db.ExecContext(ctx, query, ad.CategoryID, ad.Title, ad.Description, ad.ImageURL, ad.Price.Amount, ad.Price.Currency, id)
 	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, entity.ErrCategoryNotFound)
+		}
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -60,11 +70,11 @@ func (r AdsRepo) Update(ctx context.Context, id int64, ad entity.Ad) error {
 func (r AdsRepo) GetByID(ctx context.Context, id int64) (*entity.Ad, error) {
 	const op = "repository.AdsRepo.GetById"
 
-	query := `SELECT id, user_id, title, description, image_url, price, created_at FROM ads WHERE id = $1`
+	query := `SELECT id, user_id, category_id, title, description, image_url, price, currency, status, created_at FROM ads WHERE id = $1 AND deleted_at IS NULL`
 
 	var ad entity.Ad
 
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&ad.ID, &ad.UserID, &ad.Title, &ad.Description, &ad.ImageURL, &ad.Price, &ad.CreatedAt)
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&ad.ID, &ad.UserID, &ad.CategoryID, &ad.Title, &ad.Description, &ad.ImageURL, &ad.Price.Amount, &ad.Price.Currency, &ad.Status, &ad.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("%s: %w", op, entity.ErrAdNotFound)
@@ -75,25 +85,31 @@ func (r AdsRepo) GetByID(ctx context.Context, id int64) (*entity.Ad, error) {
 	return &ad, nil
 }
 
-// GetByIDWithAuthor retrieves an ad with author info
-func (r AdsRepo) GetByIDWithAuthor(ctx context.Context, id int64) (*entity.AdWithAuthor, error) {
+// GetByIDWithAuthor retrieves an ad with author info and its price converted to the currency
+func (r AdsRepo) GetByIDWithAuthor(ctx context.Context, id int64, currency money.Currency) (*entity.AdWithAuthor, error) {
 	const op = "repository.AdsRepo.GetByIdWithAuthor"
 
-	query := `SELECT a.id, a.user_id, a.title, a.description, a.image_url, a.price, a.created_at, u.login
+	query := fmt.Sprintf(`SELECT a.id, a.user_id, a.category_id, a.title, a.description, a.image_url, a.price, a.currency, a.status, a.created_at, u.login,
+			  %s
 			  FROM ads a
-			  JOIN users u ON a.user_id = u.id
-			  WHERE a.id = $1`
+			  JOIN users u ON a.user_id = u.id%s
+			  WHERE a.id = $1 AND a.deleted_at IS NULL`, convertedPriceSQL(2), rateJoinsSQL(2))
 
 	var ad entity.AdWithAuthor
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	var converted sql.NullInt64
+	err := r.db.QueryRowContext(ctx, query, id, currency).Scan(
 		&ad.ID,
 		&ad.UserID,
+		&ad.CategoryID,
 		&ad.Title,
 		&ad.Description,
 		&ad.ImageURL,
-		&ad.Price,
+		&ad.Price.Amount,
+		&ad.Price.Currency,
+		&ad.Status,
 		&ad.CreatedAt,
 		&ad.AuthorLogin,
+		&converted,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -101,65 +117,186 @@ func (r AdsRepo) GetByIDWithAuthor(ctx context.Context, id int64) (*entity.AdWit
 		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	if converted.Valid {
+		convertedPrice := money.New(converted.Int64, currency)
+		ad.ConvertedPrice = &convertedPrice
+	}
 
 	return &ad, nil
 }
 
-// GetAll returns a list of ads with optional filters, sorting, and pagination
-func (r AdsRepo) GetAll(ctx context.Context, params entity.GetAdsQuery) ([]entity.AdWithAuthor, error) {
-	const op = "repository.AdsRepo.GetAll"
+// adsFilter holds the joins and WHERE conditions shared by listing and counting ads
+type adsFilter struct {
+	rateJoins     string
+	priceExpr     string
+	convertedExpr string
+	filters       []string
+	args          []interface{}
+	argID         int
+	searchArgID   int
+}
 
-	baseQuery := `
-    SELECT a.id, a.user_id, a.title, a.description, a.image_url, a.price, a.created_at, u.login
-    FROM ads a
-    JOIN users u ON a.user_id = u.id
-  `
+// buildAdsFilter translates query parameters except pagination into SQL conditions and their arguments
+func buildAdsFilter(params entity.GetAdsQuery) adsFilter {
+	f := adsFilter{
+		priceExpr:     "a.price",
+		convertedExpr: "NULL::BIGINT",
+		filters:       []string{"a.deleted_at IS NULL"},
+		argID:         1,
+	}
 
-	var filters []string
-	var args []interface{}
-	argID := 1
+	// prices are compared and sorted in the requested currency, ads without a known rate have no converted price
+	if params.Currency != "" {
+		f.rateJoins = rateJoinsSQL(1)
+		f.convertedExpr = convertedPriceSQL(1)
+		f.priceExpr = f.convertedExpr
+		f.add("", params.Currency)
+	}
 
-	if params.MinPrice > 0 {
-		filters = append(filters, fmt.Sprintf("a.price >= $%d", argID))
-		args = append(args, params.MinPrice)
-		argID++
+	if params.Status != "" {
+		f.add("a.status = $%d", params.Status)
+	}
+	if params.UserID > 0 {
+		f.add("a.user_id = $%d", params.UserID)
+	}
+
+	if params.MinPrice != nil {
+		f.add(f.priceExpr+" >= $%d", params.MinPrice.Amount)
+	}
+	if params.MaxPrice != nil {
+		f.add(f.priceExpr+" <= $%d", params.MaxPrice.Amount)
+	}
+	if params.CategoryID > 0 {
+		f.add(`a.category_id IN (
+			WITH RECURSIVE subtree AS (
+				SELECT id FROM categories WHERE id = $%d
+				UNION ALL
+				SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+			)
+			SELECT id FROM subtree
+		)`, params.CategoryID)
 	}
-	if params.MaxPrice > 0 {
-		filters = append(filters, fmt.Sprintf("a.price <= $%d", argID))
-		args = append(args, params.MaxPrice)
-		argID++
+	if params.Search != "" {
+		f.searchArgID = f.argID
+		f.add("a.search_vector @@ websearch_to_tsquery('simple', $%d)", params.Search)
 	}
 
-	if len(filters) > 0 {
-		baseQuery += " WHERE " + strings.Join(filters, " AND ")
+	return f
+}
+
+// rateJoinsSQL joins the exchange rates of the ad currency as ra and of the currency in the placeholder as rt
+func rateJoinsSQL(currencyArgID int) string {
+	return fmt.Sprintf(`
+    LEFT JOIN exchange_rates ra ON ra.currency = a.currency
+    LEFT JOIN exchange_rates rt ON rt.currency = $%d`, currencyArgID)
+}
+
+// convertedPriceSQL converts the ad price to the currency in the placeholder using the rates of rateJoinsSQL.
+// The conversion is NULL when a rate is unknown or the amount does not fit into BIGINT, e.g. a huge price
+// in a currency with few minor units converted to one with a much smaller unit
+func convertedPriceSQL(currencyArgID int) string {
+	converted := "ROUND(a.price * rt.rate / ra.rate * power(10::NUMERIC, rt.minor_units - ra.minor_units))"
+	return fmt.Sprintf(`CASE WHEN a.currency = $%[1]d THEN a.price
+        WHEN %[2]s BETWEEN -9223372036854775808 AND 9223372036854775807 THEN %[2]s::BIGINT END`, currencyArgID, converted)
+}
+
+// add appends a condition referencing the next placeholder, an empty condition only binds the argument
+func (f *adsFilter) add(condition string, arg interface{}) {
+	if condition != "" {
+		f.filters = append(f.filters, fmt.Sprintf(condition, f.argID))
+	}
+	f.args = append(f.args, arg)
+	f.argID++
+}
+
+// Count returns the number of ads matching the same filters GetAll uses, pagination is ignored
+func (r AdsRepo) Count(ctx context.Context, params entity.GetAdsQuery) (int64, error) {
+	const op = "repository.AdsRepo.Count"
+
+	f := buildAdsFilter(params)
+
+	query := fmt.Sprintf(`
+    SELECT COUNT(*)
+    FROM ads a
+    JOIN users u ON a.user_id = u.id%s
+    WHERE %s`, f.rateJoins, strings.Join(f.filters, " AND "))
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, query, f.args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	orderBy := "a.created_at"
+	return total, nil
+}
+
+// GetAll returns a page of ads with optional filters and sorting, paginated either by offset or after a cursor.
+// Ads are always ordered by ID after the sort key, so the order is stable and the cursor to the next page is returned
+func (r AdsRepo) GetAll(ctx context.Context, params entity.GetAdsQuery) ([]entity.AdWithAuthor, *entity.AdsCursor, error) {
+	const op = "repository.AdsRepo.GetAll"
+
+	f := buildAdsFilter(params)
+	filters, args, argID := f.filters, f.args, f.argID
+	priceExpr, searchArgID := f.priceExpr, f.searchArgID
+
+	// sortKey is the expression ads are ordered by, keyType is used to cast the cursor key back
+	sortKey, keyType, nullable := "a.created_at", "TIMESTAMPTZ", false
 	switch params.SortBy {
 	case "price":
-		orderBy = "a.price"
-	case "date":
-		orderBy = "a.created_at"
+		sortKey, keyType, nullable = priceExpr, "BIGINT", params.Currency != ""
+	case "relevance":
+		if searchArgID > 0 {
+			sortKey, keyType = fmt.Sprintf("ts_rank(a.search_vector, websearch_to_tsquery('simple', $%d))", searchArgID), "REAL"
+		}
 	}
 
-	orderDirection := "DESC"
+	orderDirection, cmp := "DESC", "<"
 	if strings.ToUpper(params.SortDir) == "ASC" {
-		orderDirection = "ASC"
+		orderDirection, cmp = "ASC", ">"
 	}
 
-	baseQuery += fmt.Sprintf(" ORDER BY %s %s", orderBy, orderDirection)
+	if after := params.After; after != nil {
+		// ads without a sort key go last, so after such an ad only ads without a key are left
+		switch {
+		case after.Key == nil && nullable:
+			filters = append(filters, fmt.Sprintf("(%s IS NULL AND a.id %s $%d)", sortKey, cmp, argID))
+			args = append(args, after.ID)
+			argID++
+		case after.Key == nil:
+			return nil, nil, fmt.Errorf("%s: %w", op, entity.ErrInvalidCursor)
+		default:
+			keyset := fmt.Sprintf("(%s, a.id) %s ($%d::%s, $%d::BIGINT)", sortKey, cmp, argID, keyType, argID+1)
+			if nullable {
+				keyset = fmt.Sprintf("(%s OR %s IS NULL)", keyset, sortKey)
+			}
+			filters = append(filters, keyset)
+			args = append(args, *after.Key, after.ID)
+			argID += 2
+		}
+	}
+
+	baseQuery := fmt.Sprintf(`
+    SELECT a.id, a.user_id, a.category_id, a.title, a.description, a.image_url, a.price, a.currency, a.status, a.created_at, u.login,
+        %s, (%s)::TEXT
+    FROM ads a
+    JOIN users u ON a.user_id = u.id%s
+  `, f.convertedExpr, sortKey, f.rateJoins)
+
+	baseQuery += " WHERE " + strings.Join(filters, " AND ")
+
+	baseQuery += fmt.Sprintf(" ORDER BY %s %s NULLS LAST, a.id %s", sortKey, orderDirection, orderDirection)
 
 	limit := 10
 	if params.Limit > 0 {
 		limit = params.Limit
 	}
 
+	// one extra row tells whether there is a next page
 	baseQuery += fmt.Sprintf(" LIMIT $%d", argID)
-	args = append(args, limit)
+	args = append(args, limit+1)
 	argID++
 
 	offset := 0
-	if params.Page > 1 {
+	if params.Page > 1 && params.After == nil {
 		offset = (params.Page - 1) * limit
 	}
 	baseQuery += fmt.Sprintf(" OFFSET $%d", argID)
@@ -167,38 +304,114 @@ func (r AdsRepo) GetAll(ctx context.Context, params entity.GetAdsQuery) ([]entit
 
 	rows, err := r.db.QueryContext(ctx, baseQuery, args...)
 	if err != nil {
-		return nil, fmt.Errorf("%s: query execution: %w", op, err)
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code.Class() == "22" && params.After != nil {
+			return nil, nil, fmt.Errorf("%s: %w", op, entity.ErrInvalidCursor)
+		}
+		return nil, nil, fmt.Errorf("%s: query execution: %w", op, err)
 	}
 	defer func(rows *sql.Rows) {
 		_ = rows.Close()
 	}(rows)
 
 	var ads []entity.AdWithAuthor
+	var keys []*string
 	for rows.Next() {
 		var ad entity.AdWithAuthor
+		var converted sql.NullInt64
+		var key sql.NullString
 		if err := rows.Scan(
 			&ad.ID,
 			&ad.UserID,
+			&ad.CategoryID,
 			&ad.Title,
 			&ad.Description,
 			&ad.ImageURL,
-			&ad.Price,
+			&ad.Price.Amount,
+			&ad.Price.Currency,
+			&ad.Status,
 			&ad.CreatedAt,
 			&ad.AuthorLogin,
+			&converted,
+			&key,
 		); err != nil {
-			return nil, fmt.Errorf("%s: row scan: %w", op, err)
+			return nil, nil, fmt.Errorf("%s: row scan: %w", op, err)
+		}
+		if converted.Valid {
+			convertedPrice := money.New(converted.Int64, params.Currency)
+			ad.ConvertedPrice = &convertedPrice
 		}
 		ads = append(ads, ad)
+		if key.Valid {
+			keys = append(keys, &key.String)
+		} else {
+			keys = append(keys, nil)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("%s: rows iteration: %w", op, err)
+	}
+
+	if len(ads) <= limit {
+		return ads, nil, nil
+	}
+
+	ads = ads[:limit]
+	next := &entity.AdsCursor{
+		SortBy:  params.SortBy,
+		SortDir: strings.ToLower(orderDirection),
+		Key:     keys[limit-1],
+		ID:      ads[limit-1].ID,
+	}
+
+	return ads, next, nil
+}
+
+// UpdateStatus moves an ad from one status to another, failing if its status was changed concurrently
+func (r AdsRepo) UpdateStatus(ctx context.Context, id int64, from, to entity.AdStatus) error {
+	const op = "repository.AdsRepo.UpdateStatus"
+
+	query := `UPDATE ads SET status = $1 WHERE id = $2 AND status = $3 AND deleted_at IS NULL`
+
+	res, err := r.db.ExecContext(ctx, query, to, id, from)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrInvalidAdTransition)
 	}
 
-	return ads, nil
+	return nil
 }
 
-// Delete removes an ad by its ID
+// GetDeletedByID retrieves a soft-deleted ad by its ID
+func (r AdsRepo) GetDeletedByID(ctx context.Context, id int64) (*entity.Ad, error) {
+	const op = "repository.AdsRepo.GetDeletedByID"
+
+	query := `SELECT id, user_id, category_id, title, description, image_url, price, currency, status, created_at, deleted_at FROM ads WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	var ad entity.Ad
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&ad.ID, &ad.UserID, &ad.CategoryID, &ad.Title, &ad.Description, &ad.ImageURL, &ad.Price.Amount, &ad.Price.Currency, &ad.Status, &ad.CreatedAt, &ad.DeletedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%s: %w", op, entity.ErrAdNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &ad, nil
+}
+
+// Delete marks an ad as deleted, the row is kept until it is purged
 func (r AdsRepo) Delete(ctx context.Context, id int64) error {
 	const op = "repository.AdsRepo.Delete"
 
-	query := `DELETE FROM ads WHERE id = $1`
+	query := `UPDATE ads SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
 
 	res, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
@@ -216,3 +429,45 @@ func (r AdsRepo) Delete(ctx context.Context, id int64) error {
 
 	return nil
 }
+
+// Restore clears the deletion mark of an ad deleted after the given time
+func (r AdsRepo) Restore(ctx context.Context, id int64, deletedAfter time.Time) error {
+	const op = "repository.AdsRepo.Restore"
+
+	query := `UPDATE ads SET deleted_at = NULL WHERE id = $1 AND deleted_at > $2`
+
+	res, err := r.db.ExecContext(ctx, query, id, deletedAfter)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrAdNotFound)
+	}
+
+	return nil
+}
+
+// PurgeDeleted permanently removes ads deleted before the given time and returns their number
+func (r AdsRepo) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	const op = "repository.AdsRepo.PurgeDeleted"
+
+	query := `DELETE FROM ads WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+
+	res, err := r.db.ExecContext(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+
+	return rowsAffected, nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"rest-api-marketplace/internal/entity"
+)
+
+// AuditLogRepo provides DB operations for the audit log of privileged actions
+type AuditLogRepo struct {
+	db *sql.DB
+}
+
+// NewAuditLogRepo creates a new AuditLogRepo instance
+func NewAuditLogRepo(db *sql.DB) *AuditLogRepo {
+	return &AuditLogRepo{db: db}
+}
+
+// Create appends an entry to the audit log, a zero actor ID is stored as NULL for actions done outside the API
+func (r *AuditLogRepo) Create(ctx context.Context, entry entity.AuditEntry) error {
+	const op = "repository.AuditLogRepo.Create"
+
+	var details []byte
+	if len(entry.Details) > 0 {
+		var err error
+		details, err = json.Marshal(entry.Details)
+		if err != nil {
+			return fmt.Errorf("%s: marshal details: %w", op, err)
+		}
+	}
+
+	query := `INSERT INTO audit_log (actor_id, actor_role, action, target_type, target_id, details)
+			  VALUES ($1, $2, $3, $4, $5, $6)`
+
+	actorID := sql.NullInt64{Int64: entry.ActorID, Valid: entry.ActorID != 0}
+
+	_, err := r.db.ExecContext(ctx, query, actorID, entry.ActorRole, entry.Action, entry.TargetType, entry.TargetID, details)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"rest-api-marketplace/internal/entity"
+
+	"github.com/lib/pq"
+)
+
+// CategoriesRepo provides DB operations for ad categories
+type CategoriesRepo struct {
+	db *sql.DB
+}
+
+// NewCategoriesRepo creates a new CategoriesRepo instance
+func NewCategoriesRepo(db *sql.DB) *CategoriesRepo {
+	return &CategoriesRepo{db: db}
+}
+
+// Create inserts a new category and returns its ID
+func (r *CategoriesRepo) Create(ctx context.Context, category entity.Category) (int64, error) {
+	const op = "repository.CategoriesRepo.Create"
+
+	query := `INSERT INTO categories (parent_id, name) VALUES ($1, $2) RETURNING id`
+
+	var id int64
+	err := r.db.QueryRowContext(ctx, query, category.ParentID, category.Name).Scan(&id)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return 0, fmt.Errorf("%s: %w", op, entity.ErrParentCategoryNotFound)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Update modifies an existing category
+func (r *CategoriesRepo) Update(ctx context.Context, id int64, category entity.Category) error {
+	const op = "repository.CategoriesRepo.Update"
+
+	query := `UPDATE categories SET parent_id = $1, name = $2 WHERE id = $3`
+
+	res, err := r.db.ExecContext(ctx, query, category.ParentID, category.Name, id)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, entity.ErrParentCategoryNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrCategoryNotFound)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a category by its ID
+func (r *CategoriesRepo) GetByID(ctx context.Context, id int64) (*entity.Category, error) {
+	const op = "repository.CategoriesRepo.GetByID"
+
+	query := `SELECT id, parent_id, name, created_at FROM categories WHERE id = $1`
+
+	var category entity.Category
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&category.ID, &category.ParentID, &category.Name, &category.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, entity.ErrCategoryNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &category, nil
+}
+
+// GetAll returns all categories ordered by name
+func (r *CategoriesRepo) GetAll(ctx context.Context) ([]entity.Category, error) {
+	const op = "repository.CategoriesRepo.GetAll"
+
+	query := `SELECT id, parent_id, name, created_at FROM categories ORDER BY name, id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query execution: %w", op, err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var categories []entity.Category
+	for rows.Next() {
+		var category entity.Category
+		if err := rows.Scan(&category.ID, &category.ParentID, &category.Name, &category.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: row scan: %w", op, err)
+		}
+		categories = append(categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows iteration: %w", op, err)
+	}
+
+	return categories, nil
+}
+
+// Delete removes a category by its ID, ads in this category become uncategorized
+func (r *CategoriesRepo) Delete(ctx context.Context, id int64) error {
+	const op = "repository.CategoriesRepo.Delete"
+
+	query := `DELETE FROM categories WHERE id = $1`
+
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, entity.ErrCategoryHasChildren)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrCategoryNotFound)
+	}
+
+	return nil
+}
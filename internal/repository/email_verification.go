@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"rest-api-marketplace/internal/entity"
+)
+
+// EmailVerificationsRepo provides DB operations for email verification tokens
+type EmailVerificationsRepo struct {
+	db *sql.DB
+}
+
+// NewEmailVerificationsRepo creates a new EmailVerificationsRepo instance
+func NewEmailVerificationsRepo(db *sql.DB) *EmailVerificationsRepo {
+	return &EmailVerificationsRepo{db: db}
+}
+
+// Create stores a verification token, earlier tokens of the user stop working
+func (r *EmailVerificationsRepo) Create(ctx context.Context, verification entity.EmailVerification) error {
+	const op = "repository.EmailVerificationsRepo.Create"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM email_verifications WHERE user_id = $1`, verification.UserID); err != nil {
+		return fmt.Errorf("%s: delete previous tokens: %w", op, err)
+	}
+
+	query := `INSERT INTO email_verifications (token_hash, user_id, email, expires_at) VALUES ($1, $2, $3, $4)`
+
+	if _, err := tx.ExecContext(ctx, query, verification.TokenHash, verification.UserID, verification.Email, verification.ExpiresAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+
+	return nil
+}
+
+// Redeem marks the email the token was issued for as verified and returns the user ID.
+// It fails with ErrInvalidVerificationToken if the token is unknown, expired or the user changed the email since
+func (r *EmailVerificationsRepo) Redeem(ctx context.Context, tokenHash string) (int64, error) {
+	const op = "repository.EmailVerificationsRepo.Redeem"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `DELETE FROM email_verifications WHERE token_hash = $1 AND expires_at > NOW() RETURNING user_id, email`
+
+	var verification entity.EmailVerification
+	err = tx.QueryRowContext(ctx, query, tokenHash).Scan(&verification.UserID, &verification.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, entity.ErrInvalidVerificationToken)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `UPDATE users SET email_verified_at = NOW() WHERE id = $1 AND email = $2`,
+		verification.UserID, verification.Email)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return 0, fmt.Errorf("%s: %w", op, entity.ErrInvalidVerificationToken)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+
+	return verification.UserID, nil
+}
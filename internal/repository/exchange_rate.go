@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"rest-api-marketplace/internal/entity"
+)
+
+// ExchangeRatesRepo provides DB operations for currency exchange rates
+type ExchangeRatesRepo struct {
+	db *sql.DB
+}
+
+// NewExchangeRatesRepo creates a new ExchangeRatesRepo instance
+func NewExchangeRatesRepo(db *sql.DB) *ExchangeRatesRepo {
+	return &ExchangeRatesRepo{db: db}
+}
+
+// Upsert inserts or replaces the given rates in a single transaction
+func (r *ExchangeRatesRepo) Upsert(ctx context.Context, rates []entity.ExchangeRate) error {
+	const op = "repository.ExchangeRatesRepo.Upsert"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `INSERT INTO exchange_rates (currency, rate, minor_units, updated_at)
+			  VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+			  ON CONFLICT (currency) DO UPDATE
+			  SET rate = EXCLUDED.rate, minor_units = EXCLUDED.minor_units, updated_at = EXCLUDED.updated_at`
+
+	for _, rate := range rates {
+		if _, err := tx.ExecContext(ctx, query, rate.Currency, rate.Rate, rate.MinorUnits); err != nil {
+			return fmt.Errorf("%s: upsert %s: %w", op, rate.Currency, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetAll returns all exchange rates ordered by currency
+func (r *ExchangeRatesRepo) GetAll(ctx context.Context) ([]entity.ExchangeRate, error) {
+	const op = "repository.ExchangeRatesRepo.GetAll"
+
+	query := `SELECT currency, rtrim(rtrim(rate::TEXT, '0'), '.'), minor_units, updated_at FROM exchange_rates ORDER BY currency`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query execution: %w", op, err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var rates []entity.ExchangeRate
+	for rows.Next() {
+		var rate entity.ExchangeRate
+		if err := rows.Scan(&rate.Currency, &rate.Rate, &rate.MinorUnits, &rate.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("%s: row scan: %w", op, err)
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows iteration: %w", op, err)
+	}
+
+	return rates, nil
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"rest-api-marketplace/internal/entity"
+
+	"github.com/lib/pq"
+)
+
+// identity unique constraints, their violations tell why an identity can't be linked
+const (
+	identitySubjectConstraint  = "user_identities_provider_subject_key"
+	identityProviderConstraint = "user_identities_user_id_provider_key"
+)
+
+// IdentitiesRepo provides DB operations for external identities linked to users
+type IdentitiesRepo struct {
+	db *sql.DB
+}
+
+// NewIdentitiesRepo creates a new IdentitiesRepo instance
+func NewIdentitiesRepo(db *sql.DB) *IdentitiesRepo {
+	return &IdentitiesRepo{db: db}
+}
+
+// Create links an identity to its user and returns its ID
+func (r *IdentitiesRepo) Create(ctx context.Context, identity entity.Identity) (int64, error) {
+	const op = "repository.IdentitiesRepo.Create"
+
+	id, err := insertIdentity(ctx, r.db, identity)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// CreateWithUser registers a new user signed up with an identity and links the identity to them,
+// it returns the ID of the user
+func (r *IdentitiesRepo) CreateWithUser(ctx context.Context, user entity.User, identity entity.Identity) (int64, error) {
+	const op = "repository.IdentitiesRepo.CreateWithUser"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `INSERT INTO users (login, email, email_verified_at, password_hash) VALUES ($1, NULLIF($2, ''), $3, $4) RETURNING id`
+
+	var userID int64
+	err = tx.QueryRowContext(ctx, query, user.Login, user.Email, user.EmailVerifiedAt, user.PasswordHash).Scan(&userID)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			if pgErr.Constraint == emailIndex {
+				return 0, fmt.Errorf("%s: %w", op, entity.ErrEmailTaken)
+			}
+			return 0, fmt.Errorf("%s: %w", op, entity.ErrUserExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	identity.UserID = userID
+	if _, err := insertIdentity(ctx, tx, identity); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+
+	return userID, nil
+}
+
+// GetBySubject retrieves the identity of a provider account
+func (r *IdentitiesRepo) GetBySubject(ctx context.Context, provider, subject string) (*entity.Identity, error) {
+	const op = "repository.IdentitiesRepo.GetBySubject"
+
+	query := `SELECT id, user_id, provider, subject, COALESCE(email, ''), created_at
+			  FROM user_identities
+			  WHERE provider = $1 AND subject = $2`
+
+	var identity entity.Identity
+	err := r.db.QueryRowContext(ctx, query, provider, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, entity.ErrIdentityNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &identity, nil
+}
+
+// GetByUserID returns the identities linked to the user
+func (r *IdentitiesRepo) GetByUserID(ctx context.Context, userID int64) ([]entity.Identity, error) {
+	const op = "repository.IdentitiesRepo.GetByUserID"
+
+	query := `SELECT id, user_id, provider, subject, COALESCE(email, ''), created_at
+			  FROM user_identities
+			  WHERE user_id = $1
+			  ORDER BY created_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query execution: %w", op, err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var identities []entity.Identity
+	for rows.Next() {
+		var identity entity.Identity
+		if err := rows.Scan(
+			&identity.ID,
+			&identity.UserID,
+			&identity.Provider,
+			&identity.Subject,
+			&identity.Email,
+			&identity.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("%s: row scan: %w", op, err)
+		}
+		identities = append(identities, identity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows iteration: %w", op, err)
+	}
+
+	return identities, nil
+}
+
+// Delete unlinks an identity of the user. The last identity of a user without a password is kept,
+// it is their only way to sign in. The user row is locked while checking, so concurrent unlinks
+// or a password change can't leave the user locked out in between
+func (r *IdentitiesRepo) Delete(ctx context.Context, id, userID int64) error {
+	const op = "repository.IdentitiesRepo.Delete"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var hasPassword bool
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(password_hash, '') <> '' FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&hasPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, entity.ErrUserNotFound)
+		}
+		return fmt.Errorf("%s: lock user: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM user_identities WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrIdentityNotFound)
+	}
+
+	if !hasPassword {
+		var remaining int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM user_identities WHERE user_id = $1`, userID).Scan(&remaining); err != nil {
+			return fmt.Errorf("%s: count identities: %w", op, err)
+		}
+		if remaining == 0 {
+			return fmt.Errorf("%s: %w", op, entity.ErrLastSignInMethod)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+
+	return nil
+}
+
+// rowQuerier is satisfied by both *sql.DB and *sql.Tx
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insertIdentity inserts an identity and maps unique violations to identity errors
+func insertIdentity(ctx context.Context, db rowQuerier, identity entity.Identity) (int64, error) {
+	query := `INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, NULLIF($4, '')) RETURNING id`
+
+	var id int64
+	err := db.QueryRowContext(ctx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email).Scan(&id)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			switch pgErr.Constraint {
+			case identitySubjectConstraint:
+				return 0, entity.ErrIdentityLinked
+			case identityProviderConstraint:
+				return 0, entity.ErrProviderLinked
+			}
+		}
+		return 0, err
+	}
+
+	return id, nil
+}
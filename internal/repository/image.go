@@ -0,0 +1,421 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"rest-api-marketplace/internal/entity"
+
+	"github.com/lib/pq"
+)
+
+// AdImagesRepo provides DB operations for ad images
+type AdImagesRepo struct {
+	db *sql.DB
+}
+
+// NewAdImagesRepo creates a new AdImagesRepo instance
+func NewAdImagesRepo(db *sql.DB) *AdImagesRepo {
+	return &AdImagesRepo{db: db}
+}
+
+// coverIndex is the unique index allowing a single cover image per ad
+const coverIndex = "idx_ad_images_cover"
+
+// createImageAttempts is how many times Create inserts an image that lost the race for the cover
+const createImageAttempts = 3
+
+// isCoverConflict reports whether the error is a violation of the single cover index by a concurrent change of the gallery
+func isCoverConflict(pgErr *pq.Error) bool {
+	return pgErr.Code == "23505" && pgErr.Constraint == coverIndex
+}
+
+// Create appends a new image to the end of the ad gallery, the first image becomes the cover.
+// When concurrent uploads both try to become the cover, the losing insert is retried and sees the cover already taken
+func (r *AdImagesRepo) Create(ctx context.Context, image entity.AdImage) (*entity.AdImage, error) {
+	const op = "repository.AdImagesRepo.Create"
+
+	query := `INSERT INTO ad_images (ad_id, storage_key, content_type, size, position, is_cover)
+			  SELECT $1::BIGINT, $2::VARCHAR, $3::VARCHAR, $4::BIGINT,
+			         COALESCE(MAX(position) + 1, 0),
+			         COUNT(*) FILTER (WHERE is_cover) = 0
+			  FROM ad_images
+			  WHERE ad_id = $1
+			  RETURNING id, position, is_cover, created_at`
+
+	for attempt := 1; ; attempt++ {
+		err := r.db.QueryRowContext(ctx, query, image.AdID, image.StorageKey, image.ContentType, image.Size).
+			Scan(&image.ID, &image.Position, &image.IsCover, &image.CreatedAt)
+		if err == nil {
+			return &image, nil
+		}
+
+		if pgErr, ok := err.(*pq.Error); ok {
+			switch {
+			case pgErr.Code == "23503":
+				return nil, fmt.Errorf("%s: %w", op, entity.ErrAdNotFound)
+			case isCoverConflict(pgErr):
+				if attempt < createImageAttempts {
+					continue
+				}
+				return nil, fmt.Errorf("%s: %w", op, entity.ErrGalleryConflict)
+			}
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+}
+
+// GetByAdIDs returns images of the given ads in gallery order with a single query
+func (r *AdImagesRepo) GetByAdIDs(ctx context.Context, adIDs []int64) ([]entity.AdImage, error) {
+	const op = "repository.AdImagesRepo.GetByAdIDs"
+
+	query := `SELECT id, ad_id, storage_key, content_type, size, position, is_cover, created_at
+			  FROM ad_images
+			  WHERE ad_id = ANY($1)
+			  ORDER BY ad_id, position, id`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(adIDs))
+	if err != nil {
+		return nil, fmt.Errorf("%s: query execution: %w", op, err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var (
+		images   []entity.AdImage
+		imageIDs []int64
+	)
+	for rows.Next() {
+		var image entity.AdImage
+		if err := rows.Scan(&image.ID, &image.AdID, &image.StorageKey, &image.ContentType, &image.Size, &image.Position, &image.IsCover, &image.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: row scan: %w", op, err)
+		}
+		images = append(images, image)
+		imageIDs = append(imageIDs, image.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows iteration: %w", op, err)
+	}
+
+	if len(images) == 0 {
+		return images, nil
+	}
+
+	variants, err := r.getVariants(ctx, imageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for i := range images {
+		images[i].Variants = variants[images[i].ID]
+	}
+
+	return images, nil
+}
+
+// GetByID retrieves an image by its ID
+func (r *AdImagesRepo) GetByID(ctx context.Context, id int64) (*entity.AdImage, error) {
+	const op = "repository.AdImagesRepo.GetByID"
+
+	query := `SELECT id, ad_id, storage_key, content_type, size, position, is_cover, created_at FROM ad_images WHERE id = $1`
+
+	var image entity.AdImage
+	err := r.db.QueryRowContext(ctx, query, id).
+		Scan(&image.ID, &image.AdID, &image.StorageKey, &image.ContentType, &image.Size, &image.Position, &image.IsCover, &image.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, entity.ErrImageNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &image, nil
+}
+
+// GetPendingVariants returns IDs of images whose variants have not been generated yet and didn't fail
+func (r *AdImagesRepo) GetPendingVariants(ctx context.Context, limit int) ([]int64, error) {
+	const op = "repository.AdImagesRepo.GetPendingVariants"
+
+	query := `SELECT id FROM ad_images WHERE variants_generated_at IS NULL AND variants_failed_at IS NULL ORDER BY id LIMIT $1`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query execution: %w", op, err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%s: row scan: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows iteration: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+// SaveVariants stores generated variants of an image and marks the image as processed
+func (r *AdImagesRepo) SaveVariants(ctx context.Context, imageID int64, variants []entity.AdImageVariant) error {
+	const op = "repository.AdImagesRepo.SaveVariants"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `INSERT INTO ad_image_variants (image_id, size, width, height, storage_key)
+			  VALUES ($1, $2, $3, $4, $5)
+			  ON CONFLICT (image_id, size) DO UPDATE
+			  SET width = EXCLUDED.width, height = EXCLUDED.height, storage_key = EXCLUDED.storage_key`
+
+	for _, v := range variants {
+		if _, err := tx.ExecContext(ctx, query, imageID, v.Size, v.Width, v.Height, v.StorageKey); err != nil {
+			if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+				return fmt.Errorf("%s: %w", op, entity.ErrImageNotFound)
+			}
+			return fmt.Errorf("%s: insert variant: %w", op, err)
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `UPDATE ad_images SET variants_generated_at = NOW() WHERE id = $1`, imageID)
+	if err != nil {
+		return fmt.Errorf("%s: mark image: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrImageNotFound)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+	return nil
+}
+
+// MarkVariantsFailed records that variants of the image can never be generated, so it is no longer pending
+func (r *AdImagesRepo) MarkVariantsFailed(ctx context.Context, imageID int64) error {
+	const op = "repository.AdImagesRepo.MarkVariantsFailed"
+
+	res, err := r.db.ExecContext(ctx, `UPDATE ad_images SET variants_failed_at = NOW() WHERE id = $1`, imageID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrImageNotFound)
+	}
+
+	return nil
+}
+
+// getVariants loads variants of the given images keyed by image ID
+func (r *AdImagesRepo) getVariants(ctx context.Context, imageIDs []int64) (map[int64][]entity.AdImageVariant, error) {
+	query := `SELECT image_id, size, width, height, storage_key
+			  FROM ad_image_variants
+			  WHERE image_id = ANY($1)
+			  ORDER BY image_id, size`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(imageIDs))
+	if err != nil {
+		return nil, fmt.Errorf("variants query execution: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	variants := make(map[int64][]entity.AdImageVariant)
+	for rows.Next() {
+		var v entity.AdImageVariant
+		if err := rows.Scan(&v.ImageID, &v.Size, &v.Width, &v.Height, &v.StorageKey); err != nil {
+			return nil, fmt.Errorf("variant row scan: %w", err)
+		}
+		variants[v.ImageID] = append(variants[v.ImageID], v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("variant rows iteration: %w", err)
+	}
+
+	return variants, nil
+}
+
+// Reorder sets gallery positions of the ad images according to the order of imageIDs
+func (r *AdImagesRepo) Reorder(ctx context.Context, adID int64, imageIDs []int64) error {
+	const op = "repository.AdImagesRepo.Reorder"
+
+	query := `UPDATE ad_images i
+			  SET position = o.position - 1
+			  FROM unnest($2::BIGINT[]) WITH ORDINALITY AS o(id, position)
+			  WHERE i.id = o.id AND i.ad_id = $1`
+
+	res, err := r.db.ExecContext(ctx, query, adID, pq.Array(imageIDs))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected != int64(len(imageIDs)) {
+		return fmt.Errorf("%s: %w", op, entity.ErrImageNotFound)
+	}
+
+	return nil
+}
+
+// SetCover makes the image the only cover of the ad gallery
+func (r *AdImagesRepo) SetCover(ctx context.Context, adID, imageID int64) error {
+	const op = "repository.AdImagesRepo.SetCover"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE ad_images SET is_cover = FALSE WHERE ad_id = $1 AND is_cover`, adID); err != nil {
+		return fmt.Errorf("%s: reset cover: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `UPDATE ad_images SET is_cover = TRUE WHERE id = $1 AND ad_id = $2`, imageID, adID)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && isCoverConflict(pgErr) {
+			return fmt.Errorf("%s: %w", op, entity.ErrGalleryConflict)
+		}
+		return fmt.Errorf("%s: set cover: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrImageNotFound)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+	return nil
+}
+
+// Delete removes the image from the ad gallery and returns storage keys of the image and its variants.
+// Remaining images are renumbered and the first one becomes the cover if the cover was removed
+func (r *AdImagesRepo) Delete(ctx context.Context, adID, imageID int64) ([]string, error) {
+	const op = "repository.AdImagesRepo.Delete"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var variantKeys []string
+	err = tx.QueryRowContext(ctx, `SELECT ARRAY(SELECT storage_key FROM ad_image_variants WHERE image_id = $1)`, imageID).
+		Scan(pq.Array(&variantKeys))
+	if err != nil {
+		return nil, fmt.Errorf("%s: get variants: %w", op, err)
+	}
+
+	var (
+		key     string
+		isCover bool
+	)
+	err = tx.QueryRowContext(ctx, `DELETE FROM ad_images WHERE id = $1 AND ad_id = $2 RETURNING storage_key, is_cover`, imageID, adID).
+		Scan(&key, &isCover)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, entity.ErrImageNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	renumberQuery := `UPDATE ad_images i
+					  SET position = o.position
+					  FROM (
+					      SELECT id, ROW_NUMBER() OVER (ORDER BY position, id) - 1 AS position
+					      FROM ad_images
+					      WHERE ad_id = $1
+					  ) o
+					  WHERE i.id = o.id`
+	if _, err := tx.ExecContext(ctx, renumberQuery, adID); err != nil {
+		return nil, fmt.Errorf("%s: renumber images: %w", op, err)
+	}
+
+	if isCover {
+		if _, err := tx.ExecContext(ctx, `UPDATE ad_images SET is_cover = TRUE WHERE ad_id = $1 AND position = 0`, adID); err != nil {
+			if pgErr, ok := err.(*pq.Error); ok && isCoverConflict(pgErr) {
+				return nil, fmt.Errorf("%s: %w", op, entity.ErrGalleryConflict)
+			}
+			return nil, fmt.Errorf("%s: reassign cover: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+	return append(variantKeys, key), nil
+}
+
+// GetKeysOfDeletedAds returns storage keys of images and image variants attached to ads deleted before the given time
+func (r *AdImagesRepo) GetKeysOfDeletedAds(ctx context.Context, deletedBefore time.Time) ([]string, error) {
+	const op = "repository.AdImagesRepo.GetKeysOfDeletedAds"
+
+	query := `SELECT i.storage_key
+			  FROM ad_images i
+			  JOIN ads a ON i.ad_id = a.id
+			  WHERE a.deleted_at IS NOT NULL AND a.deleted_at < $1
+			  UNION ALL
+			  SELECT v.storage_key
+			  FROM ad_image_variants v
+			  JOIN ad_images i ON v.image_id = i.id
+			  JOIN ads a ON i.ad_id = a.id
+			  WHERE a.deleted_at IS NOT NULL AND a.deleted_at < $1`
+
+	rows, err := r.db.QueryContext(ctx, query, deletedBefore)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query execution: %w", op, err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("%s: row scan: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows iteration: %w", op, err)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"rest-api-marketplace/internal/entity"
+)
+
+// LockoutsRepo provides DB operations for recorded account lockouts
+type LockoutsRepo struct {
+	db *sql.DB
+}
+
+// NewLockoutsRepo creates a new LockoutsRepo instance
+func NewLockoutsRepo(db *sql.DB) *LockoutsRepo {
+	return &LockoutsRepo{db: db}
+}
+
+// Create records a lockout of the account
+func (r *LockoutsRepo) Create(ctx context.Context, lockout entity.Lockout) error {
+	const op = "repository.LockoutsRepo.Create"
+
+	query := `INSERT INTO account_lockouts (user_id, ip, failures, locked_until) VALUES ($1, $2, $3, $4)`
+
+	if _, err := r.db.ExecContext(ctx, query, lockout.UserID, lockout.IP, lockout.Failures, lockout.LockedUntil); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetByUserID returns the latest lockouts of the account, the most recent first
+func (r *LockoutsRepo) GetByUserID(ctx context.Context, userID int64, limit int) ([]entity.Lockout, error) {
+	const op = "repository.LockoutsRepo.GetByUserID"
+
+	query := `SELECT id, user_id, ip, failures, locked_until, created_at
+			  FROM account_lockouts
+			  WHERE user_id = $1
+			  ORDER BY created_at DESC, id DESC
+			  LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query execution: %w", op, err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var lockouts []entity.Lockout
+	for rows.Next() {
+		var lockout entity.Lockout
+		if err := rows.Scan(
+			&lockout.ID,
+			&lockout.UserID,
+			&lockout.IP,
+			&lockout.Failures,
+			&lockout.LockedUntil,
+			&lockout.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("%s: row scan: %w", op, err)
+		}
+		lockouts = append(lockouts, lockout)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows iteration: %w", op, err)
+	}
+
+	return lockouts, nil
+}
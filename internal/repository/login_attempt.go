@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"rest-api-marketplace/pkg/auth"
+)
+
+// LoginAttemptsRepo provides DB operations for failed sign-in attempts, so all instances share them
+type LoginAttemptsRepo struct {
+	db *sql.DB
+}
+
+// NewLoginAttemptsRepo creates a new LoginAttemptsRepo instance
+func NewLoginAttemptsRepo(db *sql.DB) *LoginAttemptsRepo {
+	return &LoginAttemptsRepo{db: db}
+}
+
+// Update changes the attempts of the key in a transaction holding the row lock, so concurrent updates
+// of the key wait for each other. Keys that are neither locked nor failed within the window are dropped along the way
+func (r *LoginAttemptsRepo) Update(ctx context.Context, key string, now time.Time, window time.Duration, fn func(auth.Attempts) auth.Attempts) (auth.Attempts, error) {
+	const op = "repository.LoginAttemptsRepo.Update"
+
+	since := now.Add(-window)
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $2)`, since, now); err != nil {
+		return auth.Attempts{}, fmt.Errorf("%s: prune expired: %w", op, err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return auth.Attempts{}, fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// a placeholder row gives a new key a row to lock, it is removed below unless fn counts something
+	query := `INSERT INTO login_attempts (key, failures, last_failure_at) VALUES ($1, 0, $2) ON CONFLICT (key) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, key, now); err != nil {
+		return auth.Attempts{}, fmt.Errorf("%s: insert: %w", op, err)
+	}
+
+	query = `SELECT failures, last_failure_at, locked_until FROM login_attempts WHERE key = $1 FOR UPDATE`
+
+	var (
+		attempts    auth.Attempts
+		lockedUntil *time.Time
+	)
+	if err := tx.QueryRowContext(ctx, query, key).Scan(&attempts.Failures, &attempts.LastFailure, &lockedUntil); err != nil {
+		return auth.Attempts{}, fmt.Errorf("%s: select: %w", op, err)
+	}
+	if lockedUntil != nil {
+		attempts.LockedUntil = *lockedUntil
+	}
+	if attempts.Failures == 0 || attempts.LastFailure.Before(since) {
+		attempts.Failures = 0
+		attempts.LastFailure = time.Time{}
+	}
+
+	attempts = fn(attempts)
+
+	if attempts == (auth.Attempts{}) {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM login_attempts WHERE key = $1`, key); err != nil {
+			return auth.Attempts{}, fmt.Errorf("%s: delete: %w", op, err)
+		}
+	} else {
+		lastFailure := attempts.LastFailure
+		if lastFailure.IsZero() {
+			lastFailure = now
+		}
+		lockedUntil = nil
+		if !attempts.LockedUntil.IsZero() {
+			lockedUntil = &attempts.LockedUntil
+		}
+
+		query = `UPDATE login_attempts SET failures = $2, last_failure_at = $3, locked_until = $4 WHERE key = $1`
+		if _, err := tx.ExecContext(ctx, query, key, attempts.Failures, lastFailure, lockedUntil); err != nil {
+			return auth.Attempts{}, fmt.Errorf("%s: update: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return auth.Attempts{}, fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+
+	return attempts, nil
+}
+
+// Reset forgets the attempts of the key
+func (r *LoginAttemptsRepo) Reset(ctx context.Context, key string) error {
+	const op = "repository.LoginAttemptsRepo.Reset"
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"rest-api-marketplace/internal/entity"
+)
+
+// OAuthStatesRepo provides DB operations for pending external sign-ins
+type OAuthStatesRepo struct {
+	db *sql.DB
+}
+
+// NewOAuthStatesRepo creates a new OAuthStatesRepo instance
+func NewOAuthStatesRepo(db *sql.DB) *OAuthStatesRepo {
+	return &OAuthStatesRepo{db: db}
+}
+
+// Create stores a pending external sign-in, abandoned expired ones are cleaned up on the way
+func (r *OAuthStatesRepo) Create(ctx context.Context, state entity.OAuthState) error {
+	const op = "repository.OAuthStatesRepo.Create"
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM oauth_states WHERE expires_at <= NOW()`); err != nil {
+		return fmt.Errorf("%s: delete expired states: %w", op, err)
+	}
+
+	query := `INSERT INTO oauth_states (state_hash, provider, code_verifier, nonce, user_id, expires_at) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	if _, err := r.db.ExecContext(ctx, query, state.StateHash, state.Provider, state.CodeVerifier, state.Nonce, state.UserID, state.ExpiresAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Consume removes an unexpired state and returns it, so a callback can be handled only once
+func (r *OAuthStatesRepo) Consume(ctx context.Context, stateHash string) (*entity.OAuthState, error) {
+	const op = "repository.OAuthStatesRepo.Consume"
+
+	query := `DELETE FROM oauth_states
+			  WHERE state_hash = $1 AND expires_at > NOW()
+			  RETURNING state_hash, provider, code_verifier, nonce, user_id, created_at, expires_at`
+
+	var state entity.OAuthState
+	err := r.db.QueryRowContext(ctx, query, stateHash).Scan(
+		&state.StateHash,
+		&state.Provider,
+		&state.CodeVerifier,
+		&state.Nonce,
+		&state.UserID,
+		&state.CreatedAt,
+		&state.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, entity.ErrInvalidOAuthState)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &state, nil
+}
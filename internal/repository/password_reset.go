@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"rest-api-marketplace/internal/entity"
+)
+
+// PasswordResetsRepo provides DB operations for password reset tokens
+type PasswordResetsRepo struct {
+	db *sql.DB
+}
+
+// NewPasswordResetsRepo creates a new PasswordResetsRepo instance
+func NewPasswordResetsRepo(db *sql.DB) *PasswordResetsRepo {
+	return &PasswordResetsRepo{db: db}
+}
+
+// Create stores a reset token, earlier tokens of the user stop working
+func (r *PasswordResetsRepo) Create(ctx context.Context, reset entity.PasswordReset) error {
+	const op = "repository.PasswordResetsRepo.Create"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM password_resets WHERE user_id = $1`, reset.UserID); err != nil {
+		return fmt.Errorf("%s: delete previous tokens: %w", op, err)
+	}
+
+	query := `INSERT INTO password_resets (token_hash, user_id, expires_at) VALUES ($1, $2, $3)`
+
+	if _, err := tx.ExecContext(ctx, query, reset.TokenHash, reset.UserID, reset.ExpiresAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+
+	return nil
+}
+
+// Get retrieves an unexpired reset token by its hash
+func (r *PasswordResetsRepo) Get(ctx context.Context, tokenHash string) (*entity.PasswordReset, error) {
+	const op = "repository.PasswordResetsRepo.Get"
+
+	query := `SELECT token_hash, user_id, created_at, expires_at FROM password_resets WHERE token_hash = $1 AND expires_at > NOW()`
+
+	var reset entity.PasswordReset
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(&reset.TokenHash, &reset.UserID, &reset.CreatedAt, &reset.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, entity.ErrInvalidResetToken)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &reset, nil
+}
+
+// Redeem consumes the token and sets the new password hash of its user.
+// It returns the user ID or ErrInvalidResetToken if the token is unknown, used or expired
+func (r *PasswordResetsRepo) Redeem(ctx context.Context, tokenHash, passwordHash string) (int64, error) {
+	const op = "repository.PasswordResetsRepo.Redeem"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var userID int64
+	err = tx.QueryRowContext(ctx, `DELETE FROM password_resets WHERE token_hash = $1 AND expires_at > NOW() RETURNING user_id`, tokenHash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, entity.ErrInvalidResetToken)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET password_hash = $1 WHERE id = $2`, passwordHash, userID); err != nil {
+		return 0, fmt.Errorf("%s: update password: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM password_resets WHERE user_id = $1`, userID); err != nil {
+		return 0, fmt.Errorf("%s: delete other tokens: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+
+	return userID, nil
+}
@@ -4,8 +4,11 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/pkg/auth"
+	"rest-api-marketplace/pkg/money"
 )
 
 // Users defines user repository interface
@@ -13,8 +16,83 @@ type Users interface {
 	Create(ctx context.Context, user entity.User) (int64, error)
 	GetByLogin(ctx context.Context, login string) (*entity.User, error)
 	GetByID(ctx context.Context, id int64) (*entity.User, error)
-	GetByRefreshToken(ctx context.Context, refreshToken string) (*entity.User, error)
-	SetSession(ctx context.Context, id int64, session entity.Session) error
+	UpdateRole(ctx context.Context, id int64, role entity.Role) error
+	GetByEmail(ctx context.Context, email string) (*entity.User, error)
+	SetEmail(ctx context.Context, id int64, email string) error
+	UpdatePassword(ctx context.Context, id int64, passwordHash string) error
+	ReplacePasswordHash(ctx context.Context, id int64, oldHash, newHash string) error
+}
+
+// TwoFactor defines TOTP secret, recovery code and sign-in challenge repository interface
+type TwoFactor interface {
+	SaveTOTP(ctx context.Context, userID int64, secret string) error
+	GetTOTP(ctx context.Context, userID int64) (*entity.TOTP, error)
+	EnableTOTP(ctx context.Context, userID, step int64, recoveryCodeHashes []string) error
+	UseTOTPStep(ctx context.Context, userID, step int64) error
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error
+	DeleteTOTP(ctx context.Context, userID int64) error
+	CreateChallenge(ctx context.Context, challenge entity.TwoFactorChallenge) error
+	AttemptChallenge(ctx context.Context, tokenHash string, maxAttempts int) (*entity.TwoFactorChallenge, error)
+	ConsumeChallenge(ctx context.Context, tokenHash string) error
+}
+
+// Identities defines linked external identity repository interface
+type Identities interface {
+	Create(ctx context.Context, identity entity.Identity) (int64, error)
+	CreateWithUser(ctx context.Context, user entity.User, identity entity.Identity) (int64, error)
+	GetBySubject(ctx context.Context, provider, subject string) (*entity.Identity, error)
+	GetByUserID(ctx context.Context, userID int64) ([]entity.Identity, error)
+	Delete(ctx context.Context, id, userID int64) error
+}
+
+// OAuthStates defines pending external sign-in repository interface
+type OAuthStates interface {
+	Create(ctx context.Context, state entity.OAuthState) error
+	Consume(ctx context.Context, stateHash string) (*entity.OAuthState, error)
+}
+
+// PasswordResets defines password reset token repository interface
+type PasswordResets interface {
+	Create(ctx context.Context, reset entity.PasswordReset) error
+	Get(ctx context.Context, tokenHash string) (*entity.PasswordReset, error)
+	Redeem(ctx context.Context, tokenHash, passwordHash string) (int64, error)
+}
+
+// EmailVerifications defines email verification token repository interface
+type EmailVerifications interface {
+	Create(ctx context.Context, verification entity.EmailVerification) error
+	Redeem(ctx context.Context, tokenHash string) (int64, error)
+}
+
+// Sessions defines user session repository interface
+type Sessions interface {
+	Create(ctx context.Context, session entity.Session) (int64, error)
+	GetByID(ctx context.Context, id int64) (*entity.Session, error)
+	GetRefreshToken(ctx context.Context, tokenHash string) (*entity.RefreshToken, error)
+	Rotate(ctx context.Context, oldTokenHash string, session entity.Session) error
+	GetByUserID(ctx context.Context, userID int64) ([]entity.Session, error)
+	Delete(ctx context.Context, id, userID int64) error
+	DeleteByUserID(ctx context.Context, userID int64) error
+}
+
+// RevokedTokens defines revoked access token repository interface
+type RevokedTokens interface {
+	Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error
+	RevokeUser(ctx context.Context, userID int64, issuedBefore, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, claims *auth.TokenClaims) (bool, error)
+}
+
+// LoginAttempts defines failed sign-in attempts repository interface, it is an auth.AttemptStore
+type LoginAttempts interface {
+	Update(ctx context.Context, key string, now time.Time, window time.Duration, fn func(auth.Attempts) auth.Attempts) (auth.Attempts, error)
+	Reset(ctx context.Context, key string) error
+}
+
+// Lockouts defines account lockout repository interface
+type Lockouts interface {
+	Create(ctx context.Context, lockout entity.Lockout) error
+	GetByUserID(ctx context.Context, userID int64, limit int) ([]entity.Lockout, error)
 }
 
 // Ads defines ad repository interface
@@ -22,21 +100,86 @@ type Ads interface {
 	Create(ctx context.Context, ad entity.Ad) (int64, error)
 	Update(ctx context.Context, id int64, ad entity.Ad) error
 	GetByID(ctx context.Context, id int64) (*entity.Ad, error)
-	GetByIDWithAuthor(ctx context.Context, id int64) (*entity.AdWithAuthor, error)
-	GetAll(ctx context.Context, params entity.GetAdsQuery) ([]entity.AdWithAuthor, error)
+	GetByIDWithAuthor(ctx context.Context, id int64, currency money.Currency) (*entity.AdWithAuthor, error)
+	GetAll(ctx context.Context, params entity.GetAdsQuery) ([]entity.AdWithAuthor, *entity.AdsCursor, error)
+	Count(ctx context.Context, params entity.GetAdsQuery) (int64, error)
+	UpdateStatus(ctx context.Context, id int64, from, to entity.AdStatus) error
+	GetDeletedByID(ctx context.Context, id int64) (*entity.Ad, error)
+	Delete(ctx context.Context, id int64) error
+	Restore(ctx context.Context, id int64, deletedAfter time.Time) error
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+// Categories defines category repository interface
+type Categories interface {
+	Create(ctx context.Context, category entity.Category) (int64, error)
+	Update(ctx context.Context, id int64, category entity.Category) error
+	GetByID(ctx context.Context, id int64) (*entity.Category, error)
+	GetAll(ctx context.Context) ([]entity.Category, error)
 	Delete(ctx context.Context, id int64) error
 }
 
+// AdImages defines ad image repository interface
+type AdImages interface {
+	Create(ctx context.Context, image entity.AdImage) (*entity.AdImage, error)
+	GetByAdIDs(ctx context.Context, adIDs []int64) ([]entity.AdImage, error)
+	GetByID(ctx context.Context, id int64) (*entity.AdImage, error)
+	GetPendingVariants(ctx context.Context, limit int) ([]int64, error)
+	SaveVariants(ctx context.Context, imageID int64, variants []entity.AdImageVariant) error
+	MarkVariantsFailed(ctx context.Context, imageID int64) error
+	Reorder(ctx context.Context, adID int64, imageIDs []int64) error
+	SetCover(ctx context.Context, adID, imageID int64) error
+	Delete(ctx context.Context, adID, imageID int64) ([]string, error)
+	GetKeysOfDeletedAds(ctx context.Context, deletedBefore time.Time) ([]string, error)
+}
+
+// ExchangeRates defines exchange rate repository interface
+type ExchangeRates interface {
+	Upsert(ctx context.Context, rates []entity.ExchangeRate) error
+	GetAll(ctx context.Context) ([]entity.ExchangeRate, error)
+}
+
+// AuditLog defines audit log repository interface
+type AuditLog interface {
+	Create(ctx context.Context, entry entity.AuditEntry) error
+}
+
 // Repositories aggregates all repositories
 type Repositories struct {
-	Users Users
-	Ads   Ads
+	Users              Users
+	Sessions           Sessions
+	EmailVerifications EmailVerifications
+	PasswordResets     PasswordResets
+	TwoFactor          TwoFactor
+	Identities         Identities
+	OAuthStates        OAuthStates
+	LoginAttempts      LoginAttempts
+	Lockouts           Lockouts
+	RevokedTokens      RevokedTokens
+	Ads                Ads
+	Categories         Categories
+	AdImages           AdImages
+	ExchangeRates      ExchangeRates
+	AuditLog           AuditLog
 }
 
 // NewRepositories initializes all repositories
 func NewRepositories(db *sql.DB) *Repositories {
 	return &Repositories{
-		Users: NewUsersRepo(db),
-		Ads:   NewAdsRepo(db),
+		Users:              NewUsersRepo(db),
+		Sessions:           NewSessionsRepo(db),
+		EmailVerifications: NewEmailVerificationsRepo(db),
+		PasswordResets:     NewPasswordResetsRepo(db),
+		TwoFactor:          NewTwoFactorRepo(db),
+		Identities:         NewIdentitiesRepo(db),
+		OAuthStates:        NewOAuthStatesRepo(db),
+		LoginAttempts:      NewLoginAttemptsRepo(db),
+		Lockouts:           NewLockoutsRepo(db),
+		RevokedTokens:      NewRevokedTokensRepo(db),
+		Ads:                NewAdsRepo(db),
+		Categories:         NewCategoriesRepo(db),
+		AdImages:           NewAdImagesRepo(db),
+		ExchangeRates:      NewExchangeRatesRepo(db),
+		AuditLog:           NewAuditLogRepo(db),
 	}
 }
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"rest-api-marketplace/pkg/auth"
+)
+
+// RevokedTokensRepo provides DB operations for revoked access tokens
+type RevokedTokensRepo struct {
+	db *sql.DB
+}
+
+// NewRevokedTokensRepo creates a new RevokedTokensRepo instance
+func NewRevokedTokensRepo(db *sql.DB) *RevokedTokensRepo {
+	return &RevokedTokensRepo{db: db}
+}
+
+// Revoke stores the ID of a revoked token until it expires and drops revocations of already expired tokens
+func (r *RevokedTokensRepo) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	const op = "repository.RevokedTokensRepo.Revoke"
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < NOW()`); err != nil {
+		return fmt.Errorf("%s: prune expired: %w", op, err)
+	}
+
+	query := `INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`
+
+	if _, err := r.db.ExecContext(ctx, query, tokenID, expiresAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeUser revokes all tokens of the user issued up to the end of the second of issuedBefore, token issue times have whole seconds
+func (r *RevokedTokensRepo) RevokeUser(ctx context.Context, userID int64, issuedBefore, expiresAt time.Time) error {
+	const op = "repository.RevokedTokensRepo.RevokeUser"
+
+	issuedBefore = issuedBefore.Truncate(time.Second)
+
+	query := `INSERT INTO user_token_revocations (user_id, revoked_before, expires_at) VALUES ($1, $2, $3)
+			  ON CONFLICT (user_id) DO UPDATE
+			  SET revoked_before = GREATEST(user_token_revocations.revoked_before, EXCLUDED.revoked_before),
+			      expires_at = GREATEST(user_token_revocations.expires_at, EXCLUDED.expires_at)`
+
+	if _, err := r.db.ExecContext(ctx, query, userID, issuedBefore, expiresAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// IsRevoked reports whether the token itself or all tokens of its user issued up to its issue time were revoked
+func (r *RevokedTokensRepo) IsRevoked(ctx context.Context, claims *auth.TokenClaims) (bool, error) {
+	const op = "repository.RevokedTokensRepo.IsRevoked"
+
+	var issuedAt *time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = &claims.IssuedAt.Time
+	}
+
+	query := `SELECT 1 WHERE EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1 AND $1 <> '')
+			  OR EXISTS (
+			      SELECT 1 FROM user_token_revocations
+			      WHERE user_id = $2 AND expires_at > NOW()
+			        AND ($3::TIMESTAMPTZ IS NULL OR $3::TIMESTAMPTZ <= revoked_before)
+			  )`
+
+	var found int
+	err := r.db.QueryRowContext(ctx, query, claims.ID, claims.UserID, issuedAt).Scan(&found)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"rest-api-marketplace/internal/entity"
+)
+
+// SessionsRepo provides DB operations for user sessions
+type SessionsRepo struct {
+	db *sql.DB
+}
+
+// NewSessionsRepo creates a new SessionsRepo instance
+func NewSessionsRepo(db *sql.DB) *SessionsRepo {
+	return &SessionsRepo{db: db}
+}
+
+// Create inserts a new session together with the first refresh token of its family and returns the session ID
+func (r *SessionsRepo) Create(ctx context.Context, session entity.Session) (int64, error) {
+	const op = "repository.SessionsRepo.Create"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `INSERT INTO sessions (user_id, user_agent, ip, expires_at) VALUES ($1, $2, $3, $4) RETURNING id`
+
+	var id int64
+	err = tx.QueryRowContext(ctx, query, session.UserID, session.UserAgent, session.IP, session.ExpiresAt).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO refresh_tokens (session_id, token_hash) VALUES ($1, $2)`, id, session.RefreshTokenHash); err != nil {
+		return 0, fmt.Errorf("%s: insert refresh token: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// GetByID retrieves an unexpired session by its ID
+func (r *SessionsRepo) GetByID(ctx context.Context, id int64) (*entity.Session, error) {
+	const op = "repository.SessionsRepo.GetByID"
+
+	query := `SELECT id, user_id, user_agent, ip, created_at, last_used_at, expires_at
+			  FROM sessions
+			  WHERE id = $1 AND expires_at > NOW()`
+
+	var session entity.Session
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.UserAgent,
+		&session.IP,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, entity.ErrSessionNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &session, nil
+}
+
+// GetRefreshToken retrieves a refresh token by its hash, including tokens that were already rotated
+func (r *SessionsRepo) GetRefreshToken(ctx context.Context, tokenHash string) (*entity.RefreshToken, error) {
+	const op = "repository.SessionsRepo.GetRefreshToken"
+
+	query := `SELECT id, session_id, token_hash, created_at, rotated_at FROM refresh_tokens WHERE token_hash = $1`
+
+	var token entity.RefreshToken
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(&token.ID, &token.SessionID, &token.TokenHash, &token.CreatedAt, &token.RotatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, entity.ErrSessionNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &token, nil
+}
+
+// Rotate marks the current refresh token of a session as rotated and adds the next one to the family.
+// It fails with ErrRefreshTokenReused if the old token was rotated concurrently
+func (r *SessionsRepo) Rotate(ctx context.Context, oldTokenHash string, session entity.Session) error {
+	const op = "repository.SessionsRepo.Rotate"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	res, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET rotated_at = NOW() WHERE session_id = $1 AND token_hash = $2 AND rotated_at IS NULL`,
+		session.ID, oldTokenHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrRefreshTokenReused)
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO refresh_tokens (session_id, token_hash) VALUES ($1, $2)`, session.ID, session.RefreshTokenHash); err != nil {
+		return fmt.Errorf("%s: insert refresh token: %w", op, err)
+	}
+
+	res, err = tx.ExecContext(ctx, `UPDATE sessions SET user_agent = $1, ip = $2, expires_at = $3, last_used_at = NOW() WHERE id = $4 AND expires_at > NOW()`,
+		session.UserAgent, session.IP, session.ExpiresAt, session.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	rowsAffected, err = res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrSessionNotFound)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetByUserID returns unexpired sessions of a user, the most recently used first
+func (r *SessionsRepo) GetByUserID(ctx context.Context, userID int64) ([]entity.Session, error) {
+	const op = "repository.SessionsRepo.GetByUserID"
+
+	query := `SELECT id, user_id, user_agent, ip, created_at, last_used_at, expires_at
+			  FROM sessions
+			  WHERE user_id = $1 AND expires_at > NOW()
+			  ORDER BY last_used_at DESC, id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query execution: %w", op, err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var sessions []entity.Session
+	for rows.Next() {
+		var session entity.Session
+		if err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IP,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.ExpiresAt,
+		); err != nil {
+			return nil, fmt.Errorf("%s: row scan: %w", op, err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows iteration: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+// Delete removes a session of the user together with its whole refresh token family
+func (r *SessionsRepo) Delete(ctx context.Context, id, userID int64) error {
+	const op = "repository.SessionsRepo.Delete"
+
+	query := `DELETE FROM sessions WHERE id = $1 AND user_id = $2`
+
+	res, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrSessionNotFound)
+	}
+
+	return nil
+}
+
+// DeleteByUserID removes all sessions of the user together with their refresh token families
+func (r *SessionsRepo) DeleteByUserID(ctx context.Context, userID int64) error {
+	const op = "repository.SessionsRepo.DeleteByUserID"
+
+	query := `DELETE FROM sessions WHERE user_id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,283 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"rest-api-marketplace/internal/entity"
+)
+
+// TwoFactorRepo provides DB operations for TOTP secrets, recovery codes and sign-in challenges
+type TwoFactorRepo struct {
+	db *sql.DB
+}
+
+// NewTwoFactorRepo creates a new TwoFactorRepo instance
+func NewTwoFactorRepo(db *sql.DB) *TwoFactorRepo {
+	return &TwoFactorRepo{db: db}
+}
+
+// SaveTOTP stores a new unconfirmed secret of the user, replacing an earlier unconfirmed one.
+// It fails with ErrTwoFactorEnabled if the user already confirmed a secret
+func (r *TwoFactorRepo) SaveTOTP(ctx context.Context, userID int64, secret string) error {
+	const op = "repository.TwoFactorRepo.SaveTOTP"
+
+	query := `INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+			  ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_step = 0, created_at = NOW()
+			  WHERE user_totp.confirmed_at IS NULL`
+
+	res, err := r.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrTwoFactorEnabled)
+	}
+
+	return nil
+}
+
+// GetTOTP retrieves the secret of the user, confirmed or not
+func (r *TwoFactorRepo) GetTOTP(ctx context.Context, userID int64) (*entity.TOTP, error) {
+	const op = "repository.TwoFactorRepo.GetTOTP"
+
+	query := `SELECT user_id, secret, last_step, created_at, confirmed_at FROM user_totp WHERE user_id = $1`
+
+	var totp entity.TOTP
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&totp.UserID, &totp.Secret, &totp.LastStep, &totp.CreatedAt, &totp.ConfirmedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, entity.ErrTwoFactorNotEnabled)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &totp, nil
+}
+
+// EnableTOTP confirms the secret of the user with the time step of a valid code and stores the recovery codes
+func (r *TwoFactorRepo) EnableTOTP(ctx context.Context, userID, step int64, recoveryCodeHashes []string) error {
+	const op = "repository.TwoFactorRepo.EnableTOTP"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	res, err := tx.ExecContext(ctx, `UPDATE user_totp SET confirmed_at = NOW(), last_step = $2 WHERE user_id = $1 AND confirmed_at IS NULL`, userID, step)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrTwoFactorEnabled)
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+
+	return nil
+}
+
+// UseTOTPStep marks the time step of a valid code as used. It fails with ErrInvalidTwoFactorCode
+// if a code of this or a later step was already accepted, so codes can't be replayed
+func (r *TwoFactorRepo) UseTOTPStep(ctx context.Context, userID, step int64) error {
+	const op = "repository.TwoFactorRepo.UseTOTPStep"
+
+	query := `UPDATE user_totp SET last_step = $2 WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_step < $2`
+
+	res, err := r.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrInvalidTwoFactorCode)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code of the user as used,
+// it fails with ErrInvalidTwoFactorCode if there is no such code
+func (r *TwoFactorRepo) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	const op = "repository.TwoFactorRepo.UseRecoveryCode"
+
+	query := `UPDATE recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	res, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrInvalidTwoFactorCode)
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes drops all recovery codes of the user, used or not, and stores new ones
+func (r *TwoFactorRepo) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	const op = "repository.TwoFactorRepo.ReplaceRecoveryCodes"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteTOTP turns two-factor authentication of the user off, removing the secret, recovery codes and pending challenges
+func (r *TwoFactorRepo) DeleteTOTP(ctx context.Context, userID int64) error {
+	const op = "repository.TwoFactorRepo.DeleteTOTP"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrTwoFactorNotEnabled)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("%s: delete recovery codes: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM two_factor_challenges WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("%s: delete challenges: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+
+	return nil
+}
+
+// CreateChallenge stores a pending second step of a sign-in
+func (r *TwoFactorRepo) CreateChallenge(ctx context.Context, challenge entity.TwoFactorChallenge) error {
+	const op = "repository.TwoFactorRepo.CreateChallenge"
+
+	query := `INSERT INTO two_factor_challenges (token_hash, user_id, expires_at) VALUES ($1, $2, $3)`
+
+	if _, err := r.db.ExecContext(ctx, query, challenge.TokenHash, challenge.UserID, challenge.ExpiresAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AttemptChallenge counts an attempt at an unexpired challenge before its code is checked and returns the challenge.
+// Counting and checking the limit is one statement, so concurrent attempts can't exceed maxAttempts.
+// It fails with ErrInvalidChallenge if the challenge was used, expired or has no attempts left
+func (r *TwoFactorRepo) AttemptChallenge(ctx context.Context, tokenHash string, maxAttempts int) (*entity.TwoFactorChallenge, error) {
+	const op = "repository.TwoFactorRepo.AttemptChallenge"
+
+	query := `UPDATE two_factor_challenges SET attempts = attempts + 1
+			  WHERE token_hash = $1 AND expires_at > NOW() AND attempts < $2
+			  RETURNING token_hash, user_id, attempts, created_at, expires_at`
+
+	var challenge entity.TwoFactorChallenge
+	err := r.db.QueryRowContext(ctx, query, tokenHash, maxAttempts).Scan(
+		&challenge.TokenHash,
+		&challenge.UserID,
+		&challenge.Attempts,
+		&challenge.CreatedAt,
+		&challenge.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, entity.ErrInvalidChallenge)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &challenge, nil
+}
+
+// ConsumeChallenge removes a passed challenge so it can't be used again,
+// it fails with ErrInvalidChallenge if the challenge was already used or expired
+func (r *TwoFactorRepo) ConsumeChallenge(ctx context.Context, tokenHash string) error {
+	const op = "repository.TwoFactorRepo.ConsumeChallenge"
+
+	res, err := r.db.ExecContext(ctx, `DELETE FROM two_factor_challenges WHERE token_hash = $1 AND expires_at > NOW()`, tokenHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrInvalidChallenge)
+	}
+
+	return nil
+}
+
+// replaceRecoveryCodes swaps the recovery codes of the user within the transaction
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+
+	for _, codeHash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, codeHash); err != nil {
+			return fmt.Errorf("insert recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
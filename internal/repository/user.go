@@ -11,6 +11,9 @@ import (
 	"github.com/lib/pq"
 )
 
+// emailIndex is the unique index of user emails, its violation means the email is taken
+const emailIndex = "idx_users_email"
+
 // UsersRepo provides DB operations for users
 type UsersRepo struct {
 	db *sql.DB
@@ -25,12 +28,15 @@ func NewUsersRepo(db *sql.DB) *UsersRepo {
 func (r *UsersRepo) Create(ctx context.Context, user entity.User) (int64, error) {
 	const op = "repository.UsersRepo.Create"
 
-	query := `INSERT INTO users (login, password_hash) VALUES ($1, $2) RETURNING id`
+	query := `INSERT INTO users (login, email, password_hash) VALUES ($1, NULLIF($2, ''), $3) RETURNING id`
 
 	var id int64
-	err := r.db.QueryRowContext(ctx, query, user.Login, user.PasswordHash).Scan(&id)
+	err := r.db.QueryRowContext(ctx, query, user.Login, user.Email, user.PasswordHash).Scan(&id)
 	if err != nil {
 		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			if pgErr.Constraint == emailIndex {
+				return 0, fmt.Errorf("%s: %w", op, entity.ErrEmailTaken)
+			}
 			return 0, fmt.Errorf("%s: %w", op, entity.ErrUserExists)
 		}
 		return 0, fmt.Errorf("%s: %w", op, err)
@@ -42,10 +48,10 @@ func (r *UsersRepo) Create(ctx context.Context, user entity.User) (int64, error)
 func (r *UsersRepo) GetByLogin(ctx context.Context, login string) (*entity.User, error) {
 	const op = "repository.UsersRepo.GetByLogin"
 
-	query := `SELECT id, login, password_hash, created_at FROM users WHERE login = $1`
+	query := `SELECT id, login, COALESCE(email, ''), email_verified_at, password_hash, role, created_at FROM users WHERE login = $1`
 
 	var user entity.User
-	err := r.db.QueryRowContext(ctx, query, login).Scan(&user.ID, &user.Login, &user.PasswordHash, &user.CreatedAt)
+	err := r.db.QueryRowContext(ctx, query, login).Scan(&user.ID, &user.Login, &user.Email, &user.EmailVerifiedAt, &user.PasswordHash, &user.Role, &user.CreatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("%s: %w", op, entity.ErrUserNotFound)
@@ -59,10 +65,10 @@ func (r *UsersRepo) GetByLogin(ctx context.Context, login string) (*entity.User,
 func (r *UsersRepo) GetByID(ctx context.Context, id int64) (*entity.User, error) {
 	const op = "repository.UsersRepo.GetByID"
 
-	query := `SELECT id, login, created_at FROM users WHERE id = $1 `
+	query := `SELECT id, login, COALESCE(email, ''), email_verified_at, password_hash, role, created_at FROM users WHERE id = $1 `
 
 	var user entity.User
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Login, &user.CreatedAt)
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Login, &user.Email, &user.EmailVerifiedAt, &user.PasswordHash, &user.Role, &user.CreatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("%s: %w", op, entity.ErrUserNotFound)
@@ -72,16 +78,61 @@ func (r *UsersRepo) GetByID(ctx context.Context, id int64) (*entity.User, error)
 	return &user, nil
 }
 
-// GetByRefreshToken retrieves a user by refresh token
-func (r *UsersRepo) GetByRefreshToken(ctx context.Context, refreshToken string) (*entity.User, error) {
-	const op = "repository.UsersRepo.GetByRefreshToken"
+// UpdateRole changes the role of a user
+func (r *UsersRepo) UpdateRole(ctx context.Context, id int64, role entity.Role) error {
+	const op = "repository.UsersRepo.UpdateRole"
+
+	query := `UPDATE users SET role = $1 WHERE id = $2`
 
-	query := `SELECT id, login, password_hash, created_at
-			  FROM users
-			  WHERE refresh_token = $1 AND refresh_expires_at > NOW()`
+	res, err := r.db.ExecContext(ctx, query, role, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// SetEmail changes the email of a user, the new email is unverified
+func (r *UsersRepo) SetEmail(ctx context.Context, id int64, email string) error {
+	const op = "repository.UsersRepo.SetEmail"
+
+	query := `UPDATE users SET email = $1, email_verified_at = NULL WHERE id = $2`
+
+	res, err := r.db.ExecContext(ctx, query, email, id)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" && pgErr.Constraint == emailIndex {
+			return fmt.Errorf("%s: %w", op, entity.ErrEmailTaken)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// GetByEmail retrieves a user by email, case-insensitively
+func (r *UsersRepo) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
+	const op = "repository.UsersRepo.GetByEmail"
+
+	query := `SELECT id, login, email, email_verified_at, password_hash, role, created_at FROM users WHERE lower(email) = lower($1)`
 
 	var user entity.User
-	err := r.db.QueryRowContext(ctx, query, refreshToken).Scan(&user.ID, &user.Login, &user.PasswordHash, &user.CreatedAt)
+	err := r.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Login, &user.Email, &user.EmailVerifiedAt, &user.PasswordHash, &user.Role, &user.CreatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("%s: %w", op, entity.ErrUserNotFound)
@@ -91,15 +142,38 @@ func (r *UsersRepo) GetByRefreshToken(ctx context.Context, refreshToken string)
 	return &user, nil
 }
 
-// SetSession updates a user's refresh token and expiration
-func (r *UsersRepo) SetSession(ctx context.Context, id int64, session entity.Session) error {
-	const op = "repository.UsersRepo.SetSession"
+// UpdatePassword replaces the password hash of a user
+func (r *UsersRepo) UpdatePassword(ctx context.Context, id int64, passwordHash string) error {
+	const op = "repository.UsersRepo.UpdatePassword"
+
+	query := `UPDATE users SET password_hash = $1 WHERE id = $2`
 
-	query := `UPDATE users SET refresh_token = $1, refresh_expires_at = $2, last_visit_at = NOW() WHERE id = $3`
+	res, err := r.db.ExecContext(ctx, query, passwordHash, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
-	_, err := r.db.ExecContext(ctx, query, session.RefreshToken, session.ExpiresAt, id)
+	rowsAffected, err := res.RowsAffected()
 	if err != nil {
+		return fmt.Errorf("%s: check rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, entity.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// ReplacePasswordHash swaps the password hash of a user for a new hash of the same password.
+// Nothing changes if the password was changed in the meantime, so the new password is never overwritten
+func (r *UsersRepo) ReplacePasswordHash(ctx context.Context, id int64, oldHash, newHash string) error {
+	const op = "repository.UsersRepo.ReplacePasswordHash"
+
+	query := `UPDATE users SET password_hash = $1 WHERE id = $2 AND password_hash = $3`
+
+	if _, err := r.db.ExecContext(ctx, query, newHash, id, oldHash); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+
 	return nil
 }
@@ -6,26 +6,66 @@ import (
 	"fmt"
 	"log/slog"
 	"net/url"
+	"slices"
+	"strconv"
+	"time"
 
 	"rest-api-marketplace/internal/entity"
 	"rest-api-marketplace/internal/repository"
+	"rest-api-marketplace/pkg/money"
+	"rest-api-marketplace/pkg/storage"
 )
 
+// adTransitions lists the statuses an ad is allowed to move to from each status
+var adTransitions = map[entity.AdStatus][]entity.AdStatus{
+	entity.AdStatusDraft:     {entity.AdStatusPublished, entity.AdStatusArchived},
+	entity.AdStatusPublished: {entity.AdStatusDraft, entity.AdStatusReserved, entity.AdStatusSold, entity.AdStatusArchived},
+	entity.AdStatusReserved:  {entity.AdStatusPublished, entity.AdStatusSold, entity.AdStatusArchived},
+	entity.AdStatusSold:      {entity.AdStatusArchived},
+	entity.AdStatusArchived:  {entity.AdStatusDraft},
+	entity.AdStatusHidden:    nil, // only a moderator can bring a hidden ad back
+}
+
+// moderatorTransitions lists the statuses a moderator is allowed to move any ad to from each status
+var moderatorTransitions = map[entity.AdStatus][]entity.AdStatus{
+	entity.AdStatusDraft:     {entity.AdStatusHidden},
+	entity.AdStatusPublished: {entity.AdStatusHidden},
+	entity.AdStatusReserved:  {entity.AdStatusHidden},
+	entity.AdStatusSold:      {entity.AdStatusHidden},
+	entity.AdStatusArchived:  {entity.AdStatusHidden},
+	entity.AdStatusHidden:    {entity.AdStatusDraft},
+}
+
 // AdService provides operations to manage ads
 type AdService struct {
-	repo   repository.Ads
-	logger *slog.Logger
+	repo          repository.Ads
+	images        repository.AdImages
+	users         repository.Users
+	audit         auditor
+	storage       storage.Storage
+	logger        *slog.Logger
+	restoreWindow time.Duration
+	// requireVerifiedEmail blocks ad creation by users who haven't verified their email
+	requireVerifiedEmail bool
+	imageOpts            ImageOptions
 }
 
 // NewAdService creates a new AdService instance
-func NewAdService(repo repository.Ads, logger *slog.Logger) *AdService {
+func NewAdService(repo repository.Ads, images repository.AdImages, users repository.Users, auditLog repository.AuditLog, storage storage.Storage, logger *slog.Logger, restoreWindow time.Duration, requireVerifiedEmail bool, imageOpts ImageOptions) *AdService {
 	return &AdService{
-		repo:   repo,
-		logger: logger,
+		repo:                 repo,
+		images:               images,
+		users:                users,
+		audit:                auditor{repo: auditLog, logger: logger},
+		storage:              storage,
+		logger:               logger,
+		restoreWindow:        restoreWindow,
+		requireVerifiedEmail: requireVerifiedEmail,
+		imageOpts:            imageOpts,
 	}
 }
 
-// Create validates input and creates a new ad
+// Create validates input and creates a new ad, the author must have a verified email if it is required
 func (s AdService) Create(ctx context.Context, input CreateAdInput, userID int64) (*entity.Ad, error) {
 	const op = "service.AdService.Create"
 
@@ -33,25 +73,49 @@ func (s AdService) Create(ctx context.Context, input CreateAdInput, userID int64
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	status := input.Status
+	if status == "" {
+		status = entity.AdStatusPublished
+	}
+	if status != entity.AdStatusDraft && status != entity.AdStatusPublished {
+		return nil, fmt.Errorf("%s: new ad must be a draft or published: %w", op, entity.ErrInvalidInput)
+	}
+
+	if s.requireVerifiedEmail {
+		user, err := s.users.GetByID(ctx, userID)
+		if err != nil {
+			s.logger.Error("failed to get ad author", slog.String("op", op), slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if !user.EmailVerified() {
+			return nil, fmt.Errorf("%s: %w", op, entity.ErrEmailNotVerified)
+		}
+	}
+
 	ad := entity.Ad{
 		UserID:      userID,
+		CategoryID:  input.CategoryID,
 		Title:       input.Title,
 		Description: input.Description,
 		ImageURL:    input.ImageURL,
 		Price:       input.Price,
+		Status:      status,
 	}
 
 	adID, err := s.repo.Create(ctx, ad)
 	if err != nil {
+		if errors.Is(err, entity.ErrCategoryNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
 		s.logger.Error("failed to create ad", slog.String("op", op), slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return s.repo.GetByID(ctx, adID)
+	return s.GetByID(ctx, adID)
 }
 
-// Update modifies an existing ad with new data
-func (s AdService) Update(ctx context.Context, adID, userID int64, input UpdateAdInput) (*entity.Ad, error) {
+// Update modifies an existing ad with new data, moderators can update any ad
+func (s AdService) Update(ctx context.Context, adID int64, actor Actor, input UpdateAdInput) (*entity.Ad, error) {
 	const op = "service.AdService.Update"
 
 	originalAd, err := s.repo.GetByID(ctx, adID)
@@ -63,12 +127,16 @@ func (s AdService) Update(ctx context.Context, adID, userID int64, input UpdateA
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if originalAd.UserID != userID {
-		return nil, entity.ErrForbidden
+	moderating, err := authorize(originalAd, actor)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	updatedAd := *originalAd
 
+	if input.CategoryID != nil {
+		updatedAd.CategoryID = input.CategoryID
+	}
 	if input.Title != nil {
 		updatedAd.Title = *input.Title
 	}
@@ -87,13 +155,21 @@ func (s AdService) Update(ctx context.Context, adID, userID int64, input UpdateA
 	}
 
 	if err := s.repo.Update(ctx, adID, updatedAd); err != nil {
-		if errors.Is(err, entity.ErrAdNotFound) {
+		if errors.Is(err, entity.ErrAdNotFound) || errors.Is(err, entity.ErrCategoryNotFound) {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 		s.logger.Error("failed to update ad", slog.String("op", op), slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if moderating {
+		s.audit.record(ctx, actor, entity.AuditAdUpdate, entity.AuditTargetAd, adID, adAuditDetails(originalAd))
+	}
+
+	if err := s.attachImages(ctx, op, &updatedAd); err != nil {
+		return nil, err
+	}
+
 	return &updatedAd, nil
 }
 
@@ -110,14 +186,18 @@ func (s AdService) GetByID(ctx context.Context, id int64) (*entity.Ad, error) {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := s.attachImages(ctx, op, ad); err != nil {
+		return nil, err
+	}
+
 	return ad, nil
 }
 
-// GetByIDWithAuthor retrieves an ad with author information and optional ownership info
-func (s AdService) GetByIDWithAuthor(ctx context.Context, id int64, currentUserID *int64) (*entity.AdResponse, error) {
+// GetByIDWithAuthor retrieves an ad with author information, its price in the currency and optional ownership info
+func (s AdService) GetByIDWithAuthor(ctx context.Context, id int64, currency money.Currency, currentUserID *int64) (*entity.AdResponse, error) {
 	const op = "service.AdService.GetByIDWithAuthor"
 
-	ad, err := s.repo.GetByIDWithAuthor(ctx, id)
+	ad, err := s.repo.GetByIDWithAuthor(ctx, id, currency)
 	if err != nil {
 		if errors.Is(err, entity.ErrAdNotFound) {
 			return nil, fmt.Errorf("%s: %w", op, err)
@@ -126,6 +206,16 @@ func (s AdService) GetByIDWithAuthor(ctx context.Context, id int64, currentUserI
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	isVisible := ad.Status == entity.AdStatusPublished || (currentUserID != nil && ad.UserID == *currentUserID)
+	if !isVisible {
+		return nil, fmt.Errorf("%s: %w", op, entity.ErrAdNotFound)
+	}
+
+	ad.Images, err = s.gallery(ctx, op, ad.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	res := &entity.AdResponse{
 		AdWithAuthor: *ad,
 	}
@@ -138,19 +228,48 @@ func (s AdService) GetByIDWithAuthor(ctx context.Context, id int64, currentUserI
 	return res, nil
 }
 
-// GetAll returns all ads with author info and optional ownership info
-func (s AdService) GetAll(ctx context.Context, params entity.GetAdsQuery, currentUserID *int64) ([]entity.AdResponse, error) {
+// GetAll returns a page of ads with author info and optional ownership info.
+// Only published ads are listed unless the user lists their own ads
+func (s AdService) GetAll(ctx context.Context, params entity.GetAdsQuery, currentUserID *int64) (*entity.AdsPage, error) {
 	const op = "service.AdService.GetAll"
 
-	adsWithAuthor, err := s.repo.GetAll(ctx, params)
+	if params.SortBy == "relevance" && params.Search == "" {
+		return nil, fmt.Errorf("%s: sorting by relevance requires a search query: %w", op, entity.ErrInvalidInput)
+	}
+
+	isOwnList := currentUserID != nil && params.UserID == *currentUserID
+	if !isOwnList {
+		if params.Status == "" {
+			params.Status = entity.AdStatusPublished
+		}
+		if params.Status != entity.AdStatusPublished {
+			return nil, fmt.Errorf("%s: only own ads can be listed with status %q: %w", op, params.Status, entity.ErrForbidden)
+		}
+	}
+
+	adsWithAuthor, next, err := s.repo.GetAll(ctx, params)
 	if err != nil {
+		if errors.Is(err, entity.ErrInvalidCursor) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
 		s.logger.Error("failed to get all ads", slog.String("op", op), slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	adIDs := make([]int64, len(adsWithAuthor))
+	for i, ad := range adsWithAuthor {
+		adIDs[i] = ad.ID
+	}
+	galleries, err := s.galleries(ctx, adIDs...)
+	if err != nil {
+		s.logger.Error("failed to get images of ads", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	response := make([]entity.AdResponse, len(adsWithAuthor))
 
 	for i, ad := range adsWithAuthor {
+		ad.Images = galleries[ad.ID]
 		res := entity.AdResponse{
 			AdWithAuthor: ad,
 		}
@@ -163,11 +282,82 @@ func (s AdService) GetAll(ctx context.Context, params entity.GetAdsQuery, curren
 		response[i] = res
 	}
 
-	return response, nil
+	adsPage := &entity.AdsPage{
+		Items:      response,
+		NextCursor: next,
+	}
+
+	if !params.SkipCount {
+		total, err := s.repo.Count(ctx, params)
+		if err != nil {
+			s.logger.Error("failed to count ads", slog.String("op", op), slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		adsPage.Total = &total
+	}
+
+	return adsPage, nil
+}
+
+// Transition moves an ad to another lifecycle status. Owners follow the ad lifecycle,
+// moderators can hide any ad and bring a hidden ad back to draft
+func (s AdService) Transition(ctx context.Context, adID int64, actor Actor, status entity.AdStatus) (*entity.Ad, error) {
+	const op = "service.AdService.Transition"
+
+	if _, ok := adTransitions[status]; !ok {
+		return nil, fmt.Errorf("%s: unknown status %q: %w", op, status, entity.ErrInvalidInput)
+	}
+
+	ad, err := s.repo.GetByID(ctx, adID)
+	if err != nil {
+		if errors.Is(err, entity.ErrAdNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to get ad by id for transition", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	moderating, err := authorize(ad, actor)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var allowed []entity.AdStatus
+	if !moderating {
+		allowed = adTransitions[ad.Status]
+	}
+	if actor.Role.CanModerate() {
+		allowed = append(slices.Clone(allowed), moderatorTransitions[ad.Status]...)
+	}
+
+	if !slices.Contains(allowed, status) {
+		return nil, fmt.Errorf("%s: from %q to %q: %w", op, ad.Status, status, entity.ErrInvalidAdTransition)
+	}
+
+	if err := s.repo.UpdateStatus(ctx, adID, ad.Status, status); err != nil {
+		if errors.Is(err, entity.ErrInvalidAdTransition) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to update ad status", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if moderating || status == entity.AdStatusHidden || ad.Status == entity.AdStatusHidden {
+		details := adAuditDetails(ad)
+		details["from"], details["to"] = string(ad.Status), string(status)
+		s.audit.record(ctx, actor, entity.AuditAdTransition, entity.AuditTargetAd, adID, details)
+	}
+
+	ad.Status = status
+	if err := s.attachImages(ctx, op, ad); err != nil {
+		return nil, err
+	}
+
+	return ad, nil
 }
 
-// Delete removes an ad if the user is authorized
-func (s AdService) Delete(ctx context.Context, adID, userID int64) error {
+// Delete marks an ad as deleted if the actor owns it or is a moderator, it can be restored during the restore window
+func (s AdService) Delete(ctx context.Context, adID int64, actor Actor) error {
 	const op = "service.AdService.Delete"
 
 	ad, err := s.repo.GetByID(ctx, adID)
@@ -179,8 +369,9 @@ func (s AdService) Delete(ctx context.Context, adID, userID int64) error {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	if ad.UserID != userID {
-		return fmt.Errorf("%s: %w", op, entity.ErrForbidden)
+	moderating, err := authorize(ad, actor)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 	if err := s.repo.Delete(ctx, adID); err != nil {
 		if errors.Is(err, entity.ErrAdNotFound) {
@@ -190,11 +381,101 @@ func (s AdService) Delete(ctx context.Context, adID, userID int64) error {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if moderating {
+		s.audit.record(ctx, actor, entity.AuditAdDelete, entity.AuditTargetAd, adID, adAuditDetails(ad))
+	}
+
 	return nil
 }
 
+// Restore brings back a deleted ad within the restore window, moderators can restore any ad
+func (s AdService) Restore(ctx context.Context, adID int64, actor Actor) (*entity.Ad, error) {
+	const op = "service.AdService.Restore"
+
+	ad, err := s.repo.GetDeletedByID(ctx, adID)
+	if err != nil {
+		if errors.Is(err, entity.ErrAdNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to get deleted ad by id", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	moderating, err := authorize(ad, actor)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deletedAfter := time.Now().Add(-s.restoreWindow)
+	if ad.DeletedAt.Before(deletedAfter) {
+		return nil, fmt.Errorf("%s: %w", op, entity.ErrAdRestoreExpired)
+	}
+
+	if err := s.repo.Restore(ctx, adID, deletedAfter); err != nil {
+		if errors.Is(err, entity.ErrAdNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, entity.ErrAdRestoreExpired)
+		}
+		s.logger.Error("failed to restore ad", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if moderating {
+		s.audit.record(ctx, actor, entity.AuditAdRestore, entity.AuditTargetAd, adID, adAuditDetails(ad))
+	}
+
+	ad.DeletedAt = nil
+	if err := s.attachImages(ctx, op, ad); err != nil {
+		return nil, err
+	}
+
+	return ad, nil
+}
+
+// PurgeDeleted permanently removes ads that were deleted longer than retention ago
+func (s AdService) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	const op = "service.AdService.PurgeDeleted"
+
+	deletedBefore := time.Now().Add(-retention)
+
+	keys, err := s.images.GetKeysOfDeletedAds(ctx, deletedBefore)
+	if err != nil {
+		s.logger.Error("failed to get images of deleted ads", slog.String("op", op), slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	purged, err := s.repo.PurgeDeleted(ctx, deletedBefore)
+	if err != nil {
+		s.logger.Error("failed to purge deleted ads", slog.String("op", op), slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.deleteObjects(ctx, op, keys...)
+
+	return purged, nil
+}
+
+// authorize checks that the actor may manage the ad: owners always can, moderators can manage any ad.
+// It reports whether a moderator acts on an ad of another user, such actions are audited
+func authorize(ad *entity.Ad, actor Actor) (moderating bool, err error) {
+	if ad.UserID == actor.UserID {
+		return false, nil
+	}
+	if actor.Role.CanModerate() {
+		return true, nil
+	}
+	return false, entity.ErrForbidden
+}
+
+// adAuditDetails describes an ad in audit log entries
+func adAuditDetails(ad *entity.Ad) map[string]string {
+	return map[string]string{
+		"owner_id": strconv.FormatInt(ad.UserID, 10),
+		"title":    ad.Title,
+	}
+}
+
 // validateInput checks if ad fields are correct
-func validateInput(title, description, imageURL string, price float64) error {
+func validateInput(title, description, imageURL string, price money.Money) error {
 	if len(title) < 1 || len(title) > 100 {
 		return fmt.Errorf("title length must be between 1 and 100: %w", entity.ErrInvalidInput)
 	}
@@ -206,8 +487,11 @@ func validateInput(title, description, imageURL string, price float64) error {
 			return fmt.Errorf("invalid umage url format: %w", entity.ErrInvalidInput)
 		}
 	}
-	if price < 0 {
+	if price.IsNegative() {
 		return fmt.Errorf("price cannot be negative: %w", entity.ErrInvalidInput)
 	}
+	if _, err := price.Currency.Exponent(); err != nil {
+		return fmt.Errorf("price currency: %w: %w", err, entity.ErrInvalidInput)
+	}
 	return nil
 }
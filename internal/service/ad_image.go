@@ -0,0 +1,436 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/pkg/storage"
+	"rest-api-marketplace/pkg/thumbnail"
+)
+
+// imageExtensions maps supported image content types to file extensions
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// variantContentTypes maps content types of decodable originals to the format of their variants
+var variantContentTypes = map[string]string{
+	"image/jpeg": "image/jpeg",
+	"image/png":  "image/png",
+	"image/gif":  "image/png",
+}
+
+// errUndecodableImage is returned when variants cannot be rendered from the original image
+var errUndecodableImage = errors.New("image cannot be decoded")
+
+// AddImage validates and stores an image uploaded by the ad owner
+func (s AdService) AddImage(ctx context.Context, adID, userID int64, r io.Reader) (*entity.AdImage, error) {
+	const op = "service.AdService.AddImage"
+
+	if err := s.checkAdOwner(ctx, op, adID, userID); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, s.imageOpts.MaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: read image: %w", op, err)
+	}
+	if int64(len(data)) > s.imageOpts.MaxSize {
+		return nil, fmt.Errorf("%s: limit is %d bytes: %w", op, s.imageOpts.MaxSize, entity.ErrImageTooLarge)
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%s: %s: %w", op, contentType, entity.ErrUnsupportedImage)
+	}
+
+	name, err := randomName()
+	if err != nil {
+		s.logger.Error("failed to generate image name", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	image := entity.AdImage{
+		AdID:        adID,
+		StorageKey:  fmt.Sprintf("ads/%d/%s%s", adID, name, ext),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}
+
+	if err := s.storage.Put(ctx, image.StorageKey, bytes.NewReader(data)); err != nil {
+		s.logger.Error("failed to store image", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	created, err := s.images.Create(ctx, image)
+	if err != nil {
+		s.deleteObjects(ctx, op, image.StorageKey)
+		if errors.Is(err, entity.ErrAdNotFound) || errors.Is(err, entity.ErrGalleryConflict) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to save image", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if s.imageOpts.Queue != nil {
+		s.imageOpts.Queue.Enqueue(created.ID)
+	}
+
+	created.URL = s.storage.URL(created.StorageKey)
+	created.Variants = []entity.AdImageVariant{}
+	return created, nil
+}
+
+// GenerateImageVariants creates downscaled copies of an image for every configured size.
+// Images that cannot be decoded are marked as processed without variants, images whose original is missing as failed
+func (s AdService) GenerateImageVariants(ctx context.Context, imageID int64) error {
+	const op = "service.AdService.GenerateImageVariants"
+
+	image, err := s.images.GetByID(ctx, imageID)
+	if err != nil {
+		if errors.Is(err, entity.ErrImageNotFound) {
+			return nil
+		}
+		s.logger.Error("failed to get image", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	variants, err := s.renderVariants(ctx, image)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			s.logger.Error("original image is missing, variants failed", slog.String("op", op), slog.Int64("image_id", imageID))
+			if err := s.images.MarkVariantsFailed(ctx, imageID); err != nil && !errors.Is(err, entity.ErrImageNotFound) {
+				s.logger.Error("failed to mark image variants failed", slog.String("op", op), slog.String("error", err.Error()))
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			return nil
+		}
+		if !errors.Is(err, errUndecodableImage) {
+			s.deleteObjects(ctx, op, variantKeys(variants)...)
+			s.logger.Error("failed to render image variants", slog.String("op", op), slog.Int64("image_id", imageID), slog.String("error", err.Error()))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Warn("image cannot be decoded, skipping variants", slog.String("op", op), slog.Int64("image_id", imageID), slog.String("error", err.Error()))
+	}
+
+	if err := s.images.SaveVariants(ctx, imageID, variants); err != nil {
+		s.deleteObjects(ctx, op, variantKeys(variants)...)
+		if errors.Is(err, entity.ErrImageNotFound) {
+			return nil
+		}
+		s.logger.Error("failed to save image variants", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PendingImages returns IDs of images still waiting for their variants
+func (s AdService) PendingImages(ctx context.Context, limit int) ([]int64, error) {
+	const op = "service.AdService.PendingImages"
+
+	ids, err := s.images.GetPendingVariants(ctx, limit)
+	if err != nil {
+		s.logger.Error("failed to get pending images", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+// renderVariants decodes the original image, stores its downscaled copies and returns them.
+// Sizes not smaller than the original are skipped
+func (s AdService) renderVariants(ctx context.Context, image *entity.AdImage) ([]entity.AdImageVariant, error) {
+	outputType, ok := variantContentTypes[image.ContentType]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", image.ContentType, errUndecodableImage)
+	}
+
+	rc, err := s.storage.Get(ctx, image.StorageKey)
+	if err != nil {
+		return nil, fmt.Errorf("open original: %w", err)
+	}
+	data, err := io.ReadAll(rc)
+	_ = rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read original: %w", err)
+	}
+
+	original, err := thumbnail.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errUndecodableImage, err)
+	}
+
+	base := strings.TrimSuffix(image.StorageKey, path.Ext(image.StorageKey))
+	variants := make([]entity.AdImageVariant, 0, len(s.imageOpts.VariantSizes))
+	for _, size := range s.imageOpts.VariantSizes {
+		resized, ok := thumbnail.Fit(original, size)
+		if !ok {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := thumbnail.Encode(&buf, resized, outputType); err != nil {
+			return variants, fmt.Errorf("encode %dpx variant: %w", size, err)
+		}
+
+		variant := entity.AdImageVariant{
+			ImageID:    image.ID,
+			Size:       size,
+			Width:      resized.Bounds().Dx(),
+			Height:     resized.Bounds().Dy(),
+			StorageKey: fmt.Sprintf("%s_%d%s", base, size, imageExtensions[outputType]),
+		}
+		if err := s.storage.Put(ctx, variant.StorageKey, &buf); err != nil {
+			return variants, fmt.Errorf("store %dpx variant: %w", size, err)
+		}
+		variants = append(variants, variant)
+	}
+
+	return variants, nil
+}
+
+// ReorderImages changes the gallery order, imageIDs must list every image of the ad exactly once
+func (s AdService) ReorderImages(ctx context.Context, adID, userID int64, imageIDs []int64) ([]entity.AdImage, error) {
+	const op = "service.AdService.ReorderImages"
+
+	if err := s.checkAdOwner(ctx, op, adID, userID); err != nil {
+		return nil, err
+	}
+
+	current, err := s.images.GetByAdIDs(ctx, []int64{adID})
+	if err != nil {
+		s.logger.Error("failed to get ad images", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	seen := make(map[int64]bool, len(imageIDs))
+	for _, id := range imageIDs {
+		if seen[id] {
+			return nil, fmt.Errorf("%s: duplicate image id %d: %w", op, id, entity.ErrInvalidInput)
+		}
+		seen[id] = true
+	}
+	if len(imageIDs) != len(current) {
+		return nil, fmt.Errorf("%s: all %d images of the ad must be listed: %w", op, len(current), entity.ErrInvalidInput)
+	}
+	for _, image := range current {
+		if !seen[image.ID] {
+			return nil, fmt.Errorf("%s: image %d is missing: %w", op, image.ID, entity.ErrInvalidInput)
+		}
+	}
+
+	if err := s.images.Reorder(ctx, adID, imageIDs); err != nil {
+		if errors.Is(err, entity.ErrImageNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to reorder ad images", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.gallery(ctx, op, adID)
+}
+
+// SetCoverImage makes the image the cover of the ad gallery
+func (s AdService) SetCoverImage(ctx context.Context, adID, userID, imageID int64) ([]entity.AdImage, error) {
+	const op = "service.AdService.SetCoverImage"
+
+	if err := s.checkAdOwner(ctx, op, adID, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.images.SetCover(ctx, adID, imageID); err != nil {
+		if errors.Is(err, entity.ErrImageNotFound) || errors.Is(err, entity.ErrGalleryConflict) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to set cover image", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.gallery(ctx, op, adID)
+}
+
+// DeleteImage removes the image from the ad gallery and from the storage
+func (s AdService) DeleteImage(ctx context.Context, adID, userID, imageID int64) error {
+	const op = "service.AdService.DeleteImage"
+
+	if err := s.checkAdOwner(ctx, op, adID, userID); err != nil {
+		return err
+	}
+
+	keys, err := s.images.Delete(ctx, adID, imageID)
+	if err != nil {
+		if errors.Is(err, entity.ErrImageNotFound) || errors.Is(err, entity.ErrGalleryConflict) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to delete image", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.deleteObjects(ctx, op, keys...)
+	return nil
+}
+
+// OpenImage opens a stored image by its storage key and returns it with its content type.
+// Images of an ad are only served while the ad is visible to the user: published, or owned by them
+func (s AdService) OpenImage(ctx context.Context, key string, currentUserID *int64) (io.ReadCloser, string, error) {
+	const op = "service.AdService.OpenImage"
+
+	adID, ok := adIDFromKey(key)
+	if !ok {
+		return nil, "", fmt.Errorf("%s: %w", op, entity.ErrImageNotFound)
+	}
+
+	ad, err := s.repo.GetByID(ctx, adID)
+	if err != nil {
+		if errors.Is(err, entity.ErrAdNotFound) {
+			return nil, "", fmt.Errorf("%s: %w", op, entity.ErrImageNotFound)
+		}
+		s.logger.Error("failed to get ad by id", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	isVisible := ad.Status == entity.AdStatusPublished || (currentUserID != nil && ad.UserID == *currentUserID)
+	if !isVisible {
+		return nil, "", fmt.Errorf("%s: %w", op, entity.ErrImageNotFound)
+	}
+
+	rc, err := s.storage.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			return nil, "", fmt.Errorf("%s: %w", op, entity.ErrImageNotFound)
+		}
+		s.logger.Error("failed to open image", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return rc, contentType, nil
+}
+
+// adIDFromKey returns the ID of the ad an image key of the form ads/<ad id>/<name> belongs to
+func adIDFromKey(key string) (int64, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 || parts[0] != "ads" {
+		return 0, false
+	}
+	adID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || adID <= 0 {
+		return 0, false
+	}
+	return adID, true
+}
+
+// checkAdOwner makes sure the ad exists and belongs to the user
+func (s AdService) checkAdOwner(ctx context.Context, op string, adID, userID int64) error {
+	ad, err := s.repo.GetByID(ctx, adID)
+	if err != nil {
+		if errors.Is(err, entity.ErrAdNotFound) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to get ad by id", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if ad.UserID != userID {
+		return fmt.Errorf("%s: %w", op, entity.ErrForbidden)
+	}
+	return nil
+}
+
+// galleries loads the images of each given ad with one query, keyed by ad ID
+func (s AdService) galleries(ctx context.Context, adIDs ...int64) (map[int64][]entity.AdImage, error) {
+	galleries := make(map[int64][]entity.AdImage, len(adIDs))
+	for _, id := range adIDs {
+		galleries[id] = []entity.AdImage{}
+	}
+	if len(adIDs) == 0 {
+		return galleries, nil
+	}
+
+	images, err := s.images.GetByAdIDs(ctx, adIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, image := range images {
+		image.URL = s.storage.URL(image.StorageKey)
+		if image.Variants == nil {
+			image.Variants = []entity.AdImageVariant{}
+		}
+		for i := range image.Variants {
+			image.Variants[i].URL = s.storage.URL(image.Variants[i].StorageKey)
+		}
+		galleries[image.AdID] = append(galleries[image.AdID], image)
+	}
+
+	return galleries, nil
+}
+
+// gallery loads the images of a single ad
+func (s AdService) gallery(ctx context.Context, op string, adID int64) ([]entity.AdImage, error) {
+	galleries, err := s.galleries(ctx, adID)
+	if err != nil {
+		s.logger.Error("failed to get ad images", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return galleries[adID], nil
+}
+
+// attachImages sets the gallery of a single ad
+func (s AdService) attachImages(ctx context.Context, op string, ad *entity.Ad) error {
+	images, err := s.gallery(ctx, op, ad.ID)
+	if err != nil {
+		return err
+	}
+
+	ad.Images = images
+	return nil
+}
+
+// deleteObjects removes stored objects, failures are only logged
+func (s AdService) deleteObjects(ctx context.Context, op string, keys ...string) {
+	for _, key := range keys {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			s.logger.Error("failed to delete stored object", slog.String("op", op), slog.String("key", key), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// variantKeys returns storage keys of the given variants
+func variantKeys(variants []entity.AdImageVariant) []string {
+	keys := make([]string, len(variants))
+	for i, v := range variants {
+		keys[i] = v.StorageKey
+	}
+	return keys
+}
+
+// randomName generates a random hex string used as a file name
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
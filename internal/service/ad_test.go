@@ -0,0 +1,18 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rest-api-marketplace/internal/entity"
+)
+
+func TestAdServiceGetAllRelevanceRequiresSearch(t *testing.T) {
+	var s AdService
+
+	_, err := s.GetAll(context.Background(), entity.GetAdsQuery{SortBy: "relevance"}, nil)
+	if !errors.Is(err, entity.ErrInvalidInput) {
+		t.Errorf("GetAll() sorted by relevance without a query error = %v, want %v", err, entity.ErrInvalidInput)
+	}
+}
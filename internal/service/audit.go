@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/internal/repository"
+)
+
+// auditor records privileged actions after they succeed
+type auditor struct {
+	repo   repository.AuditLog
+	logger *slog.Logger
+}
+
+// record appends an entry about the actor performing action on the target to the audit log.
+// The action is already done at this point, so a failed write is logged with the entry instead of returned
+func (a auditor) record(ctx context.Context, actor Actor, action entity.AuditAction, targetType string, targetID int64, details map[string]string) {
+	const op = "service.auditor.record"
+
+	err := a.repo.Create(ctx, entity.AuditEntry{
+		ActorID:    actor.UserID,
+		ActorRole:  actor.Role,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+	})
+	if err != nil {
+		a.logger.Error("failed to record audit entry", slog.String("op", op), slog.String("action", string(action)),
+			slog.Int64("actor_id", actor.UserID), slog.String("actor_role", string(actor.Role)),
+			slog.String("target_type", targetType), slog.Int64("target_id", targetID), slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/internal/repository"
+)
+
+// CategoryService provides operations to manage the category tree
+type CategoryService struct {
+	repo   repository.Categories
+	audit  auditor
+	logger *slog.Logger
+}
+
+// NewCategoryService creates a new CategoryService instance
+func NewCategoryService(repo repository.Categories, auditLog repository.AuditLog, logger *slog.Logger) *CategoryService {
+	return &CategoryService{
+		repo:   repo,
+		audit:  auditor{repo: auditLog, logger: logger},
+		logger: logger,
+	}
+}
+
+// Create validates input and creates a new category, only admins manage categories
+func (s CategoryService) Create(ctx context.Context, actor Actor, input CategoryInput) (*entity.Category, error) {
+	const op = "service.CategoryService.Create"
+
+	category := entity.Category{
+		ParentID: input.ParentID,
+		Name:     strings.TrimSpace(input.Name),
+	}
+	if actor.Role != entity.RoleAdmin {
+		return nil, fmt.Errorf("%s: %w", op, entity.ErrForbidden)
+	}
+	if err := validateCategory(category); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := s.repo.Create(ctx, category)
+	if err != nil {
+		if errors.Is(err, entity.ErrParentCategoryNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to create category", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.audit.record(ctx, actor, entity.AuditCategoryCreate, entity.AuditTargetCategory, id, map[string]string{"name": category.Name})
+
+	return s.repo.GetByID(ctx, id)
+}
+
+// Update renames a category or moves it under another parent
+func (s CategoryService) Update(ctx context.Context, actor Actor, id int64, input CategoryInput) (*entity.Category, error) {
+	const op = "service.CategoryService.Update"
+
+	if actor.Role != entity.RoleAdmin {
+		return nil, fmt.Errorf("%s: %w", op, entity.ErrForbidden)
+	}
+
+	category, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, entity.ErrCategoryNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to get category", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	category.ParentID = input.ParentID
+	category.Name = strings.TrimSpace(input.Name)
+	if err := validateCategory(*category); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if category.ParentID != nil {
+		if err := s.checkCycle(ctx, id, *category.ParentID); err != nil {
+			if errors.Is(err, entity.ErrParentCategoryNotFound) || errors.Is(err, entity.ErrCategoryCycle) {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			s.logger.Error("failed to check category cycle", slog.String("op", op), slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := s.repo.Update(ctx, id, *category); err != nil {
+		if errors.Is(err, entity.ErrCategoryNotFound) || errors.Is(err, entity.ErrParentCategoryNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to update category", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.audit.record(ctx, actor, entity.AuditCategoryUpdate, entity.AuditTargetCategory, id, map[string]string{"name": category.Name})
+
+	return category, nil
+}
+
+// GetByID retrieves a category by its ID
+func (s CategoryService) GetByID(ctx context.Context, id int64) (*entity.Category, error) {
+	const op = "service.CategoryService.GetByID"
+
+	category, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, entity.ErrCategoryNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to get category by id", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return category, nil
+}
+
+// GetTree returns all categories arranged as a tree of root categories
+func (s CategoryService) GetTree(ctx context.Context) ([]entity.CategoryNode, error) {
+	const op = "service.CategoryService.GetTree"
+
+	categories, err := s.repo.GetAll(ctx)
+	if err != nil {
+		s.logger.Error("failed to get categories", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	children := make(map[int64][]entity.Category)
+	var roots []entity.Category
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+			continue
+		}
+		children[*category.ParentID] = append(children[*category.ParentID], category)
+	}
+
+	return buildCategoryNodes(roots, children), nil
+}
+
+// Delete removes a category that has no subcategories
+func (s CategoryService) Delete(ctx context.Context, actor Actor, id int64) error {
+	const op = "service.CategoryService.Delete"
+
+	if actor.Role != entity.RoleAdmin {
+		return fmt.Errorf("%s: %w", op, entity.ErrForbidden)
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		if errors.Is(err, entity.ErrCategoryNotFound) || errors.Is(err, entity.ErrCategoryHasChildren) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to delete category", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.audit.record(ctx, actor, entity.AuditCategoryDelete, entity.AuditTargetCategory, id, nil)
+
+	return nil
+}
+
+// checkCycle makes sure that parentID is neither the category itself nor one of its descendants
+func (s CategoryService) checkCycle(ctx context.Context, id, parentID int64) error {
+	categories, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	parents := make(map[int64]*int64, len(categories))
+	for _, category := range categories {
+		parents[category.ID] = category.ParentID
+	}
+
+	if _, ok := parents[parentID]; !ok {
+		return entity.ErrParentCategoryNotFound
+	}
+
+	for current := &parentID; current != nil; current = parents[*current] {
+		if *current == id {
+			return entity.ErrCategoryCycle
+		}
+	}
+
+	return nil
+}
+
+// buildCategoryNodes recursively attaches subcategories to the given categories
+func buildCategoryNodes(categories []entity.Category, children map[int64][]entity.Category) []entity.CategoryNode {
+	nodes := make([]entity.CategoryNode, len(categories))
+	for i, category := range categories {
+		nodes[i] = entity.CategoryNode{
+			Category: category,
+			Children: buildCategoryNodes(children[category.ID], children),
+		}
+	}
+	return nodes
+}
+
+// validateCategory checks if category fields are correct
+func validateCategory(category entity.Category) error {
+	if len(category.Name) < 1 || len(category.Name) > 100 {
+		return fmt.Errorf("category name length must be between 1 and 100: %w", entity.ErrInvalidInput)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/internal/repository"
+)
+
+// staticCategories is a repository.Categories knowing a fixed set of categories, only reads are implemented
+type staticCategories struct {
+	repository.Categories
+	categories []entity.Category
+}
+
+func (s staticCategories) GetByID(_ context.Context, id int64) (*entity.Category, error) {
+	for _, category := range s.categories {
+		if category.ID == id {
+			return &category, nil
+		}
+	}
+	return nil, entity.ErrCategoryNotFound
+}
+
+func (s staticCategories) GetAll(_ context.Context) ([]entity.Category, error) {
+	return s.categories, nil
+}
+
+func TestCategoryServiceUpdateParent(t *testing.T) {
+	root, child := int64(1), int64(2)
+	s := CategoryService{
+		repo: staticCategories{categories: []entity.Category{
+			{ID: root, Name: "Electronics"},
+			{ID: child, ParentID: &root, Name: "Phones"},
+		}},
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	admin := Actor{UserID: 1, Role: entity.RoleAdmin}
+	missing, self := int64(99), root
+
+	tests := []struct {
+		name     string
+		id       int64
+		parentID *int64
+		wantErr  error
+	}{
+		{name: "missing category", id: missing, wantErr: entity.ErrCategoryNotFound},
+		{name: "missing parent", id: child, parentID: &missing, wantErr: entity.ErrParentCategoryNotFound},
+		{name: "nested under itself", id: root, parentID: &self, wantErr: entity.ErrCategoryCycle},
+		{name: "nested under its subcategory", id: root, parentID: &child, wantErr: entity.ErrCategoryCycle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Update(context.Background(), admin, tt.id, CategoryInput{ParentID: tt.parentID, Name: "Renamed"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Update() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == entity.ErrParentCategoryNotFound && errors.Is(err, entity.ErrCategoryNotFound) {
+				t.Error("missing parent is reported as a missing category")
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/internal/repository"
+	"rest-api-marketplace/pkg/money"
+)
+
+// ratePattern matches positive decimal rates that fit NUMERIC(24,12)
+var ratePattern = regexp.MustCompile(`^\d{1,12}(\.\d{1,12})?$`)
+
+// ExchangeRateService provides operations to manage currency exchange rates
+type ExchangeRateService struct {
+	repo   repository.ExchangeRates
+	logger *slog.Logger
+}
+
+// NewExchangeRateService creates a new ExchangeRateService instance
+func NewExchangeRateService(repo repository.ExchangeRates, logger *slog.Logger) *ExchangeRateService {
+	return &ExchangeRateService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Import reads "currency,rate" CSV records and stores them, an optional header line is skipped.
+// Nothing is stored if any record is invalid
+func (s ExchangeRateService) Import(ctx context.Context, r io.Reader) (int, error) {
+	const op = "service.ExchangeRateService.Import"
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	var rates []entity.ExchangeRate
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w: %w", op, err, entity.ErrInvalidInput)
+		}
+		if line == 1 && strings.EqualFold(record[0], "currency") {
+			continue
+		}
+
+		rate, err := parseExchangeRate(record[0], record[1])
+		if err != nil {
+			return 0, fmt.Errorf("%s: line %d: %w", op, line, err)
+		}
+		rates = append(rates, rate)
+	}
+
+	if len(rates) == 0 {
+		return 0, fmt.Errorf("%s: no rates found: %w", op, entity.ErrInvalidInput)
+	}
+
+	if err := s.repo.Upsert(ctx, rates); err != nil {
+		s.logger.Error("failed to save exchange rates", slog.String("op", op), slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return len(rates), nil
+}
+
+// GetAll returns all known exchange rates
+func (s ExchangeRateService) GetAll(ctx context.Context) ([]entity.ExchangeRate, error) {
+	const op = "service.ExchangeRateService.GetAll"
+
+	rates, err := s.repo.GetAll(ctx)
+	if err != nil {
+		s.logger.Error("failed to get exchange rates", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rates, nil
+}
+
+// parseExchangeRate validates a currency code and a rate of a single CSV record
+func parseExchangeRate(code, rate string) (entity.ExchangeRate, error) {
+	currency, err := money.ParseCurrency(code)
+	if err != nil {
+		return entity.ExchangeRate{}, fmt.Errorf("%w: %w", err, entity.ErrInvalidInput)
+	}
+	minorUnits, _ := currency.Exponent()
+
+	rate = strings.TrimSpace(rate)
+	if !ratePattern.MatchString(rate) || strings.Trim(rate, "0.") == "" {
+		return entity.ExchangeRate{}, fmt.Errorf("rate %q of %s must be a positive decimal: %w", rate, currency, entity.ErrInvalidInput)
+	}
+
+	return entity.ExchangeRate{
+		Currency:   currency,
+		Rate:       rate,
+		MinorUnits: minorUnits,
+	}, nil
+}
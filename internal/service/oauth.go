@@ -0,0 +1,345 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/pkg/auth"
+	"rest-api-marketplace/pkg/oidc"
+)
+
+const (
+	// oauthLoginAttempts is how many logins are tried for a user signed up with a provider before giving up
+	oauthLoginAttempts = 5
+	// oauthLoginMaxBase keeps generated logins within the sign-up limit after a suffix is appended
+	oauthLoginMaxBase = 20
+)
+
+// OAuthProviders returns the names of the configured identity providers
+func (s *UsersService) OAuthProviders() []string {
+	names := make([]string, 0, len(s.oauthOpts.Providers))
+	for name := range s.oauthOpts.Providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// StartOAuth begins an authorization code flow with PKCE at the provider and returns the URL to send the user to.
+// userID is set when a signed-in user links the provider account instead of signing in
+func (s *UsersService) StartOAuth(ctx context.Context, provider string, userID *int64) (OAuthStart, error) {
+	const op = "service.UsersService.StartOAuth"
+
+	p, ok := s.oauthOpts.Providers[provider]
+	if !ok {
+		return OAuthStart{}, fmt.Errorf("%s: %w", op, entity.ErrUnknownProvider)
+	}
+
+	state, err := auth.NewRandomToken()
+	if err != nil {
+		s.logger.Error("failed to create oauth state", slog.String("op", op), slog.String("error", err.Error()))
+		return OAuthStart{}, fmt.Errorf("%s: %w", op, err)
+	}
+	nonce, err := auth.NewRandomToken()
+	if err != nil {
+		s.logger.Error("failed to create oauth nonce", slog.String("op", op), slog.String("error", err.Error()))
+		return OAuthStart{}, fmt.Errorf("%s: %w", op, err)
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		s.logger.Error("failed to create code verifier", slog.String("op", op), slog.String("error", err.Error()))
+		return OAuthStart{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		s.logger.Error("failed to build authorization url", slog.String("op", op), slog.String("provider", provider), slog.String("error", err.Error()))
+		return OAuthStart{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	expiresAt := time.Now().Add(s.oauthOpts.StateTTL)
+	err = s.oauthStates.Create(ctx, entity.OAuthState{
+		StateHash:    auth.HashToken(state),
+		Provider:     provider,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		UserID:       userID,
+		ExpiresAt:    expiresAt,
+	})
+	if err != nil {
+		s.logger.Error("failed to store oauth state", slog.String("op", op), slog.String("error", err.Error()))
+		return OAuthStart{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return OAuthStart{AuthURL: authURL, State: state, ExpiresAt: expiresAt}, nil
+}
+
+// CompleteOAuth handles the provider callback: it redeems the code and either links the provider account
+// to the user who started the flow, or signs in the user the account belongs to. A user is found by a linked
+// identity, then by an email both sides verified, otherwise a new user without a password is registered.
+// The sign-in issues the same tokens as a password sign-in, including the two-factor challenge
+func (s *UsersService) CompleteOAuth(ctx context.Context, provider, code, state string, client ClientInfo) (OAuthResult, error) {
+	const op = "service.UsersService.CompleteOAuth"
+
+	p, ok := s.oauthOpts.Providers[provider]
+	if !ok {
+		return OAuthResult{}, fmt.Errorf("%s: %w", op, entity.ErrUnknownProvider)
+	}
+	if state == "" {
+		return OAuthResult{}, fmt.Errorf("%s: %w", op, entity.ErrInvalidOAuthState)
+	}
+
+	pending, err := s.oauthStates.Consume(ctx, auth.HashToken(state))
+	if err != nil {
+		if errors.Is(err, entity.ErrInvalidOAuthState) {
+			return OAuthResult{}, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to consume oauth state", slog.String("op", op), slog.String("error", err.Error()))
+		return OAuthResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if pending.Provider != provider {
+		return OAuthResult{}, fmt.Errorf("%s: %w", op, entity.ErrInvalidOAuthState)
+	}
+	if code == "" {
+		return OAuthResult{}, fmt.Errorf("%s: %w", op, entity.ErrExternalAuthFailed)
+	}
+
+	ident, err := p.Exchange(ctx, code, pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		var oauthErr *oidc.Error
+		if errors.Is(err, oidc.ErrInvalidIDToken) || errors.As(err, &oauthErr) {
+			s.logger.Warn("security: external sign-in rejected",
+				slog.String("op", op),
+				slog.String("event", "oauth_rejected"),
+				slog.String("provider", provider),
+				slog.String("ip", client.IP),
+				slog.String("error", err.Error()),
+			)
+			return OAuthResult{}, fmt.Errorf("%s: %w", op, entity.ErrExternalAuthFailed)
+		}
+		s.logger.Error("failed to exchange authorization code", slog.String("op", op), slog.String("provider", provider), slog.String("error", err.Error()))
+		return OAuthResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if pending.UserID != nil {
+		identity, err := s.linkIdentity(ctx, op, *pending.UserID, provider, ident)
+		if err != nil {
+			return OAuthResult{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return OAuthResult{Linked: identity}, nil
+	}
+
+	user, err := s.userForIdentity(ctx, op, provider, ident)
+	if err != nil {
+		return OAuthResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := s.finishSignIn(ctx, op, *user, client)
+	if err != nil {
+		return OAuthResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return OAuthResult{SignIn: res}, nil
+}
+
+// GetIdentities returns the external identities linked to the user
+func (s *UsersService) GetIdentities(ctx context.Context, userID int64) ([]entity.Identity, error) {
+	const op = "service.UsersService.GetIdentities"
+
+	identities, err := s.identities.GetByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get identities", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return identities, nil
+}
+
+// UnlinkIdentity removes an external identity of the user. Users without a password
+// must keep at least one identity, otherwise they couldn't sign in anymore
+func (s *UsersService) UnlinkIdentity(ctx context.Context, userID, identityID int64) error {
+	const op = "service.UsersService.UnlinkIdentity"
+
+	if err := s.identities.Delete(ctx, identityID, userID); err != nil {
+		if errors.Is(err, entity.ErrIdentityNotFound) || errors.Is(err, entity.ErrLastSignInMethod) ||
+			errors.Is(err, entity.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to delete identity", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// linkIdentity links a provider account to the user who started the flow
+func (s *UsersService) linkIdentity(ctx context.Context, op string, userID int64, provider string, ident oidc.Identity) (*entity.Identity, error) {
+	identity := entity.Identity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  ident.Subject,
+		Email:    identityEmail(ident),
+	}
+
+	id, err := s.identities.Create(ctx, identity)
+	if err != nil {
+		if errors.Is(err, entity.ErrIdentityLinked) || errors.Is(err, entity.ErrProviderLinked) {
+			return nil, err
+		}
+		s.logger.Error("failed to link identity", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	s.logger.Info("security: external identity linked",
+		slog.String("op", op),
+		slog.String("event", "identity_linked"),
+		slog.Int64("user_id", userID),
+		slog.String("provider", provider),
+	)
+
+	identity.ID = id
+	identity.CreatedAt = time.Now()
+	return &identity, nil
+}
+
+// userForIdentity finds the user a provider account signs in, linking or registering one when needed
+func (s *UsersService) userForIdentity(ctx context.Context, op, provider string, ident oidc.Identity) (*entity.User, error) {
+	linked, err := s.identities.GetBySubject(ctx, provider, ident.Subject)
+	if err == nil {
+		user, err := s.repo.GetByID(ctx, linked.UserID)
+		if err != nil {
+			s.logger.Error("failed to get identity user", slog.String("op", op), slog.String("error", err.Error()))
+			return nil, err
+		}
+		return user, nil
+	}
+	if !errors.Is(err, entity.ErrIdentityNotFound) {
+		s.logger.Error("failed to get identity", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	email := identityEmail(ident)
+	if email != "" {
+		user, err := s.repo.GetByEmail(ctx, email)
+		switch {
+		case err == nil:
+			// an unverified email on either side would let anyone claim the account by its address
+			if !ident.EmailVerified || !user.EmailVerified() {
+				return nil, entity.ErrEmailTaken
+			}
+			if _, err := s.linkIdentity(ctx, op, user.ID, provider, ident); err != nil {
+				return nil, err
+			}
+			return user, nil
+		case !errors.Is(err, entity.ErrUserNotFound):
+			s.logger.Error("failed to get user by email", slog.String("op", op), slog.String("error", err.Error()))
+			return nil, err
+		}
+	}
+
+	return s.signUpWithIdentity(ctx, op, provider, ident)
+}
+
+// signUpWithIdentity registers a user without a password for a provider account,
+// the user can set a password later through the password reset
+func (s *UsersService) signUpWithIdentity(ctx context.Context, op, provider string, ident oidc.Identity) (*entity.User, error) {
+	identity := entity.Identity{
+		Provider: provider,
+		Subject:  ident.Subject,
+		Email:    identityEmail(ident),
+	}
+	user := entity.User{Email: identity.Email}
+	if ident.EmailVerified && user.Email != "" {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	base := oauthLoginBase(ident)
+	var (
+		userID int64
+		err    error
+	)
+	for attempt := 0; attempt < oauthLoginAttempts; attempt++ {
+		user.Login = base
+		if attempt > 0 {
+			suffix := make([]byte, 3)
+			if _, err := rand.Read(suffix); err != nil {
+				return nil, err
+			}
+			user.Login = base + "-" + hex.EncodeToString(suffix)
+		}
+
+		userID, err = s.identities.CreateWithUser(ctx, user, identity)
+		if !errors.Is(err, entity.ErrUserExists) {
+			break
+		}
+	}
+	if err != nil {
+		if errors.Is(err, entity.ErrEmailTaken) || errors.Is(err, entity.ErrIdentityLinked) {
+			return nil, err
+		}
+		s.logger.Error("failed to create user", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	created, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to retrieve created user", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	s.logger.Info("user signed up with external identity", slog.String("op", op), slog.Int64("user_id", userID), slog.String("provider", provider))
+
+	if created.Email != "" && !created.EmailVerified() {
+		if err := s.sendVerification(ctx, created.ID, created.Email); err != nil {
+			s.logger.Error("failed to send verification email", slog.String("op", op), slog.String("error", err.Error()))
+		}
+	}
+
+	return created, nil
+}
+
+// identityEmail returns the normalized email of the provider account, empty if it has none or it is malformed
+func identityEmail(ident oidc.Identity) string {
+	email, err := normalizeEmail(ident.Email)
+	if err != nil {
+		return ""
+	}
+	return email
+}
+
+// oauthLoginBase derives a login from the provider account: its username, the name of its email or its display name
+func oauthLoginBase(ident oidc.Identity) string {
+	emailName, _, _ := strings.Cut(ident.Email, "@")
+
+	for _, candidate := range []string{ident.PreferredUsername, emailName, ident.Name} {
+		login := strings.Map(func(r rune) rune {
+			switch {
+			case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)), r == '.', r == '_', r == '-':
+				return unicode.ToLower(r)
+			case unicode.IsSpace(r):
+				return '.'
+			default:
+				return -1
+			}
+		}, candidate)
+		login = strings.Trim(login, ".-_")
+		if len(login) > oauthLoginMaxBase {
+			login = login[:oauthLoginMaxBase]
+		}
+		if len(login) >= 3 {
+			return login
+		}
+	}
+
+	return "user"
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/pkg/breached"
+)
+
+// Password policy rules, reported in PasswordPolicyError
+const (
+	PasswordRuleMinLength      = "min_length"
+	PasswordRuleMaxLength      = "max_length"
+	PasswordRuleMaxBytes       = "max_bytes"
+	PasswordRuleCharClasses    = "character_classes"
+	PasswordRuleSimilarToLogin = "similar_to_login"
+	PasswordRuleBreached       = "breached"
+)
+
+// minIdentifierLength keeps very short logins from rejecting every password that happens to contain them
+const minIdentifierLength = 3
+
+// PasswordPolicy holds the rules new passwords must follow
+type PasswordPolicy struct {
+	// MinLength and MaxLength count characters, not bytes
+	MinLength int
+	MaxLength int
+	// MaxBytes limits the UTF-8 length for hashers that refuse longer passwords, like bcrypt past 72 bytes.
+	// Zero means no limit
+	MaxBytes int
+	// MinCharClasses is how many of lowercase letters, uppercase letters, digits and other characters are required
+	MinCharClasses int
+	// RejectSimilarToLogin rejects passwords that contain the login or the email name, as is, reversed or the other way around
+	RejectSimilarToLogin bool
+	// Breached is a list of passwords exposed in data breaches, nil disables the check
+	Breached breached.Source
+}
+
+// Validate checks the password against every rule and returns a PasswordPolicyError naming the first failed one.
+// Identifiers are the login and the email of the user
+func (p PasswordPolicy) Validate(ctx context.Context, password string, identifiers ...string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return &entity.PasswordPolicyError{
+			Rule:   PasswordRuleMinLength,
+			Reason: fmt.Sprintf("password must be at least %d characters long", p.MinLength),
+		}
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return &entity.PasswordPolicyError{
+			Rule:   PasswordRuleMaxLength,
+			Reason: fmt.Sprintf("password must be at most %d characters long", p.MaxLength),
+		}
+	}
+
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		return &entity.PasswordPolicyError{
+			Rule:   PasswordRuleMaxBytes,
+			Reason: fmt.Sprintf("password must be at most %d bytes long, characters outside of English letters, digits and symbols take several bytes", p.MaxBytes),
+		}
+	}
+
+	if classes := charClasses(password); classes < p.MinCharClasses {
+		return &entity.PasswordPolicyError{
+			Rule:   PasswordRuleCharClasses,
+			Reason: fmt.Sprintf("password must mix at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinCharClasses),
+		}
+	}
+
+	if p.RejectSimilarToLogin && similarToIdentifiers(password, identifiers) {
+		return &entity.PasswordPolicyError{
+			Rule:   PasswordRuleSimilarToLogin,
+			Reason: "password must not contain your login or email",
+		}
+	}
+
+	if p.Breached != nil {
+		found, err := breached.Contains(ctx, p.Breached, password)
+		if err != nil {
+			return fmt.Errorf("check breached passwords: %w", err)
+		}
+		if found {
+			return &entity.PasswordPolicyError{
+				Rule:   PasswordRuleBreached,
+				Reason: "password appears in a list of passwords exposed in data breaches, choose another one",
+			}
+		}
+	}
+
+	return nil
+}
+
+// charClasses counts which of lowercase letters, uppercase letters, digits and other characters the password uses
+func charClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	count := 0
+	for _, used := range []bool{lower, upper, digit, other} {
+		if used {
+			count++
+		}
+	}
+	return count
+}
+
+// similarToIdentifiers reports whether the password contains an identifier, as is or reversed,
+// or is a part of one, ignoring case and everything but letters and digits
+func similarToIdentifiers(password string, identifiers []string) bool {
+	password = alphanumeric(password)
+	if password == "" {
+		return false
+	}
+
+	for _, identifier := range identifiers {
+		// for emails only the name is personal, the domain is shared by many users
+		identifier, _, _ = strings.Cut(identifier, "@")
+		identifier = alphanumeric(identifier)
+		if utf8.RuneCountInString(identifier) < minIdentifierLength {
+			continue
+		}
+
+		if strings.Contains(password, identifier) ||
+			strings.Contains(password, reverse(identifier)) ||
+			strings.Contains(identifier, password) {
+			return true
+		}
+	}
+	return false
+}
+
+// alphanumeric lowercases the string and drops everything but letters and digits
+func alphanumeric(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+// reverse returns the string with its characters in reverse order
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
@@ -3,6 +3,7 @@ package service
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"time"
 
@@ -10,58 +11,214 @@ import (
 	"rest-api-marketplace/internal/repository"
 	"rest-api-marketplace/pkg/auth"
 	"rest-api-marketplace/pkg/hash"
+	"rest-api-marketplace/pkg/mailer"
+	"rest-api-marketplace/pkg/money"
+	"rest-api-marketplace/pkg/oidc"
+	"rest-api-marketplace/pkg/storage"
 )
 
-// UserInput represents user credentials input
+// UserInput represents user credentials input, email is only used on sign up
 type UserInput struct {
 	Login    string
+	Email    string
 	Password string
 }
 
+// Actor is the authenticated user performing an action
+type Actor struct {
+	UserID int64
+	Role   entity.Role
+}
+
+// ClientInfo describes the device a session is created from
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
 // Tokens contains access and refresh JWT tokens
 type Tokens struct {
 	AccessToken  string
 	RefreshToken string
 }
 
+// SignInResult is the outcome of the password step of a sign-in. Users with two-factor authentication
+// get a challenge to pass with a code instead of tokens
+type SignInResult struct {
+	Tokens             Tokens
+	ChallengeToken     string
+	ChallengeExpiresAt time.Time
+}
+
+// TwoFactorRequired reports whether the sign-in has to be finished with a second factor
+func (r SignInResult) TwoFactorRequired() bool {
+	return r.ChallengeToken != ""
+}
+
+// TOTPEnrollment is a new authenticator app secret waiting for confirmation
+type TOTPEnrollment struct {
+	Secret string
+	// URI is the otpauth:// URI authenticator apps import, usually shown as a QR code
+	URI string
+}
+
+// OAuthStart is the beginning of an external sign-in, the user is sent to AuthURL
+type OAuthStart struct {
+	AuthURL string
+	// State comes back with the provider callback, the client keeps it to prove the callback is its own
+	State     string
+	ExpiresAt time.Time
+}
+
+// OAuthResult is the outcome of an external sign-in callback. Linked is set instead of SignIn
+// when a signed-in user linked the provider account to their user
+type OAuthResult struct {
+	SignIn SignInResult
+	Linked *entity.Identity
+}
+
 // CreateAdInput is used to create a new ad
 type CreateAdInput struct {
+	CategoryID  *int64
 	Title       string
 	Description string
 	ImageURL    string
-	Price       float64
+	Price       money.Money
+	Status      entity.AdStatus
 }
 
 // UpdateAdInput is used to update an existing ad
 type UpdateAdInput struct {
-	Title       *string  `json:"title,omitempty"`
-	Description *string  `json:"description,omitempty"`
-	ImageURL    *string  `json:"image_url,omitempty"`
-	Price       *float64 `json:"price,omitempty"`
+	CategoryID  *int64       `json:"category_id,omitempty"`
+	Title       *string      `json:"title,omitempty"`
+	Description *string      `json:"description,omitempty"`
+	ImageURL    *string      `json:"image_url,omitempty"`
+	Price       *money.Money `json:"price,omitempty"`
+}
+
+// CategoryInput is used to create or update a category
+type CategoryInput struct {
+	ParentID *int64
+	Name     string
+}
+
+// ImageQueue schedules background processing of uploaded ad images
+type ImageQueue interface {
+	Enqueue(imageID int64)
+}
+
+// ImageOptions holds limits and processing settings of uploaded ad images
+type ImageOptions struct {
+	MaxSize      int64
+	VariantSizes []int
+	Queue        ImageQueue
+}
+
+// EmailOptions holds settings of emails sent to users for email verification and password reset
+type EmailOptions struct {
+	Mailer          mailer.Mailer
+	VerificationTTL time.Duration
+	// VerificationURL is the page users open to confirm their email, the token is appended to it
+	VerificationURL  string
+	PasswordResetTTL time.Duration
+	// PasswordResetURL is the page users open to choose a new password, the token is appended to it
+	PasswordResetURL string
+	// RequireVerified blocks ad creation until the user verifies their email
+	RequireVerified bool
+}
+
+// TwoFactorOptions holds settings of two-factor authentication
+type TwoFactorOptions struct {
+	// Issuer names the service in authenticator apps
+	Issuer string
+	// ChallengeTTL is how long users have to enter a code after the password step of a sign-in
+	ChallengeTTL time.Duration
+}
+
+// OAuthProvider is an OpenID Connect provider users can sign in with
+type OAuthProvider interface {
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (oidc.Identity, error)
+}
+
+// OAuthOptions holds settings of signing in with external identity providers
+type OAuthOptions struct {
+	// Providers are keyed by the name used in URLs
+	Providers map[string]OAuthProvider
+	// StateTTL is how long users have to sign in at the provider
+	StateTTL time.Duration
 }
 
 // Users defines the interface for user-related operations
 type Users interface {
-	SignUp(ctx context.Context, input UserInput) (*entity.User, error)
-	SignIn(ctx context.Context, input UserInput) (Tokens, error)
-	RefreshTokens(ctx context.Context, refreshToken string) (Tokens, error)
-	createSession(ctx context.Context, id int64) (Tokens, error)
+	SignUp(ctx context.Context, input UserInput) error
+	SignIn(ctx context.Context, input UserInput, client ClientInfo) (SignInResult, error)
+	SignInTwoFactor(ctx context.Context, challengeToken, code string, client ClientInfo) (Tokens, error)
+	RefreshTokens(ctx context.Context, refreshToken string, client ClientInfo) (Tokens, error)
+	GetSessions(ctx context.Context, userID int64) ([]entity.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID int64) error
+	SignOut(ctx context.Context, claims *auth.TokenClaims) error
+	SignOutAll(ctx context.Context, userID int64) error
+	SetRole(ctx context.Context, actor Actor, userID int64, role entity.Role) (*entity.User, error)
+	SetEmail(ctx context.Context, userID int64, email string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+	ChangePassword(ctx context.Context, userID int64, oldPassword, newPassword string) error
+	EnrollTOTP(ctx context.Context, userID int64) (TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID int64, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userID int64, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) ([]string, error)
+	GetLockouts(ctx context.Context, userID int64) ([]entity.Lockout, error)
+	OAuthProviders() []string
+	StartOAuth(ctx context.Context, provider string, userID *int64) (OAuthStart, error)
+	CompleteOAuth(ctx context.Context, provider, code, state string, client ClientInfo) (OAuthResult, error)
+	GetIdentities(ctx context.Context, userID int64) ([]entity.Identity, error)
+	UnlinkIdentity(ctx context.Context, userID, identityID int64) error
+	createSession(ctx context.Context, user entity.User, client ClientInfo) (Tokens, error)
 }
 
 // Ads defines the interface for ad-related operations
 type Ads interface {
 	Create(ctx context.Context, input CreateAdInput, userID int64) (*entity.Ad, error)
-	Update(ctx context.Context, adID, userID int64, input UpdateAdInput) (*entity.Ad, error)
+	Update(ctx context.Context, adID int64, actor Actor, input UpdateAdInput) (*entity.Ad, error)
 	GetByID(ctx context.Context, id int64) (*entity.Ad, error)
-	GetByIDWithAuthor(ctx context.Context, id int64, currentUserID *int64) (*entity.AdResponse, error)
-	GetAll(ctx context.Context, params entity.GetAdsQuery, currentUserID *int64) ([]entity.AdResponse, error)
-	Delete(ctx context.Context, adID, userID int64) error
+	GetByIDWithAuthor(ctx context.Context, id int64, currency money.Currency, currentUserID *int64) (*entity.AdResponse, error)
+	GetAll(ctx context.Context, params entity.GetAdsQuery, currentUserID *int64) (*entity.AdsPage, error)
+	Transition(ctx context.Context, adID int64, actor Actor, status entity.AdStatus) (*entity.Ad, error)
+	Delete(ctx context.Context, adID int64, actor Actor) error
+	Restore(ctx context.Context, adID int64, actor Actor) (*entity.Ad, error)
+	PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error)
+	AddImage(ctx context.Context, adID, userID int64, r io.Reader) (*entity.AdImage, error)
+	ReorderImages(ctx context.Context, adID, userID int64, imageIDs []int64) ([]entity.AdImage, error)
+	SetCoverImage(ctx context.Context, adID, userID, imageID int64) ([]entity.AdImage, error)
+	DeleteImage(ctx context.Context, adID, userID, imageID int64) error
+	GenerateImageVariants(ctx context.Context, imageID int64) error
+	PendingImages(ctx context.Context, limit int) ([]int64, error)
+	OpenImage(ctx context.Context, key string, currentUserID *int64) (io.ReadCloser, string, error)
+}
+
+// Categories defines the interface for category-related operations
+type Categories interface {
+	Create(ctx context.Context, actor Actor, input CategoryInput) (*entity.Category, error)
+	Update(ctx context.Context, actor Actor, id int64, input CategoryInput) (*entity.Category, error)
+	GetByID(ctx context.Context, id int64) (*entity.Category, error)
+	GetTree(ctx context.Context) ([]entity.CategoryNode, error)
+	Delete(ctx context.Context, actor Actor, id int64) error
+}
+
+// ExchangeRates defines the interface for managing currency exchange rates
+type ExchangeRates interface {
+	Import(ctx context.Context, r io.Reader) (int, error)
+	GetAll(ctx context.Context) ([]entity.ExchangeRate, error)
 }
 
 // Services aggregates all service implementations
 type Services struct {
-	Users Users
-	Ads   Ads
+	Users         Users
+	Ads           Ads
+	Categories    Categories
+	ExchangeRates ExchangeRates
 }
 
 // Deps contains dependencies required to initialize services
@@ -69,17 +226,30 @@ type Deps struct {
 	Logger          *slog.Logger
 	Repos           *repository.Repositories
 	Hasher          hash.PasswordHasher
+	PasswordPolicy  PasswordPolicy
 	TokenManager    auth.TokenManager
+	Revocations     auth.RevocationStore
+	LoginLimiter    *auth.LoginLimiter
+	Storage         storage.Storage
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
+	AdRestoreWindow time.Duration
+	Images          ImageOptions
+	Email           EmailOptions
+	TwoFactor       TwoFactorOptions
+	OAuth           OAuthOptions
 }
 
 // NewServices initializes all services with dependencies
 func NewServices(deps Deps) *Services {
-	usersService := NewUsersService(deps.Repos.Users, deps.Logger, deps.Hasher, deps.TokenManager, deps.AccessTokenTTL, deps.RefreshTokenTTL)
-	adsService := NewAdService(deps.Repos.Ads, deps.Logger)
+	usersService := NewUsersService(deps.Repos.Users, deps.Repos.Sessions, deps.Repos.EmailVerifications, deps.Repos.PasswordResets, deps.Repos.TwoFactor, deps.Repos.Identities, deps.Repos.OAuthStates, deps.Repos.Lockouts, deps.Repos.AuditLog, deps.Revocations, deps.LoginLimiter, deps.Logger, deps.Hasher, deps.PasswordPolicy, deps.TokenManager, deps.AccessTokenTTL, deps.RefreshTokenTTL, deps.Email, deps.TwoFactor, deps.OAuth)
+	adsService := NewAdService(deps.Repos.Ads, deps.Repos.AdImages, deps.Repos.Users, deps.Repos.AuditLog, deps.Storage, deps.Logger, deps.AdRestoreWindow, deps.Email.RequireVerified, deps.Images)
+	categoriesService := NewCategoryService(deps.Repos.Categories, deps.Repos.AuditLog, deps.Logger)
+	exchangeRatesService := NewExchangeRateService(deps.Repos.ExchangeRates, deps.Logger)
 	return &Services{
-		Users: usersService,
-		Ads:   adsService,
+		Users:         usersService,
+		Ads:           adsService,
+		Categories:    categoriesService,
+		ExchangeRates: exchangeRatesService,
 	}
 }
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/pkg/auth"
+	"rest-api-marketplace/pkg/totp"
+)
+
+const (
+	// totpSkew is how many time steps back and forth a code is accepted, to allow for clock drift
+	totpSkew = 1
+	// challengeMaxAttempts is how many wrong codes can be entered for one sign-in challenge
+	challengeMaxAttempts = 5
+	// recoveryCodeCount is how many recovery codes a user gets at once
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet is the lowercase base32 alphabet, it has no 0, 1 and 8 to mistake for letters
+	recoveryCodeAlphabet = "abcdefghijklmnopqrstuvwxyz234567"
+)
+
+// EnrollTOTP generates a new authenticator app secret for the user. Two-factor authentication
+// is only enabled once the secret is confirmed with a code by ConfirmTOTP
+func (s *UsersService) EnrollTOTP(ctx context.Context, userID int64) (TOTPEnrollment, error) {
+	const op = "service.UsersService.EnrollTOTP"
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, entity.ErrUserNotFound) {
+			return TOTPEnrollment{}, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to get user", slog.String("op", op), slog.String("error", err.Error()))
+		return TOTPEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		s.logger.Error("failed to generate totp secret", slog.String("op", op), slog.String("error", err.Error()))
+		return TOTPEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.twoFactor.SaveTOTP(ctx, userID, secret); err != nil {
+		if errors.Is(err, entity.ErrTwoFactorEnabled) {
+			return TOTPEnrollment{}, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to save totp secret", slog.String("op", op), slog.String("error", err.Error()))
+		return TOTPEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(s.twoFactorOpts.Issuer, user.Login, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication once the user proves the authenticator app
+// produces valid codes, and returns recovery codes. They are only shown this once
+func (s *UsersService) ConfirmTOTP(ctx context.Context, userID int64, code string) ([]string, error) {
+	const op = "service.UsersService.ConfirmTOTP"
+
+	secret, err := s.twoFactor.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, entity.ErrTwoFactorNotEnabled) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to get totp secret", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if secret.Enabled() {
+		return nil, fmt.Errorf("%s: %w", op, entity.ErrTwoFactorEnabled)
+	}
+
+	step, ok := totp.Validate(secret.Secret, strings.TrimSpace(code), time.Now(), totpSkew)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, entity.ErrInvalidTwoFactorCode)
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		s.logger.Error("failed to generate recovery codes", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.twoFactor.EnableTOTP(ctx, userID, step, hashes); err != nil {
+		if errors.Is(err, entity.ErrTwoFactorEnabled) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to enable totp", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor authentication off after checking a code from the app or a recovery code
+func (s *UsersService) DisableTOTP(ctx context.Context, userID int64, code string) error {
+	const op = "service.UsersService.DisableTOTP"
+
+	if err := s.confirmSecondFactor(ctx, op, userID, code); err != nil {
+		if errors.Is(err, entity.ErrTwoFactorNotEnabled) || errors.Is(err, entity.ErrInvalidTwoFactorCode) ||
+			errors.Is(err, entity.ErrTooManyAttempts) || errors.Is(err, entity.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to check two-factor code", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.twoFactor.DeleteTOTP(ctx, userID); err != nil {
+		if errors.Is(err, entity.ErrTwoFactorNotEnabled) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to disable totp", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the user after checking a code
+// from the app or a recovery code, and returns the new ones
+func (s *UsersService) RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) ([]string, error) {
+	const op = "service.UsersService.RegenerateRecoveryCodes"
+
+	if err := s.confirmSecondFactor(ctx, op, userID, code); err != nil {
+		if errors.Is(err, entity.ErrTwoFactorNotEnabled) || errors.Is(err, entity.ErrInvalidTwoFactorCode) ||
+			errors.Is(err, entity.ErrTooManyAttempts) || errors.Is(err, entity.ErrUserNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to check two-factor code", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		s.logger.Error("failed to generate recovery codes", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.twoFactor.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		s.logger.Error("failed to replace recovery codes", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return codes, nil
+}
+
+// SignInTwoFactor finishes a sign-in with a code from the app or a recovery code and issues JWT tokens.
+// The challenge works once and is dropped after too many wrong codes. Wrong codes also count as failed
+// sign-ins of the login, so new challenges don't give new tries
+func (s *UsersService) SignInTwoFactor(ctx context.Context, challengeToken, code string, client ClientInfo) (Tokens, error) {
+	const op = "service.UsersService.SignInTwoFactor"
+
+	if challengeToken == "" {
+		return Tokens{}, fmt.Errorf("%s: %w", op, entity.ErrInvalidChallenge)
+	}
+	tokenHash := auth.HashToken(challengeToken)
+
+	challenge, err := s.twoFactor.AttemptChallenge(ctx, tokenHash, challengeMaxAttempts)
+	if err != nil {
+		if errors.Is(err, entity.ErrInvalidChallenge) {
+			return Tokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to get two-factor challenge", slog.String("op", op), slog.String("error", err.Error()))
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.repo.GetByID(ctx, challenge.UserID)
+	if err != nil {
+		s.logger.Error("failed to get challenge user", slog.String("op", op), slog.String("error", err.Error()))
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	attempt, err := s.verifySecondFactor(ctx, op, *user, code, client)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrTooManyAttempts), errors.Is(err, entity.ErrInvalidTwoFactorCode):
+			return Tokens{}, fmt.Errorf("%s: %w", op, err)
+		case errors.Is(err, entity.ErrTwoFactorNotEnabled):
+			// turned off after the password step, the challenge is no longer meaningful
+			return Tokens{}, fmt.Errorf("%s: %w", op, entity.ErrInvalidChallenge)
+		default:
+			s.logger.Error("failed to check two-factor code", slog.String("op", op), slog.String("error", err.Error()))
+			return Tokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := s.twoFactor.ConsumeChallenge(ctx, tokenHash); err != nil {
+		s.releaseAttempt(ctx, op, attempt)
+		if errors.Is(err, entity.ErrInvalidChallenge) {
+			return Tokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to consume two-factor challenge", slog.String("op", op), slog.String("error", err.Error()))
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err := s.createSession(ctx, *user, client)
+	if err != nil {
+		s.releaseAttempt(ctx, op, attempt)
+		return Tokens{}, err
+	}
+
+	if err := s.limiter.Succeed(ctx, attempt); err != nil {
+		s.logger.Error("failed to reset sign-in attempts", slog.String("op", op), slog.String("error", err.Error()))
+	}
+
+	return tokens, nil
+}
+
+// twoFactorEnabled reports whether sign-in of the user requires a second factor
+func (s *UsersService) twoFactorEnabled(ctx context.Context, userID int64) (bool, error) {
+	secret, err := s.twoFactor.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, entity.ErrTwoFactorNotEnabled) {
+			return false, nil
+		}
+		return false, err
+	}
+	return secret.Enabled(), nil
+}
+
+// createChallenge stores a sign-in challenge for the user and returns its token
+func (s *UsersService) createChallenge(ctx context.Context, userID int64) (SignInResult, error) {
+	token, err := auth.NewRandomToken()
+	if err != nil {
+		return SignInResult{}, fmt.Errorf("challenge token: %w", err)
+	}
+
+	expiresAt := time.Now().Add(s.twoFactorOpts.ChallengeTTL)
+	err = s.twoFactor.CreateChallenge(ctx, entity.TwoFactorChallenge{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return SignInResult{}, fmt.Errorf("store challenge: %w", err)
+	}
+
+	return SignInResult{ChallengeToken: token, ChallengeExpiresAt: expiresAt}, nil
+}
+
+// verifySecondFactor checks a second factor code of the user. Codes are limited like passwords, every try
+// is reserved against the login and the client IP, and wrong codes stay counted as failed sign-ins.
+// Once the code is accepted the caller settles the returned attempt
+func (s *UsersService) verifySecondFactor(ctx context.Context, op string, user entity.User, code string, client ClientInfo) (*auth.Attempt, error) {
+	attempt, retryAfter, err := s.limiter.Reserve(ctx, user.Login, client.IP)
+	if err != nil {
+		return nil, fmt.Errorf("count attempt: %w", err)
+	}
+	if retryAfter > 0 {
+		return nil, &entity.ThrottledError{RetryAfter: retryAfter}
+	}
+
+	if err := s.checkSecondFactor(ctx, user.ID, code); err != nil {
+		if errors.Is(err, entity.ErrInvalidTwoFactorCode) {
+			s.failSignIn(ctx, op, attempt, &user, client)
+		} else {
+			s.releaseAttempt(ctx, op, attempt)
+		}
+		return nil, err
+	}
+
+	return attempt, nil
+}
+
+// confirmSecondFactor checks a code of a signed-in user before two-factor settings are changed. Wrong codes
+// count as failed sign-ins of the login, so a stolen access token can't be used to guess codes and turn 2FA off
+func (s *UsersService) confirmSecondFactor(ctx context.Context, op string, userID int64, code string) error {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	attempt, err := s.verifySecondFactor(ctx, op, *user, code, ClientInfo{})
+	if err != nil {
+		return err
+	}
+
+	// a right code proves the factor but is no sign-in, the history of the login is kept
+	s.releaseAttempt(ctx, op, attempt)
+	return nil
+}
+
+// checkSecondFactor accepts a 6-digit code from the app, each at most once, or an unused recovery code
+func (s *UsersService) checkSecondFactor(ctx context.Context, userID int64, code string) error {
+	secret, err := s.twoFactor.GetTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !secret.Enabled() {
+		return entity.ErrTwoFactorNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(secret.Secret, code, time.Now(), totpSkew)
+		if !ok || step <= secret.LastStep {
+			return entity.ErrInvalidTwoFactorCode
+		}
+		return s.twoFactor.UseTOTPStep(ctx, userID, step)
+	}
+
+	return s.twoFactor.UseRecoveryCode(ctx, userID, auth.HashToken(normalizeRecoveryCode(code)))
+}
+
+// newRecoveryCodes generates recovery codes formatted as "xxxxx-xxxxx" along with hashes of their normalized form
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	codes = make([]string, recoveryCodeCount)
+	hashes = make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("generate recovery code: %w", err)
+		}
+		for j := range b {
+			// 256 is a multiple of the alphabet size, so every character is equally likely
+			b[j] = recoveryCodeAlphabet[int(b[j])%len(recoveryCodeAlphabet)]
+		}
+
+		codes[i] = string(b[:5]) + "-" + string(b[5:])
+		hashes[i] = auth.HashToken(string(b))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode drops separators and case, so codes can be typed as they are printed or not
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
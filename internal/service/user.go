@@ -5,145 +5,833 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/mail"
+	"net/url"
+	"strings"
 	"time"
 
 	"rest-api-marketplace/internal/entity"
 	"rest-api-marketplace/internal/repository"
 	"rest-api-marketplace/pkg/auth"
 	"rest-api-marketplace/pkg/hash"
+	"rest-api-marketplace/pkg/mailer"
 )
 
+// lockoutsLimit is how many latest lockouts users can review
+const lockoutsLimit = 50
+
 // UsersService provides operations for managing users
 type UsersService struct {
 	repo            repository.Users
+	sessions        repository.Sessions
+	verifications   repository.EmailVerifications
+	resets          repository.PasswordResets
+	twoFactor       repository.TwoFactor
+	identities      repository.Identities
+	oauthStates     repository.OAuthStates
+	lockouts        repository.Lockouts
+	audit           auditor
+	revocations     auth.RevocationStore
+	limiter         *auth.LoginLimiter
 	logger          *slog.Logger
 	hasher          hash.PasswordHasher
+	passwordPolicy  PasswordPolicy
 	tokenManager    auth.TokenManager
 	accessTokenTTL  time.Duration
 	refreshTokenTTL time.Duration
+	email           EmailOptions
+	twoFactorOpts   TwoFactorOptions
+	oauthOpts       OAuthOptions
 }
 
 // NewUsersService creates a new UsersService instance
-func NewUsersService(repo repository.Users, logger *slog.Logger, hasher hash.PasswordHasher, tokenManager auth.TokenManager, tokenTTL, refreshTokenTTL time.Duration) *UsersService {
+func NewUsersService(repo repository.Users, sessions repository.Sessions, verifications repository.EmailVerifications, resets repository.PasswordResets, twoFactor repository.TwoFactor, identities repository.Identities, oauthStates repository.OAuthStates, lockouts repository.Lockouts, auditLog repository.AuditLog, revocations auth.RevocationStore, limiter *auth.LoginLimiter, logger *slog.Logger, hasher hash.PasswordHasher, passwordPolicy PasswordPolicy, tokenManager auth.TokenManager, tokenTTL, refreshTokenTTL time.Duration, email EmailOptions, twoFactorOpts TwoFactorOptions, oauthOpts OAuthOptions) *UsersService {
 	return &UsersService{
 		repo:            repo,
+		sessions:        sessions,
+		verifications:   verifications,
+		resets:          resets,
+		twoFactor:       twoFactor,
+		identities:      identities,
+		oauthStates:     oauthStates,
+		lockouts:        lockouts,
+		audit:           auditor{repo: auditLog, logger: logger},
+		revocations:     revocations,
+		limiter:         limiter,
 		logger:          logger,
 		hasher:          hasher,
+		passwordPolicy:  passwordPolicy,
 		tokenManager:    tokenManager,
 		accessTokenTTL:  tokenTTL,
 		refreshTokenTTL: refreshTokenTTL,
+		email:           email,
+		twoFactorOpts:   twoFactorOpts,
+		oauthOpts:       oauthOpts,
 	}
 }
 
-// SignUp registers a new user
-func (s *UsersService) SignUp(ctx context.Context, input UserInput) (*entity.User, error) {
+// SignUp registers a new user and sends a verification link to their email. An email that already
+// belongs to a user gets a notice instead and no error is returned, so sign-up doesn't tell which
+// emails are registered, the same way ForgotPassword doesn't
+func (s *UsersService) SignUp(ctx context.Context, input UserInput) error {
 	const op = "service.UsersService.SignUp"
 
 	if len(input.Login) < 3 || len(input.Login) > 30 {
-		return nil, fmt.Errorf("%s: %w: login length", op, entity.ErrInvalidInput)
+		return fmt.Errorf("%s: %w: login length", op, entity.ErrInvalidInput)
+	}
+	email, err := normalizeEmail(input.Email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
-	if len(input.Password) < 6 {
-		return nil, fmt.Errorf("%s: %w: password too short", op, entity.ErrInvalidInput)
+	if err := s.checkPassword(ctx, op, input.Password, input.Login, email); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	hashedPass, err := s.hasher.Hash(input.Password)
 	if err != nil {
 		s.logger.Error("failed to hash password", slog.String("op", op), slog.String("error", err.Error()))
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	user := entity.User{
 		Login:        input.Login,
+		Email:        email,
 		PasswordHash: hashedPass,
 		CreatedAt:    time.Now(),
 	}
 
 	userID, err := s.repo.Create(ctx, user)
 	if err != nil {
-		if errors.Is(err, entity.ErrUserExists) {
-			return nil, fmt.Errorf("%s: %w", op, err)
+		switch {
+		case errors.Is(err, entity.ErrEmailTaken):
+			if err := s.sendSignUpNotice(ctx, email); err != nil {
+				s.logger.Error("failed to send sign-up notice", slog.String("op", op), slog.String("error", err.Error()))
+			}
+			return nil
+		case errors.Is(err, entity.ErrUserExists):
+			return fmt.Errorf("%s: %w", op, err)
+		default:
+			s.logger.Error("failed to create user", slog.String("op", op), slog.String("error", err.Error()))
+			return fmt.Errorf("%s: %w", op, err)
 		}
-		s.logger.Error("failed to create user", slog.String("op", op), slog.String("error", err.Error()))
-		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	createdUser, err := s.repo.GetByID(ctx, userID)
-	if err != nil {
-		s.logger.Error("failed to retrieve created user", slog.String("op", op), slog.String("error", err.Error()))
-		return nil, fmt.Errorf("%s: %w", op, err)
+	// the account is usable without a verified email, the user can ask for another link
+	if err := s.sendVerification(ctx, userID, email); err != nil {
+		s.logger.Error("failed to send verification email", slog.String("op", op), slog.String("error", err.Error()))
 	}
-	return createdUser, nil
+
+	return nil
 }
 
-// SignIn authenticates a user and returns JWT tokens
-func (s *UsersService) SignIn(ctx context.Context, input UserInput) (Tokens, error) {
+// SignIn authenticates a user and returns JWT tokens. Users with two-factor authentication
+// get a challenge instead, the tokens are issued by SignInTwoFactor.
+// Failed attempts slow down further ones for the login and the client IP and eventually lock them out
+func (s *UsersService) SignIn(ctx context.Context, input UserInput, client ClientInfo) (SignInResult, error) {
 	const op = "service.UsersService.SignIn"
 
+	// the attempt is counted before the slow password check, so parallel guesses can't outrun the limits
+	attempt, retryAfter, err := s.limiter.Reserve(ctx, input.Login, client.IP)
+	if err != nil {
+		s.logger.Error("failed to count sign-in attempt", slog.String("op", op), slog.String("error", err.Error()))
+		return SignInResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if retryAfter > 0 {
+		return SignInResult{}, fmt.Errorf("%s: %w", op, &entity.ThrottledError{RetryAfter: retryAfter})
+	}
+
 	user, err := s.repo.GetByLogin(ctx, input.Login)
 	if err != nil {
 		if errors.Is(err, entity.ErrUserNotFound) {
-			return Tokens{}, fmt.Errorf("%s: %w", op, entity.ErrInvalidCreds)
+			// unknown logins are counted the same way, so lockouts don't tell which logins exist
+			s.failSignIn(ctx, op, attempt, nil, client)
+			return SignInResult{}, fmt.Errorf("%s: %w", op, entity.ErrInvalidCreds)
 		}
+		s.releaseAttempt(ctx, op, attempt)
 		s.logger.Error("failed to get user by login", slog.String("op", op), slog.String("error", err.Error()))
-		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+		return SignInResult{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	if !s.hasher.Check(input.Password, user.PasswordHash) {
-		return Tokens{}, entity.ErrInvalidCreds
+		s.failSignIn(ctx, op, attempt, user, client)
+		return SignInResult{}, fmt.Errorf("%s: %w", op, entity.ErrInvalidCreds)
+	}
+
+	if s.hasher.NeedsRehash(user.PasswordHash) {
+		s.rehashPassword(ctx, op, user, input.Password)
+	}
+
+	res, err := s.finishSignIn(ctx, op, *user, client)
+	if err != nil {
+		s.releaseAttempt(ctx, op, attempt)
+		return SignInResult{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return s.createSession(ctx, user.ID)
+	// failures of the login are only forgotten once tokens are issued, a pending second factor keeps them
+	if res.ChallengeToken != "" {
+		err = s.limiter.Pass(ctx, attempt)
+	} else {
+		err = s.limiter.Succeed(ctx, attempt)
+	}
+	if err != nil {
+		s.logger.Error("failed to settle sign-in attempt", slog.String("op", op), slog.String("error", err.Error()))
+	}
+
+	return res, nil
 }
 
-// RefreshTokens generates new access and refresh tokens for a valid refresh token
-func (s *UsersService) RefreshTokens(ctx context.Context, refreshToken string) (Tokens, error) {
+// finishSignIn issues tokens to an authenticated user, or a two-factor challenge if the user enabled it
+func (s *UsersService) finishSignIn(ctx context.Context, op string, user entity.User, client ClientInfo) (SignInResult, error) {
+	enabled, err := s.twoFactorEnabled(ctx, user.ID)
+	if err != nil {
+		s.logger.Error("failed to get two-factor settings", slog.String("op", op), slog.String("error", err.Error()))
+		return SignInResult{}, err
+	}
+	if enabled {
+		res, err := s.createChallenge(ctx, user.ID)
+		if err != nil {
+			s.logger.Error("failed to create two-factor challenge", slog.String("op", op), slog.String("error", err.Error()))
+			return SignInResult{}, err
+		}
+		return res, nil
+	}
+
+	tokens, err := s.createSession(ctx, user, client)
+	if err != nil {
+		return SignInResult{}, err
+	}
+
+	return SignInResult{Tokens: tokens}, nil
+}
+
+// RefreshTokens rotates the refresh token of a session and issues a new token pair.
+// Presenting a token that was already rotated means it leaked, so the whole session is revoked
+func (s *UsersService) RefreshTokens(ctx context.Context, refreshToken string, client ClientInfo) (Tokens, error) {
 	const op = "service.UsersService.RefreshTokens"
 
 	if refreshToken == "" {
 		return Tokens{}, fmt.Errorf("%s: %w: empty refresh token", op, entity.ErrInvalidInput)
 	}
 
-	user, err := s.repo.GetByRefreshToken(ctx, refreshToken)
+	tokenHash := auth.HashRefreshToken(refreshToken)
+
+	token, err := s.sessions.GetRefreshToken(ctx, tokenHash)
 	if err != nil {
-		if errors.Is(err, entity.ErrUserNotFound) {
+		if errors.Is(err, entity.ErrSessionNotFound) {
 			return Tokens{}, fmt.Errorf("%s: %w", op, err)
 		}
-		s.logger.Error("failed to get user by refresh token", slog.String("op", op), slog.String("error", err.Error()))
+		s.logger.Error("failed to get refresh token", slog.String("op", op), slog.String("error", err.Error()))
 		return Tokens{}, fmt.Errorf("%s: %w", op, err)
 	}
-	return s.createSession(ctx, user.ID)
+
+	session, err := s.sessions.GetByID(ctx, token.SessionID)
+	if err != nil {
+		if errors.Is(err, entity.ErrSessionNotFound) {
+			return Tokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to get session", slog.String("op", op), slog.String("error", err.Error()))
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if token.RotatedAt != nil {
+		s.revokeReusedSession(ctx, op, session, client)
+		return Tokens{}, fmt.Errorf("%s: %w", op, entity.ErrRefreshTokenReused)
+	}
+
+	user, err := s.repo.GetByID(ctx, session.UserID)
+	if err != nil {
+		s.logger.Error("failed to get session user", slog.String("op", op), slog.String("error", err.Error()))
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := s.newTokens(*user, session.ID)
+	if err != nil {
+		s.logger.Error("failed to create tokens", slog.String("op", op), slog.String("error", err.Error()))
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	session.RefreshTokenHash = auth.HashRefreshToken(res.RefreshToken)
+	session.ExpiresAt = time.Now().Add(s.refreshTokenTTL)
+	session.UserAgent, session.IP = clientFields(client)
+
+	if err := s.sessions.Rotate(ctx, tokenHash, *session); err != nil {
+		switch {
+		case errors.Is(err, entity.ErrRefreshTokenReused):
+			s.revokeReusedSession(ctx, op, session, client)
+			return Tokens{}, fmt.Errorf("%s: %w", op, err)
+		case errors.Is(err, entity.ErrSessionNotFound):
+			return Tokens{}, fmt.Errorf("%s: %w", op, err)
+		default:
+			s.logger.Error("failed to rotate refresh token", slog.String("op", op), slog.String("error", err.Error()))
+			return Tokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return res, nil
 }
 
-// createSession generates JWT access and refresh tokens and stores the session
-func (s *UsersService) createSession(ctx context.Context, id int64) (Tokens, error) {
-	const op = "service.UsersService.createSession"
-	var (
-		res Tokens
-		err error
+// rehashPassword upgrades the stored hash of a verified password to the current algorithm and parameters.
+// Errors are only logged, the old hash keeps working
+func (s *UsersService) rehashPassword(ctx context.Context, op string, user *entity.User, password string) {
+	hashedPass, err := s.hasher.Hash(password)
+	if err != nil {
+		s.logger.Error("failed to rehash password", slog.String("op", op), slog.String("error", err.Error()))
+		return
+	}
+
+	if err := s.repo.ReplacePasswordHash(ctx, user.ID, user.PasswordHash, hashedPass); err != nil {
+		s.logger.Error("failed to update password hash", slog.String("op", op), slog.String("error", err.Error()))
+		return
+	}
+
+	user.PasswordHash = hashedPass
+}
+
+// failSignIn keeps a reserved sign-in attempt counted as failed and records a lockout of the account for its owner
+// to review. user is nil if the login is unknown. Errors are only logged, the client gets invalid credentials anyway
+func (s *UsersService) failSignIn(ctx context.Context, op string, attempt *auth.Attempt, user *entity.User, client ClientInfo) {
+	failure, err := s.limiter.Fail(ctx, attempt)
+	if err != nil {
+		s.logger.Error("failed to count sign-in attempt", slog.String("op", op), slog.String("error", err.Error()))
+		return
+	}
+
+	if failure.IPLocked {
+		s.logger.Warn("security: client locked out after failed sign-in attempts",
+			slog.String("op", op),
+			slog.String("event", "ip_lockout"),
+			slog.String("ip", client.IP),
+			slog.Time("locked_until", failure.LockedUntil),
+		)
+	}
+	if !failure.LoginLocked || user == nil {
+		return
+	}
+
+	s.logger.Warn("security: account locked out after failed sign-in attempts",
+		slog.String("op", op),
+		slog.String("event", "account_lockout"),
+		slog.Int64("user_id", user.ID),
+		slog.Int("failures", failure.LoginFailures),
+		slog.String("ip", client.IP),
+		slog.Time("locked_until", failure.LockedUntil),
 	)
 
-	res.AccessToken, err = s.tokenManager.NewJWTToken(id, s.accessTokenTTL)
+	_, ip := clientFields(client)
+	err = s.lockouts.Create(ctx, entity.Lockout{
+		UserID:      user.ID,
+		IP:          ip,
+		Failures:    failure.LoginFailures,
+		LockedUntil: failure.LockedUntil,
+	})
 	if err != nil {
-		s.logger.Error("failed to create access token", slog.String("op", op), slog.String("error", err.Error()))
-		return res, fmt.Errorf("%s: %w", op, err)
+		s.logger.Error("failed to record lockout", slog.String("op", op), slog.String("error", err.Error()))
+	}
+}
+
+// releaseAttempt takes back a reserved sign-in attempt that ended before the credentials were judged.
+// Errors are only logged, the attempt then stays counted as failed
+func (s *UsersService) releaseAttempt(ctx context.Context, op string, attempt *auth.Attempt) {
+	if err := s.limiter.Release(ctx, attempt); err != nil {
+		s.logger.Error("failed to release sign-in attempt", slog.String("op", op), slog.String("error", err.Error()))
 	}
+}
 
-	res.RefreshToken, err = s.tokenManager.NewRefreshToken()
+// GetLockouts returns the latest lockouts of the account after failed sign-in attempts
+func (s *UsersService) GetLockouts(ctx context.Context, userID int64) ([]entity.Lockout, error) {
+	const op = "service.UsersService.GetLockouts"
+
+	lockouts, err := s.lockouts.GetByUserID(ctx, userID, lockoutsLimit)
+	if err != nil {
+		s.logger.Error("failed to get lockouts", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return lockouts, nil
+}
+
+// revokeReusedSession revokes the refresh token family of a session after a rotated token was replayed
+func (s *UsersService) revokeReusedSession(ctx context.Context, op string, session *entity.Session, client ClientInfo) {
+	s.logger.Warn("security: refresh token reuse detected, session revoked",
+		slog.String("op", op),
+		slog.String("event", "refresh_token_reuse"),
+		slog.Int64("user_id", session.UserID),
+		slog.Int64("session_id", session.ID),
+		slog.String("ip", client.IP),
+		slog.String("user_agent", client.UserAgent),
+	)
+
+	if err := s.sessions.Delete(ctx, session.ID, session.UserID); err != nil && !errors.Is(err, entity.ErrSessionNotFound) {
+		s.logger.Error("failed to revoke session", slog.String("op", op), slog.String("error", err.Error()))
+	}
+}
+
+// GetSessions returns active sessions of the user
+func (s *UsersService) GetSessions(ctx context.Context, userID int64) ([]entity.Session, error) {
+	const op = "service.UsersService.GetSessions"
+
+	sessions, err := s.sessions.GetByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get sessions", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession signs the user out on one device, its refresh token stops working
+func (s *UsersService) RevokeSession(ctx context.Context, userID, sessionID int64) error {
+	const op = "service.UsersService.RevokeSession"
+
+	if err := s.sessions.Delete(ctx, sessionID, userID); err != nil {
+		if errors.Is(err, entity.ErrSessionNotFound) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to delete session", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SignOut ends the session the access token was issued for and revokes the token itself
+func (s *UsersService) SignOut(ctx context.Context, claims *auth.TokenClaims) error {
+	const op = "service.UsersService.SignOut"
+
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if err := s.revocations.Revoke(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+			s.logger.Error("failed to revoke access token", slog.String("op", op), slog.String("error", err.Error()))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if claims.SessionID == 0 {
+		return nil
+	}
+
+	if err := s.sessions.Delete(ctx, claims.SessionID, claims.UserID); err != nil && !errors.Is(err, entity.ErrSessionNotFound) {
+		s.logger.Error("failed to delete session", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SignOutAll ends all sessions of the user and revokes every access token issued so far
+func (s *UsersService) SignOutAll(ctx context.Context, userID int64) error {
+	const op = "service.UsersService.SignOutAll"
+
+	if err := s.revokeAllSessions(ctx, userID); err != nil {
+		s.logger.Error("failed to revoke sessions", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// revokeAllSessions revokes every access token issued to the user so far and deletes all their sessions
+func (s *UsersService) revokeAllSessions(ctx context.Context, userID int64) error {
+	if err := auth.RevokeAllTokens(ctx, s.revocations, userID, s.accessTokenTTL); err != nil {
+		return fmt.Errorf("revoke access tokens: %w", err)
+	}
+
+	if err := s.sessions.DeleteByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("delete sessions: %w", err)
+	}
+
+	return nil
+}
+
+// SetRole changes the role of another user, only admins can do it. Access tokens of the user are revoked,
+// so the new role takes effect on the next token refresh
+func (s *UsersService) SetRole(ctx context.Context, actor Actor, userID int64, role entity.Role) (*entity.User, error) {
+	const op = "service.UsersService.SetRole"
+
+	if actor.Role != entity.RoleAdmin || actor.UserID == userID {
+		return nil, fmt.Errorf("%s: %w", op, entity.ErrForbidden)
+	}
+	if !role.Valid() {
+		return nil, fmt.Errorf("%s: %w: unknown role %q", op, entity.ErrInvalidInput, role)
+	}
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, entity.ErrUserNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to get user", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if user.Role == role {
+		return user, nil
+	}
+
+	if err := s.repo.UpdateRole(ctx, userID, role); err != nil {
+		if errors.Is(err, entity.ErrUserNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to update user role", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.audit.record(ctx, actor, entity.AuditUserRoleChange, entity.AuditTargetUser, userID, map[string]string{
+		"login": user.Login,
+		"from":  string(user.Role),
+		"to":    string(role),
+	})
+
+	if err := auth.RevokeAllTokens(ctx, s.revocations, userID, s.accessTokenTTL); err != nil {
+		s.logger.Error("failed to revoke access tokens", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user.Role = role
+	return user, nil
+}
+
+// SetEmail changes the email of the user and sends a verification link to it.
+// Calling it with the current unverified email sends a new link
+func (s *UsersService) SetEmail(ctx context.Context, userID int64, email string) error {
+	const op = "service.UsersService.SetEmail"
+
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, entity.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to get user", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.Email == email && user.EmailVerified() {
+		return nil
+	}
+
+	if user.Email != email {
+		if err := s.repo.SetEmail(ctx, userID, email); err != nil {
+			if errors.Is(err, entity.ErrEmailTaken) || errors.Is(err, entity.ErrUserNotFound) {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			s.logger.Error("failed to set email", slog.String("op", op), slog.String("error", err.Error()))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := s.sendVerification(ctx, userID, email); err != nil {
+		s.logger.Error("failed to send verification email", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// VerifyEmail redeems a verification token and marks the email it was sent to as verified
+func (s *UsersService) VerifyEmail(ctx context.Context, token string) error {
+	const op = "service.UsersService.VerifyEmail"
+
+	if token == "" {
+		return fmt.Errorf("%s: %w", op, entity.ErrInvalidVerificationToken)
+	}
+
+	if _, err := s.verifications.Redeem(ctx, auth.HashToken(token)); err != nil {
+		if errors.Is(err, entity.ErrInvalidVerificationToken) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to redeem verification token", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ForgotPassword mails a password reset link to the verified email of the user.
+// Unknown and unverified emails are silently ignored, so the result doesn't reveal which emails are registered
+func (s *UsersService) ForgotPassword(ctx context.Context, email string) error {
+	const op = "service.UsersService.ForgotPassword"
+
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, entity.ErrUserNotFound) {
+			return nil
+		}
+		s.logger.Error("failed to get user by email", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// an unverified email may belong to someone else, it can't be trusted with the account
+	if !user.EmailVerified() {
+		return nil
+	}
+
+	if err := s.sendPasswordReset(ctx, user.ID, user.Email); err != nil {
+		s.logger.Error("failed to send password reset email", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ResetPassword redeems a password reset token, sets the new password and signs the user out everywhere
+func (s *UsersService) ResetPassword(ctx context.Context, token, password string) error {
+	const op = "service.UsersService.ResetPassword"
+
+	if token == "" {
+		return fmt.Errorf("%s: %w", op, entity.ErrInvalidResetToken)
+	}
+	tokenHash := auth.HashToken(token)
+
+	reset, err := s.resets.Get(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, entity.ErrInvalidResetToken) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to get password reset token", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.repo.GetByID(ctx, reset.UserID)
+	if err != nil {
+		s.logger.Error("failed to get user", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.checkPassword(ctx, op, password, user.Login, user.Email); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	hashedPass, err := s.hasher.Hash(password)
+	if err != nil {
+		s.logger.Error("failed to hash password", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID, err := s.resets.Redeem(ctx, tokenHash, hashedPass)
+	if err != nil {
+		if errors.Is(err, entity.ErrInvalidResetToken) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to redeem password reset token", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.revokeAllSessions(ctx, userID); err != nil {
+		s.logger.Error("failed to revoke sessions", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ChangePassword sets a new password after checking the current one and signs the user out everywhere,
+// including the session the change was made from
+func (s *UsersService) ChangePassword(ctx context.Context, userID int64, oldPassword, newPassword string) error {
+	const op = "service.UsersService.ChangePassword"
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, entity.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to get user", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !s.hasher.Check(oldPassword, user.PasswordHash) {
+		return fmt.Errorf("%s: %w", op, entity.ErrInvalidCreds)
+	}
+
+	if err := s.checkPassword(ctx, op, newPassword, user.Login, user.Email); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	hashedPass, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		s.logger.Error("failed to hash password", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.repo.UpdatePassword(ctx, userID, hashedPass); err != nil {
+		if errors.Is(err, entity.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		s.logger.Error("failed to update password", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.revokeAllSessions(ctx, userID); err != nil {
+		s.logger.Error("failed to revoke sessions", slog.String("op", op), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// sendPasswordReset stores a new password reset token and mails it to the user
+func (s *UsersService) sendPasswordReset(ctx context.Context, userID int64, email string) error {
+	token, err := auth.NewRandomToken()
+	if err != nil {
+		return fmt.Errorf("password reset token: %w", err)
+	}
+
+	err = s.resets.Create(ctx, entity.PasswordReset{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(s.email.PasswordResetTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("store password reset token: %w", err)
+	}
+
+	body := "Reset your password by sending this code with a new password to POST /api/v1/users/password/reset:\n\n" + token + "\n"
+	if s.email.PasswordResetURL != "" {
+		body = "Reset your password by opening this link:\n\n" + s.email.PasswordResetURL + url.QueryEscape(token) + "\n"
+	}
+	body += "\nThe code expires in " + s.email.PasswordResetTTL.String() + " and works once. If you didn't ask for it, ignore this email.\n"
+
+	return s.email.Mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body:    body,
+	})
+}
+
+// sendSignUpNotice tells the owner of an email that someone tried to sign up with it
+func (s *UsersService) sendSignUpNotice(ctx context.Context, email string) error {
+	body := "Someone tried to sign up with this email, but it already belongs to an account.\n\n" +
+		"If it was you, sign in with your login instead, or reset your password with POST /api/v1/users/password/forgot. " +
+		"If it wasn't you, ignore this email, your account was not changed.\n"
+
+	return s.email.Mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Sign-up attempt with your email",
+		Body:    body,
+	})
+}
+
+// sendVerification stores a new verification token for the email and mails it to the user
+func (s *UsersService) sendVerification(ctx context.Context, userID int64, email string) error {
+	token, err := auth.NewRandomToken()
+	if err != nil {
+		return fmt.Errorf("verification token: %w", err)
+	}
+
+	err = s.verifications.Create(ctx, entity.EmailVerification{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Now().Add(s.email.VerificationTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("store verification token: %w", err)
+	}
+
+	body := "Confirm your email by sending this code to POST /api/v1/users/verify-email:\n\n" + token + "\n"
+	if s.email.VerificationURL != "" {
+		body = "Confirm your email by opening this link:\n\n" + s.email.VerificationURL + url.QueryEscape(token) + "\n"
+	}
+	body += "\nThe code expires in " + s.email.VerificationTTL.String() + ". If you didn't sign up, ignore this email.\n"
+
+	return s.email.Mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Confirm your email",
+		Body:    body,
+	})
+}
+
+// createSession stores a new session for the device and issues JWT access and refresh tokens for it
+func (s *UsersService) createSession(ctx context.Context, user entity.User, client ClientInfo) (Tokens, error) {
+	const op = "service.UsersService.createSession"
+
+	refreshToken, err := s.tokenManager.NewRefreshToken()
 	if err != nil {
 		s.logger.Error("failed to create refresh token", slog.String("op", op), slog.String("error", err.Error()))
-		return res, fmt.Errorf("%s: %w", op, err)
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	session := entity.Session{
-		RefreshToken: res.RefreshToken,
-		ExpiresAt:    time.Now().Add(s.refreshTokenTTL),
+		UserID:           user.ID,
+		RefreshTokenHash: auth.HashRefreshToken(refreshToken),
+		ExpiresAt:        time.Now().Add(s.refreshTokenTTL),
 	}
+	session.UserAgent, session.IP = clientFields(client)
 
-	err = s.repo.SetSession(ctx, id, session)
+	sessionID, err := s.sessions.Create(ctx, session)
 	if err != nil {
-		s.logger.Error("failed to set session", slog.String("op", op), slog.String("error", err.Error()))
+		s.logger.Error("failed to create session", slog.String("op", op), slog.String("error", err.Error()))
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	accessToken, err := s.tokenManager.NewJWTToken(user.ID, sessionID, string(user.Role), s.accessTokenTTL)
+	if err != nil {
+		s.logger.Error("failed to create access token", slog.String("op", op), slog.String("error", err.Error()))
 		return Tokens{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	return Tokens{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// newTokens generates an access token for the user session and a new refresh token
+func (s *UsersService) newTokens(user entity.User, sessionID int64) (Tokens, error) {
+	var (
+		res Tokens
+		err error
+	)
+
+	res.AccessToken, err = s.tokenManager.NewJWTToken(user.ID, sessionID, string(user.Role), s.accessTokenTTL)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("access token: %w", err)
+	}
+
+	res.RefreshToken, err = s.tokenManager.NewRefreshToken()
+	if err != nil {
+		return Tokens{}, fmt.Errorf("refresh token: %w", err)
+	}
+
 	return res, nil
 }
+
+// clientFields trims client info to the sizes of the sessions table columns
+func clientFields(client ClientInfo) (userAgent, ip string) {
+	userAgent, ip = client.UserAgent, client.IP
+	if len(userAgent) > 512 {
+		userAgent = strings.ToValidUTF8(userAgent[:512], "")
+	}
+	if len(ip) > 45 {
+		ip = ip[:45]
+	}
+	return userAgent, ip
+}
+
+// checkPassword validates a new password against the password policy, logging failures other than a weak password
+func (s *UsersService) checkPassword(ctx context.Context, op, password string, identifiers ...string) error {
+	err := s.passwordPolicy.Validate(ctx, password, identifiers...)
+	if err != nil && !errors.Is(err, entity.ErrWeakPassword) {
+		s.logger.Error("failed to check password policy", slog.String("op", op), slog.String("error", err.Error()))
+	}
+	return err
+}
+
+// normalizeEmail lowercases the email and checks that it is a bare address
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" || len(email) > 254 {
+		return "", fmt.Errorf("%w: email length", entity.ErrInvalidInput)
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", fmt.Errorf("%w: invalid email", entity.ErrInvalidInput)
+	}
+
+	return email, nil
+}
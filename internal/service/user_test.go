@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/internal/repository"
+	"rest-api-marketplace/pkg/auth"
+)
+
+// memorySessions is an in-memory repository.Sessions keeping every refresh token of a session family
+type memorySessions struct {
+	mu       sync.Mutex
+	nextID   int64
+	sessions map[int64]entity.Session
+	tokens   map[string]entity.RefreshToken
+}
+
+func newMemorySessions() *memorySessions {
+	return &memorySessions{sessions: make(map[int64]entity.Session), tokens: make(map[string]entity.RefreshToken)}
+}
+
+func (m *memorySessions) Create(_ context.Context, session entity.Session) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	session.ID = m.nextID
+	m.sessions[session.ID] = session
+	m.tokens[session.RefreshTokenHash] = entity.RefreshToken{SessionID: session.ID, TokenHash: session.RefreshTokenHash}
+	return session.ID, nil
+}
+
+func (m *memorySessions) GetByID(_ context.Context, id int64) (*entity.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, entity.ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func (m *memorySessions) GetRefreshToken(_ context.Context, tokenHash string) (*entity.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[tokenHash]
+	if !ok {
+		return nil, entity.ErrSessionNotFound
+	}
+	return &token, nil
+}
+
+func (m *memorySessions) Rotate(_ context.Context, oldTokenHash string, session entity.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.tokens[oldTokenHash]
+	if !ok || old.SessionID != session.ID || old.RotatedAt != nil {
+		return entity.ErrRefreshTokenReused
+	}
+	if _, ok := m.sessions[session.ID]; !ok {
+		return entity.ErrSessionNotFound
+	}
+
+	now := time.Now()
+	old.RotatedAt = &now
+	m.tokens[oldTokenHash] = old
+	m.tokens[session.RefreshTokenHash] = entity.RefreshToken{SessionID: session.ID, TokenHash: session.RefreshTokenHash}
+	m.sessions[session.ID] = session
+	return nil
+}
+
+func (m *memorySessions) GetByUserID(_ context.Context, userID int64) ([]entity.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sessions []entity.Session
+	for _, session := range m.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+// Delete removes the session with its whole refresh token family, like the cascade in the database
+func (m *memorySessions) Delete(_ context.Context, id, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok || session.UserID != userID {
+		return entity.ErrSessionNotFound
+	}
+	delete(m.sessions, id)
+	for hash, token := range m.tokens {
+		if token.SessionID == id {
+			delete(m.tokens, hash)
+		}
+	}
+	return nil
+}
+
+func (m *memorySessions) DeleteByUserID(ctx context.Context, userID int64) error {
+	sessions, _ := m.GetByUserID(ctx, userID)
+	for _, session := range sessions {
+		if err := m.Delete(ctx, session.ID, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// staticUsers is a repository.Users knowing a fixed set of users, only GetByID is implemented
+type staticUsers struct {
+	repository.Users
+	users map[int64]entity.User
+}
+
+func (s staticUsers) GetByID(_ context.Context, id int64) (*entity.User, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return nil, entity.ErrUserNotFound
+	}
+	return &user, nil
+}
+
+// newSessionsTestService returns a service with in-memory sessions and a signed in user
+func newSessionsTestService(t *testing.T) (*UsersService, *memorySessions, Tokens) {
+	t.Helper()
+
+	tokenManager, err := auth.NewManager("test-secret", nil, auth.ClaimsOptions{Issuer: "test", Audience: []string{"test"}})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	sessions := newMemorySessions()
+	s := &UsersService{
+		repo:            staticUsers{users: map[int64]entity.User{1: {ID: 1, Login: "john", Role: entity.RoleUser}}},
+		sessions:        sessions,
+		logger:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+		tokenManager:    tokenManager,
+		accessTokenTTL:  time.Hour,
+		refreshTokenTTL: 24 * time.Hour,
+	}
+
+	tokens, err := s.createSession(context.Background(), entity.User{ID: 1, Login: "john", Role: entity.RoleUser}, ClientInfo{})
+	if err != nil {
+		t.Fatalf("createSession() error = %v", err)
+	}
+	return s, sessions, tokens
+}
+
+func TestRefreshTokensRotation(t *testing.T) {
+	ctx := context.Background()
+	s, sessions, first := newSessionsTestService(t)
+
+	second, err := s.RefreshTokens(ctx, first.RefreshToken, ClientInfo{IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("RefreshTokens() error = %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == "" {
+		t.Fatalf("RefreshTokens() = %+v, want a new token pair", second)
+	}
+
+	claims, err := s.tokenManager.ParseJWTToken(second.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseJWTToken() error = %v", err)
+	}
+	if claims.UserID != 1 || claims.SessionID != 1 {
+		t.Errorf("access token claims = user %d, session %d, want user 1, session 1", claims.UserID, claims.SessionID)
+	}
+
+	session, err := sessions.GetByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("session is gone after rotation: %v", err)
+	}
+	if session.RefreshTokenHash != auth.HashRefreshToken(second.RefreshToken) || session.IP != "192.0.2.1" {
+		t.Errorf("session after rotation = %+v", session)
+	}
+
+	// the next token of the family keeps rotating
+	if _, err := s.RefreshTokens(ctx, second.RefreshToken, ClientInfo{}); err != nil {
+		t.Errorf("RefreshTokens() with the rotated token error = %v", err)
+	}
+}
+
+func TestRefreshTokensReuseRevokesSession(t *testing.T) {
+	ctx := context.Background()
+	s, sessions, first := newSessionsTestService(t)
+
+	second, err := s.RefreshTokens(ctx, first.RefreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatalf("RefreshTokens() error = %v", err)
+	}
+
+	// an attacker replays the token the client has already exchanged
+	if _, err := s.RefreshTokens(ctx, first.RefreshToken, ClientInfo{}); !errors.Is(err, entity.ErrRefreshTokenReused) {
+		t.Fatalf("RefreshTokens() with a rotated token error = %v, want %v", err, entity.ErrRefreshTokenReused)
+	}
+	if _, err := sessions.GetByID(ctx, 1); !errors.Is(err, entity.ErrSessionNotFound) {
+		t.Errorf("session survived the reuse, GetByID() error = %v", err)
+	}
+
+	// the latest token of the family is revoked with the session
+	if _, err := s.RefreshTokens(ctx, second.RefreshToken, ClientInfo{}); !errors.Is(err, entity.ErrSessionNotFound) {
+		t.Errorf("RefreshTokens() with the latest token error = %v, want %v", err, entity.ErrSessionNotFound)
+	}
+}
+
+func TestRefreshTokensConcurrentUse(t *testing.T) {
+	ctx := context.Background()
+	s, sessions, first := newSessionsTestService(t)
+
+	const clients = 10
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.RefreshTokens(ctx, first.RefreshToken, ClientInfo{}); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Errorf("%d of %d concurrent refreshes with one token succeeded, want 1", succeeded, clients)
+	}
+	if _, err := sessions.GetByID(ctx, 1); !errors.Is(err, entity.ErrSessionNotFound) {
+		t.Errorf("session survived concurrent reuse, GetByID() error = %v", err)
+	}
+}
+
+func TestRefreshTokensInvalid(t *testing.T) {
+	s, _, _ := newSessionsTestService(t)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "empty", token: "", wantErr: entity.ErrInvalidInput},
+		{name: "unknown", token: "not-a-token", wantErr: entity.ErrSessionNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.RefreshTokens(context.Background(), tt.token, ClientInfo{}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("RefreshTokens() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
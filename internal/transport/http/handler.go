@@ -24,17 +24,21 @@ import (
 // @in header
 // @name Authorization
 
-// Handler holds service dependencies and token manager for HTTP routes
+// Handler holds service dependencies, token manager and revocation store for HTTP routes
 type Handler struct {
 	services     *service.Services
 	tokenManager auth.TokenManager
+	revocations  auth.RevocationStore
+	maxImageSize int64
 }
 
-// NewHandler creates a new Handler with given services and token manager
-func NewHandler(services *service.Services, tokenManager auth.TokenManager) *Handler {
+// NewHandler creates a new Handler with given services, token manager, revocation store and the largest accepted image upload
+func NewHandler(services *service.Services, tokenManager auth.TokenManager, revocations auth.RevocationStore, maxImageSize int64) *Handler {
 	return &Handler{
 		services:     services,
 		tokenManager: tokenManager,
+		revocations:  revocations,
+		maxImageSize: maxImageSize,
 	}
 }
 
@@ -58,7 +62,9 @@ func (h *Handler) Init() *echo.Echo {
 
 // initAPI initializes API versioned routes
 func (h *Handler) initAPI(e *echo.Echo) {
-	handlerV1 := v1.NewHandler(h.services, h.tokenManager)
+	handlerV1 := v1.NewHandler(h.services, h.tokenManager, h.revocations, h.maxImageSize)
+
+	handlerV1.InitWellKnown(e.Group("/.well-known"))
 
 	api := e.Group("/api")
 	handlerV1.Init(api)
@@ -4,54 +4,81 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
+	echomw "github.com/labstack/echo/v4/middleware"
 
 	"rest-api-marketplace/internal/entity"
 	"rest-api-marketplace/internal/middleware"
 	"rest-api-marketplace/internal/service"
+	"rest-api-marketplace/pkg/money"
 )
 
+// multipartOverhead is the room left in upload bodies for multipart boundaries and part headers
+const multipartOverhead = 64 << 10
+
 // initAdsRoutes registers all /ads endpoints with proper middlewares
 func (h *Handler) initAdsRoutes(api *echo.Group) {
 	ads := api.Group("/ads")
 	{
-		authMiddleware := middleware.JWTAuth(h.tokenManager)
-		optionalAuthMiddleware := middleware.JWTOptionalAuth(h.tokenManager)
+		authMiddleware := middleware.JWTAuth(h.tokenManager, h.revocations)
+		optionalAuthMiddleware := middleware.JWTOptionalAuth(h.tokenManager, h.revocations)
 		ads.POST("", h.createAd, authMiddleware)
 		ads.PUT("/:id", h.updateAd, authMiddleware)
 		ads.GET("", h.listAds, optionalAuthMiddleware)
 		ads.GET("/:id", h.getAdByID, optionalAuthMiddleware)
 		ads.DELETE("/:id", h.deleteAd, authMiddleware)
+		ads.POST("/:id/transition", h.transitionAd, authMiddleware)
+		ads.POST("/:id/restore", h.restoreAd, authMiddleware)
+		uploadLimit := echomw.BodyLimit(strconv.FormatInt(h.maxImageSize+multipartOverhead, 10))
+		ads.POST("/:id/images", h.uploadAdImage, uploadLimit, authMiddleware)
+		ads.PUT("/:id/images/order", h.reorderAdImages, authMiddleware)
+		ads.POST("/:id/images/:image_id/cover", h.setAdCoverImage, authMiddleware)
+		ads.DELETE("/:id/images/:image_id", h.deleteAdImage, authMiddleware)
 	}
 }
 
 // createAdInput defines input structure for creating a new ad
 type createAdInput struct {
-	Title       string  `json:"title" validate:"required,min=1,max=100"`
-	Description string  `json:"description" validate:"required,max=1000"`
-	ImageURL    string  `json:"image_url" validate:"url"`
-	Price       float64 `json:"price" validate:"gte=0"`
+	CategoryID  *int64       `json:"category_id" validate:"omitempty,gt=0"`
+	Title       string       `json:"title" validate:"required,min=1,max=100"`
+	Description string       `json:"description" validate:"required,max=1000"`
+	ImageURL    string       `json:"image_url" validate:"url"`
+	Price       *money.Money `json:"price" validate:"required"`
+	Status      string       `json:"status" validate:"omitempty,oneof=draft published"`
 }
 
 // updateAdInput defines input structure for updating an ad
 type updateAdInput struct {
-	Title       *string  `json:"title,omitempty" validate:"required,min=1,max=100"`
-	Description *string  `json:"description,omitempty" validate:"required,max=1000"`
-	ImageURL    *string  `json:"image_url,omitempty" validate:"url"`
-	Price       *float64 `json:"price,omitempty" validate:"gte=0"`
+	CategoryID  *int64       `json:"category_id,omitempty" validate:"omitempty,gt=0"`
+	Title       *string      `json:"title,omitempty" validate:"required,min=1,max=100"`
+	Description *string      `json:"description,omitempty" validate:"required,max=1000"`
+	ImageURL    *string      `json:"image_url,omitempty" validate:"url"`
+	Price       *money.Money `json:"price,omitempty"`
+}
+
+// transitionAdInput defines input structure for changing an ad status
+type transitionAdInput struct {
+	Status string `json:"status" validate:"required,oneof=draft published reserved sold archived hidden"`
+}
+
+// reorderImagesInput defines input structure for reordering an ad gallery
+type reorderImagesInput struct {
+	ImageIDs []int64 `json:"image_ids" validate:"required,min=1,dive,gt=0"`
 }
 
 // @Summary Create Ad
-// @Description Create a new advertisement
+// @Description Create a new advertisement, published unless status is "draft"
 // @Tags ads
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer <token>"
 // @Param ad body createAdInput true "Ad creation details"
-// @Success 201 {object} entity.Ad
+// @Success 201 {object} adResponse
 // @Failure 400 {object} error "Invalid request body or input"
-// @Failure 409 {object} error "Unauthorized"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 403 {object} error "Email is not verified"
 // @Failure 500 {object} error "Failed to create ad"
 // @Router /api/v1/ads [post]
 // createAd handles POST /ads to create a new advertisement
@@ -71,31 +98,39 @@ func (h *Handler) createAd(c echo.Context) error {
 	}
 
 	ad, err := h.services.Ads.Create(c.Request().Context(), service.CreateAdInput{
+		CategoryID:  input.CategoryID,
 		Title:       input.Title,
 		Description: input.Description,
 		ImageURL:    input.ImageURL,
-		Price:       input.Price,
+		Price:       *input.Price,
+		Status:      entity.AdStatus(input.Status),
 	}, userID)
 
 	if err != nil {
-		if errors.Is(err, entity.ErrInvalidInput) {
+		switch {
+		case errors.Is(err, entity.ErrInvalidInput):
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, entity.ErrCategoryNotFound):
+			return echo.NewHTTPError(http.StatusBadRequest, "category not found")
+		case errors.Is(err, entity.ErrEmailNotVerified):
+			return echo.NewHTTPError(http.StatusForbidden, "verify your email before posting ads")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create ad")
 		}
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create ad")
 	}
 
-	return c.JSON(http.StatusCreated, ad)
+	return c.JSON(http.StatusCreated, newAdResponse(*ad))
 }
 
 // @Summary Update Ad
-// @Description Update an existing advertisement
+// @Description Update an existing advertisement, moderators can update any ad
 // @Tags ads
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer <token>"
 // @Param id path int64 true "Ad ID"
 // @Param ad body updateAdInput true "Ad update details"
-// @Success 200 {object} entity.Ad
+// @Success 200 {object} adResponse
 // @Failure 400 {object} error "Invalid request body or input"
 // @Failure 401 {object} error "Unauthorized"
 // @Failure 403 {object} error "Forbidden"
@@ -104,7 +139,7 @@ func (h *Handler) createAd(c echo.Context) error {
 // @Router /api/v1/ads/{id} [put]
 // updateAd handles PUT /ads/:id to update an existing advertisement
 func (h *Handler) updateAd(c echo.Context) error {
-	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	actor, ok := h.actor(c)
 	if !ok {
 		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
 	}
@@ -119,7 +154,8 @@ func (h *Handler) updateAd(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
 	}
 
-	updatedAd, err := h.services.Ads.Update(c.Request().Context(), adID, userID, service.UpdateAdInput{
+	updatedAd, err := h.services.Ads.Update(c.Request().Context(), adID, actor, service.UpdateAdInput{
+		CategoryID:  input.CategoryID,
 		Title:       input.Title,
 		Description: input.Description,
 		ImageURL:    input.ImageURL,
@@ -133,25 +169,36 @@ func (h *Handler) updateAd(c echo.Context) error {
 			return echo.NewHTTPError(http.StatusForbidden, "you don't have permission to update this ad")
 		case errors.Is(err, entity.ErrInvalidInput):
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, entity.ErrCategoryNotFound):
+			return echo.NewHTTPError(http.StatusBadRequest, "category not found")
 		default:
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update the ad")
 		}
 	}
-	return c.JSON(http.StatusOK, updatedAd)
+	return c.JSON(http.StatusOK, newAdResponse(*updatedAd))
 }
 
 // @Summary List Ads
 // @Description Retrieve a paginated list of advertisements
 // @Tags ads
 // @Produce json
-// @Param page query int false "Page number" default(1)
+// @Param page query int false "Page number, ignored when cursor is set" default(1)
+// @Param cursor query string false "Opaque cursor from next_cursor of the previous page, sorting must stay the same"
 // @Param limit query int false "Number of items per page" default(10)
-// @Param min_price query float64 false "Minimum price"
-// @Param max_price query float64 false "Maximum price"
-// @Param sort_by query string false "Sort by field (e.g., price, created_at)"
+// @Param currency query string false "Currency to convert prices to for filtering, sorting and converted_price" default(USD)
+// @Param min_price query string false "Minimum price in the requested currency as a decimal, e.g. 10.50"
+// @Param max_price query string false "Maximum price in the requested currency as a decimal, e.g. 99.99"
+// @Param sort_by query string false "Sort by field (price, date or relevance, relevance requires q)"
 // @Param sort_dir query string false "Sort direction (asc or desc)"
-// @Success 200 {array} entity.Ad
-// @Failure 400 {object} error "Incorrect price parameter"
+// @Param category query int64 false "Category ID, ads from its subcategories are included"
+// @Param q query string false "Full-text search over title and description"
+// @Param status query string false "Ad status, anything but published requires mine=true" default(published)
+// @Param mine query bool false "List only ads of the current user in any status"
+// @Param count query bool false "Count all matching ads, pass false to skip the total for faster responses" default(true)
+// @Success 200 {object} adsPageResponse
+// @Failure 400 {object} error "Incorrect cursor, currency, price, category, search, sorting or status parameter"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 403 {object} error "Forbidden"
 // @Failure 500 {object} error "Failed to get ads"
 // @Router /api/v1/ads [get]
 // listAds handles GET /ads to retrieve a paginated list of advertisements
@@ -166,29 +213,79 @@ func (h *Handler) listAds(c echo.Context) error {
 		limit = 10
 	}
 
-	var minPrice, maxPrice float64
+	currency := money.DefaultCurrency
+	if cur := c.QueryParam("currency"); cur != "" {
+		val, err := money.ParseCurrency(cur)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "incorrect currency")
+		}
+		currency = val
+	}
+
+	var minPrice, maxPrice *money.Money
 	if mp := c.QueryParam("min_price"); mp != "" {
-		val, err := strconv.ParseFloat(mp, 64)
+		val, err := money.Parse(mp, currency)
 		if err != nil {
 			return echo.NewHTTPError(http.StatusBadRequest, "incorrect min price")
 		}
-		minPrice = val
+		minPrice = &val
 	}
 	if mp := c.QueryParam("max_price"); mp != "" {
-		val, err := strconv.ParseFloat(mp, 64)
+		val, err := money.Parse(mp, currency)
 		if err != nil {
 			return echo.NewHTTPError(http.StatusBadRequest, "incorrect max price")
 		}
-		maxPrice = val
+		maxPrice = &val
+	}
+
+	var categoryID int64
+	if cat := c.QueryParam("category"); cat != "" {
+		val, err := strconv.ParseInt(cat, 10, 64)
+		if err != nil || val <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "incorrect category")
+		}
+		categoryID = val
+	}
+
+	search := strings.TrimSpace(c.QueryParam("q"))
+	if len(search) > 200 {
+		return echo.NewHTTPError(http.StatusBadRequest, "search query is too long")
+	}
+
+	status := entity.AdStatus(c.QueryParam("status"))
+	switch status {
+	case "", entity.AdStatusDraft, entity.AdStatusPublished, entity.AdStatusReserved, entity.AdStatusSold, entity.AdStatusArchived, entity.AdStatusHidden:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "incorrect status")
+	}
+
+	sortBy, sortDir := c.QueryParam("sort_by"), c.QueryParam("sort_dir")
+
+	var after *entity.AdsCursor
+	if raw := c.QueryParam("cursor"); raw != "" {
+		cursor, err := decodeCursor(raw, sortBy, sortDir)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		after = cursor
 	}
 
 	params := entity.GetAdsQuery{
-		Page:     page,
-		Limit:    limit,
-		SortBy:   c.QueryParam("sort_by"),
-		SortDir:  c.QueryParam("sort_dir"),
-		MinPrice: minPrice,
-		MaxPrice: maxPrice,
+		Page:       page,
+		Limit:      limit,
+		SortBy:     sortBy,
+		SortDir:    sortDir,
+		MinPrice:   minPrice,
+		MaxPrice:   maxPrice,
+		Currency:   currency,
+		CategoryID: categoryID,
+		Search:     search,
+		Status:     status,
+		After:      after,
+	}
+
+	if count, err := strconv.ParseBool(c.QueryParam("count")); err == nil && !count {
+		params.SkipCount = true
 	}
 
 	var currentUserID *int64
@@ -202,12 +299,28 @@ func (h *Handler) listAds(c echo.Context) error {
 		}
 	}
 
-	ads, err := h.services.Ads.GetAll(c.Request().Context(), params, currentUserID)
+	if mine, _ := strconv.ParseBool(c.QueryParam("mine")); mine {
+		if currentUserID == nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, "authorization is required to list own ads")
+		}
+		params.UserID = *currentUserID
+	}
+
+	adsPage, err := h.services.Ads.GetAll(c.Request().Context(), params, currentUserID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get ads")
+		switch {
+		case errors.Is(err, entity.ErrForbidden):
+			return echo.NewHTTPError(http.StatusForbidden, "only own ads can be listed with this status")
+		case errors.Is(err, entity.ErrInvalidCursor):
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid cursor")
+		case errors.Is(err, entity.ErrInvalidInput):
+			return echo.NewHTTPError(http.StatusBadRequest, "sorting by relevance requires q")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get ads")
+		}
 	}
 
-	return c.JSON(http.StatusOK, ads)
+	return c.JSON(http.StatusOK, newAdsPageResponse(c.Request().URL, adsPage, page, limit, after != nil))
 }
 
 // @Summary Get Ad by ID
@@ -215,8 +328,9 @@ func (h *Handler) listAds(c echo.Context) error {
 // @Tags ads
 // @Produce json
 // @Param id path int64 true "Ad ID"
-// @Success 200 {object} entity.Ad
-// @Failure 400 {object} error "Invalid ad ID"
+// @Param currency query string false "Currency to convert the price to for converted_price" default(USD)
+// @Success 200 {object} adResponse
+// @Failure 400 {object} error "Invalid ad ID or currency"
 // @Failure 404 {object} error "Ad not found"
 // @Failure 500 {object} error "Failed to get ad"
 // @Router /api/v1/ads/{id} [get]
@@ -228,12 +342,21 @@ func (h *Handler) getAdByID(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid ad id")
 	}
 
+	currency := money.DefaultCurrency
+	if cur := c.QueryParam("currency"); cur != "" {
+		val, err := money.ParseCurrency(cur)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "incorrect currency")
+		}
+		currency = val
+	}
+
 	var currentUserID *int64
 	if userID, ok := c.Get(middleware.CtxUserID).(int64); ok {
 		currentUserID = &userID
 	}
 
-	ad, err := h.services.Ads.GetByIDWithAuthor(c.Request().Context(), id, currentUserID)
+	ad, err := h.services.Ads.GetByIDWithAuthor(c.Request().Context(), id, currency, currentUserID)
 	if err != nil {
 		if errors.Is(err, entity.ErrAdNotFound) {
 			return echo.NewHTTPError(http.StatusNotFound, "ad not found")
@@ -241,11 +364,11 @@ func (h *Handler) getAdByID(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get ad")
 	}
 
-	return c.JSON(http.StatusOK, ad)
+	return c.JSON(http.StatusOK, newAdWithAuthorResponse(*ad))
 }
 
 // @Summary Delete Ad
-// @Description Delete an advertisement by its ID
+// @Description Delete an advertisement by its ID, moderators can delete any ad. It can be restored for a limited time
 // @Tags ads
 // @Produce json
 // @Param Authorization header string true "Bearer <token>"
@@ -259,7 +382,7 @@ func (h *Handler) getAdByID(c echo.Context) error {
 // @Router /api/v1/ads/{id} [delete]
 // deleteAd handles DELETE /ads/:id to remove an advertisement by ID
 func (h *Handler) deleteAd(c echo.Context) error {
-	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	actor, ok := h.actor(c)
 	if !ok {
 		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
 	}
@@ -269,7 +392,7 @@ func (h *Handler) deleteAd(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	err = h.services.Ads.Delete(c.Request().Context(), adID, userID)
+	err = h.services.Ads.Delete(c.Request().Context(), adID, actor)
 	if err != nil {
 		switch {
 		case errors.Is(err, entity.ErrAdNotFound):
@@ -282,3 +405,307 @@ func (h *Handler) deleteAd(c echo.Context) error {
 	}
 	return c.NoContent(http.StatusNoContent)
 }
+
+// @Summary Transition Ad
+// @Description Move an advertisement to another lifecycle status. Moderators can hide any ad and move a hidden ad back to draft
+// @Tags ads
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param id path int64 true "Ad ID"
+// @Param transition body transitionAdInput true "Target status"
+// @Success 200 {object} adResponse
+// @Failure 400 {object} error "Invalid request body or input"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 403 {object} error "Forbidden"
+// @Failure 404 {object} error "Ad not found"
+// @Failure 409 {object} error "Transition is not allowed"
+// @Failure 500 {object} error "Failed to change ad status"
+// @Router /api/v1/ads/{id}/transition [post]
+// transitionAd handles POST /ads/:id/transition to change an advertisement status
+func (h *Handler) transitionAd(c echo.Context) error {
+	actor, ok := h.actor(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	adID, err := h.parseIDFromPath(c, "id")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var input transitionAdInput
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return err
+	}
+
+	ad, err := h.services.Ads.Transition(c.Request().Context(), adID, actor, entity.AdStatus(input.Status))
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrAdNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "ad not found")
+		case errors.Is(err, entity.ErrForbidden):
+			return echo.NewHTTPError(http.StatusForbidden, "you don't have permission to change this ad")
+		case errors.Is(err, entity.ErrInvalidAdTransition):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		case errors.Is(err, entity.ErrInvalidInput):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to change ad status")
+		}
+	}
+
+	return c.JSON(http.StatusOK, newAdResponse(*ad))
+}
+
+// @Summary Restore Ad
+// @Description Restore a deleted advertisement during the restore window, moderators can restore any ad
+// @Tags ads
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param id path int64 true "Ad ID"
+// @Success 200 {object} adResponse
+// @Failure 400 {object} error "Invalid ad ID"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 403 {object} error "Forbidden"
+// @Failure 404 {object} error "Deleted ad not found"
+// @Failure 410 {object} error "Restore window has expired"
+// @Failure 500 {object} error "Failed to restore ad"
+// @Router /api/v1/ads/{id}/restore [post]
+// restoreAd handles POST /ads/:id/restore to bring back a deleted advertisement
+func (h *Handler) restoreAd(c echo.Context) error {
+	actor, ok := h.actor(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	adID, err := h.parseIDFromPath(c, "id")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	ad, err := h.services.Ads.Restore(c.Request().Context(), adID, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrAdNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "deleted ad not found")
+		case errors.Is(err, entity.ErrForbidden):
+			return echo.NewHTTPError(http.StatusForbidden, "you don't have permission to restore this ad")
+		case errors.Is(err, entity.ErrAdRestoreExpired):
+			return echo.NewHTTPError(http.StatusGone, "ad can no longer be restored")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to restore ad")
+		}
+	}
+
+	return c.JSON(http.StatusOK, newAdResponse(*ad))
+}
+
+// @Summary Upload Ad Image
+// @Description Upload a JPEG, PNG, GIF or WebP image to the end of an advertisement gallery
+// @Tags ads
+// @Accept multipart/form-data
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param id path int64 true "Ad ID"
+// @Param image formData file true "Image file"
+// @Success 201 {object} adImageResponse
+// @Failure 400 {object} error "Invalid ad ID or missing image"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 403 {object} error "Forbidden"
+// @Failure 404 {object} error "Ad not found"
+// @Failure 409 {object} error "Gallery was changed concurrently"
+// @Failure 413 {object} error "Image is too large"
+// @Failure 415 {object} error "Unsupported image type"
+// @Failure 500 {object} error "Failed to upload image"
+// @Router /api/v1/ads/{id}/images [post]
+// uploadAdImage handles POST /ads/:id/images to attach an uploaded image to an advertisement
+func (h *Handler) uploadAdImage(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	adID, err := h.parseIDFromPath(c, "id")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "image file is required")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "cannot read image file")
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	image, err := h.services.Ads.AddImage(c.Request().Context(), adID, userID, file)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrAdNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "ad not found")
+		case errors.Is(err, entity.ErrForbidden):
+			return echo.NewHTTPError(http.StatusForbidden, "you don't have permission to change this ad")
+		case errors.Is(err, entity.ErrImageTooLarge):
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "image is too large")
+		case errors.Is(err, entity.ErrUnsupportedImage):
+			return echo.NewHTTPError(http.StatusUnsupportedMediaType, "unsupported image type")
+		case errors.Is(err, entity.ErrGalleryConflict):
+			return echo.NewHTTPError(http.StatusConflict, "the gallery was changed by another request, try again")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to upload image")
+		}
+	}
+
+	return c.JSON(http.StatusCreated, newAdImageResponse(*image))
+}
+
+// @Summary Reorder Ad Images
+// @Description Set the order of all images in an advertisement gallery
+// @Tags ads
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param id path int64 true "Ad ID"
+// @Param order body reorderImagesInput true "Image IDs in the new order"
+// @Success 200 {array} adImageResponse
+// @Failure 400 {object} error "Invalid request body or input"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 403 {object} error "Forbidden"
+// @Failure 404 {object} error "Ad or image not found"
+// @Failure 500 {object} error "Failed to reorder images"
+// @Router /api/v1/ads/{id}/images/order [put]
+// reorderAdImages handles PUT /ads/:id/images/order to reorder an advertisement gallery
+func (h *Handler) reorderAdImages(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	adID, err := h.parseIDFromPath(c, "id")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var input reorderImagesInput
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return err
+	}
+
+	images, err := h.services.Ads.ReorderImages(c.Request().Context(), adID, userID, input.ImageIDs)
+	if err != nil {
+		return imageErrorResponse(err, "failed to reorder images")
+	}
+
+	return c.JSON(http.StatusOK, newAdImageResponses(images))
+}
+
+// @Summary Set Ad Cover Image
+// @Description Make an image the cover of an advertisement gallery
+// @Tags ads
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param id path int64 true "Ad ID"
+// @Param image_id path int64 true "Image ID"
+// @Success 200 {array} adImageResponse
+// @Failure 400 {object} error "Invalid ad or image ID"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 403 {object} error "Forbidden"
+// @Failure 404 {object} error "Ad or image not found"
+// @Failure 409 {object} error "Gallery was changed concurrently"
+// @Failure 500 {object} error "Failed to set cover image"
+// @Router /api/v1/ads/{id}/images/{image_id}/cover [post]
+// setAdCoverImage handles POST /ads/:id/images/:image_id/cover to change an advertisement cover image
+func (h *Handler) setAdCoverImage(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	adID, err := h.parseIDFromPath(c, "id")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	imageID, err := h.parseIDFromPath(c, "image_id")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	images, err := h.services.Ads.SetCoverImage(c.Request().Context(), adID, userID, imageID)
+	if err != nil {
+		return imageErrorResponse(err, "failed to set cover image")
+	}
+
+	return c.JSON(http.StatusOK, newAdImageResponses(images))
+}
+
+// @Summary Delete Ad Image
+// @Description Remove an image from an advertisement gallery
+// @Tags ads
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param id path int64 true "Ad ID"
+// @Param image_id path int64 true "Image ID"
+// @Success 204 "No content"
+// @Failure 400 {object} error "Invalid ad or image ID"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 403 {object} error "Forbidden"
+// @Failure 404 {object} error "Ad or image not found"
+// @Failure 409 {object} error "Gallery was changed concurrently"
+// @Failure 500 {object} error "Failed to delete image"
+// @Router /api/v1/ads/{id}/images/{image_id} [delete]
+// deleteAdImage handles DELETE /ads/:id/images/:image_id to remove an image from an advertisement gallery
+func (h *Handler) deleteAdImage(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	adID, err := h.parseIDFromPath(c, "id")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	imageID, err := h.parseIDFromPath(c, "image_id")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.services.Ads.DeleteImage(c.Request().Context(), adID, userID, imageID); err != nil {
+		return imageErrorResponse(err, "failed to delete image")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// imageErrorResponse maps errors of ad gallery operations to HTTP errors
+func imageErrorResponse(err error, internalMessage string) error {
+	switch {
+	case errors.Is(err, entity.ErrAdNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "ad not found")
+	case errors.Is(err, entity.ErrImageNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "image not found")
+	case errors.Is(err, entity.ErrForbidden):
+		return echo.NewHTTPError(http.StatusForbidden, "you don't have permission to change this ad")
+	case errors.Is(err, entity.ErrInvalidInput):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, entity.ErrGalleryConflict):
+		return echo.NewHTTPError(http.StatusConflict, "the gallery was changed by another request, try again")
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, internalMessage)
+	}
+}
@@ -0,0 +1,229 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/internal/middleware"
+	"rest-api-marketplace/internal/service"
+)
+
+// initCategoriesRoutes registers all /categories endpoints with proper middlewares
+func (h *Handler) initCategoriesRoutes(api *echo.Group) {
+	categories := api.Group("/categories")
+	{
+		authMiddleware := middleware.JWTAuth(h.tokenManager, h.revocations)
+		adminMiddleware := middleware.RequireRole(entity.RoleAdmin)
+		categories.GET("", h.listCategories)
+		categories.GET("/:id", h.getCategoryByID)
+		categories.POST("", h.createCategory, authMiddleware, adminMiddleware)
+		categories.PUT("/:id", h.updateCategory, authMiddleware, adminMiddleware)
+		categories.DELETE("/:id", h.deleteCategory, authMiddleware, adminMiddleware)
+	}
+}
+
+// categoryInput defines input structure for creating or updating a category
+type categoryInput struct {
+	ParentID *int64 `json:"parent_id" validate:"omitempty,gt=0"`
+	Name     string `json:"name" validate:"required,min=1,max=100"`
+}
+
+// @Summary List Categories
+// @Description Retrieve the full category tree
+// @Tags categories
+// @Produce json
+// @Success 200 {array} categoryNodeResponse
+// @Failure 500 {object} error "Failed to get categories"
+// @Router /api/v1/categories [get]
+// listCategories handles GET /categories to retrieve the category tree
+func (h *Handler) listCategories(c echo.Context) error {
+	tree, err := h.services.Categories.GetTree(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get categories")
+	}
+
+	return c.JSON(http.StatusOK, newCategoryNodeResponses(tree))
+}
+
+// @Summary Get Category by ID
+// @Description Retrieve a single category by its ID
+// @Tags categories
+// @Produce json
+// @Param id path int64 true "Category ID"
+// @Success 200 {object} categoryResponse
+// @Failure 400 {object} error "Invalid category ID"
+// @Failure 404 {object} error "Category not found"
+// @Failure 500 {object} error "Failed to get category"
+// @Router /api/v1/categories/{id} [get]
+// getCategoryByID handles GET /categories/:id to retrieve a single category
+func (h *Handler) getCategoryByID(c echo.Context) error {
+	id, err := h.parseIDFromPath(c, "id")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	category, err := h.services.Categories.GetByID(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, entity.ErrCategoryNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "category not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get category")
+	}
+
+	return c.JSON(http.StatusOK, newCategoryResponse(*category))
+}
+
+// @Summary Create Category
+// @Description Create a new category, optionally nested under a parent category. Admins only
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param category body categoryInput true "Category details"
+// @Success 201 {object} categoryResponse
+// @Failure 400 {object} error "Invalid request body or input, or parent category not found"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 403 {object} error "Forbidden"
+// @Failure 500 {object} error "Failed to create category"
+// @Router /api/v1/categories [post]
+// createCategory handles POST /categories to create a new category
+func (h *Handler) createCategory(c echo.Context) error {
+	actor, ok := h.actor(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	var input categoryInput
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return err
+	}
+
+	category, err := h.services.Categories.Create(c.Request().Context(), actor, service.CategoryInput{
+		ParentID: input.ParentID,
+		Name:     input.Name,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrForbidden):
+			return echo.NewHTTPError(http.StatusForbidden, "only admins can manage categories")
+		case errors.Is(err, entity.ErrParentCategoryNotFound):
+			return echo.NewHTTPError(http.StatusBadRequest, "parent category not found")
+		case errors.Is(err, entity.ErrInvalidInput):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create category")
+		}
+	}
+
+	return c.JSON(http.StatusCreated, newCategoryResponse(*category))
+}
+
+// @Summary Update Category
+// @Description Rename a category or move it under another parent. Admins only
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param id path int64 true "Category ID"
+// @Param category body categoryInput true "Category details"
+// @Success 200 {object} categoryResponse
+// @Failure 400 {object} error "Invalid request body or input, parent category not found or nesting cycle"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 403 {object} error "Forbidden"
+// @Failure 404 {object} error "Category not found"
+// @Failure 500 {object} error "Failed to update category"
+// @Router /api/v1/categories/{id} [put]
+// updateCategory handles PUT /categories/:id to update an existing category
+func (h *Handler) updateCategory(c echo.Context) error {
+	actor, ok := h.actor(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	id, err := h.parseIDFromPath(c, "id")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var input categoryInput
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return err
+	}
+
+	category, err := h.services.Categories.Update(c.Request().Context(), actor, id, service.CategoryInput{
+		ParentID: input.ParentID,
+		Name:     input.Name,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrForbidden):
+			return echo.NewHTTPError(http.StatusForbidden, "only admins can manage categories")
+		case errors.Is(err, entity.ErrCategoryNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "category not found")
+		case errors.Is(err, entity.ErrParentCategoryNotFound):
+			return echo.NewHTTPError(http.StatusBadRequest, "parent category not found")
+		case errors.Is(err, entity.ErrCategoryCycle):
+			return echo.NewHTTPError(http.StatusBadRequest, "category cannot be nested under itself or its subcategory")
+		case errors.Is(err, entity.ErrInvalidInput):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update category")
+		}
+	}
+
+	return c.JSON(http.StatusOK, newCategoryResponse(*category))
+}
+
+// @Summary Delete Category
+// @Description Delete a category without subcategories, its ads become uncategorized. Admins only
+// @Tags categories
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param id path int64 true "Category ID"
+// @Success 204 "No content"
+// @Failure 400 {object} error "Invalid category ID"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 403 {object} error "Forbidden"
+// @Failure 404 {object} error "Category not found"
+// @Failure 409 {object} error "Category has subcategories"
+// @Failure 500 {object} error "Failed to delete category"
+// @Router /api/v1/categories/{id} [delete]
+// deleteCategory handles DELETE /categories/:id to remove a category
+func (h *Handler) deleteCategory(c echo.Context) error {
+	actor, ok := h.actor(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	id, err := h.parseIDFromPath(c, "id")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.services.Categories.Delete(c.Request().Context(), actor, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrForbidden):
+			return echo.NewHTTPError(http.StatusForbidden, "only admins can manage categories")
+		case errors.Is(err, entity.ErrCategoryNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "category not found")
+		case errors.Is(err, entity.ErrCategoryHasChildren):
+			return echo.NewHTTPError(http.StatusConflict, "category has subcategories")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete category")
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,32 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// initExchangeRatesRoutes registers all /exchange-rates endpoints
+func (h *Handler) initExchangeRatesRoutes(api *echo.Group) {
+	rates := api.Group("/exchange-rates")
+	{
+		rates.GET("", h.listExchangeRates)
+	}
+}
+
+// @Summary List Exchange Rates
+// @Description Retrieve exchange rates used to convert ad prices, each rate is the amount of the currency worth one USD
+// @Tags exchange-rates
+// @Produce json
+// @Success 200 {array} exchangeRateResponse
+// @Failure 500 {object} error "Failed to get exchange rates"
+// @Router /api/v1/exchange-rates [get]
+// listExchangeRates handles GET /exchange-rates to retrieve all known exchange rates
+func (h *Handler) listExchangeRates(c echo.Context) error {
+	rates, err := h.services.ExchangeRates.GetAll(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get exchange rates")
+	}
+
+	return c.JSON(http.StatusOK, newExchangeRateResponses(rates))
+}
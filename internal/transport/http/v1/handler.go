@@ -7,21 +7,28 @@ import (
 
 	"github.com/labstack/echo/v4"
 
+	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/internal/middleware"
 	"rest-api-marketplace/internal/service"
 	"rest-api-marketplace/pkg/auth"
 )
 
-// Handler holds services and token manager to handle HTTP requests
+// Handler holds services, token manager and revocation store to handle HTTP requests
 type Handler struct {
 	services     *service.Services
 	tokenManager auth.TokenManager
+	revocations  auth.RevocationStore
+	maxImageSize int64
 }
 
-// NewHandler creates a new HTTP handler with given services and token manager
-func NewHandler(services *service.Services, tokenManager auth.TokenManager) *Handler {
+// NewHandler creates a new HTTP handler with given services, token manager, revocation store
+// and the largest accepted image upload
+func NewHandler(services *service.Services, tokenManager auth.TokenManager, revocations auth.RevocationStore, maxImageSize int64) *Handler {
 	return &Handler{
 		services:     services,
 		tokenManager: tokenManager,
+		revocations:  revocations,
+		maxImageSize: maxImageSize,
 	}
 }
 
@@ -31,9 +38,25 @@ func (h *Handler) Init(api *echo.Group) {
 	{
 		h.initUsersRoutes(v1)
 		h.initAdsRoutes(v1)
+		h.initCategoriesRoutes(v1)
+		h.initExchangeRatesRoutes(v1)
+		h.initMediaRoutes(v1)
 	}
 }
 
+// actor returns the authenticated user and their role set by the JWT middleware
+func (h *Handler) actor(c echo.Context) (service.Actor, bool) {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return service.Actor{}, false
+	}
+	role, ok := c.Get(middleware.CtxUserRole).(entity.Role)
+	if !ok {
+		return service.Actor{}, false
+	}
+	return service.Actor{UserID: userID, Role: role}, true
+}
+
 // parseIDFromPath extracts and validates an integer ID parameter from the URL path
 func (h *Handler) parseIDFromPath(c echo.Context, param string) (int64, error) {
 	idStr := c.Param(param)
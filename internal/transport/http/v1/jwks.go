@@ -0,0 +1,24 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// InitWellKnown registers discovery endpoints under /.well-known, outside of the versioned API
+func (h *Handler) InitWellKnown(wellKnown *echo.Group) {
+	wellKnown.GET("/jwks.json", h.getJWKS)
+}
+
+// @Summary JSON Web Key Set
+// @Description Public keys access tokens are signed with, the token kid header selects the key. Rotated out keys stay here while tokens signed with them may still be valid
+// @Tags auth
+// @Produce json
+// @Success 200 {object} jwksResponse
+// @Router /.well-known/jwks.json [get]
+// getJWKS handles GET /.well-known/jwks.json to publish the token verification keys
+func (h *Handler) getJWKS(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, newJWKSResponse(h.tokenManager.JWKS()))
+}
@@ -0,0 +1,55 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/internal/middleware"
+)
+
+// initMediaRoutes registers /media endpoints serving uploaded files
+func (h *Handler) initMediaRoutes(api *echo.Group) {
+	api.GET("/media/*", h.getMedia, middleware.JWTOptionalAuth(h.tokenManager, h.revocations))
+}
+
+// @Summary Get Media
+// @Description Download an uploaded file by its path. Images of unpublished ads are only served to their owner
+// @Tags media
+// @Produce image/jpeg,image/png,image/gif,image/webp
+// @Param Authorization header string false "Bearer <token>"
+// @Param path path string true "File path"
+// @Success 200 {file} file
+// @Failure 404 {object} error "File not found"
+// @Failure 500 {object} error "Failed to get file"
+// @Router /api/v1/media/{path} [get]
+// getMedia handles GET /media/* to stream an uploaded file
+func (h *Handler) getMedia(c echo.Context) error {
+	var currentUserID *int64
+	if userID, ok := c.Get(middleware.CtxUserID).(int64); ok {
+		currentUserID = &userID
+	}
+
+	rc, contentType, err := h.services.Ads.OpenImage(c.Request().Context(), c.Param("*"), currentUserID)
+	if err != nil {
+		if errors.Is(err, entity.ErrImageNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "file not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get file")
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+	// an ad can be unpublished at any time, so shared caches may only keep its images for a while,
+	// and images served to a signed in user may belong to their unpublished ad
+	if currentUserID != nil {
+		c.Response().Header().Set("Cache-Control", "private, max-age=300")
+	} else {
+		c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	}
+	return c.Stream(http.StatusOK, contentType, rc)
+}
@@ -0,0 +1,245 @@
+package v1
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/internal/middleware"
+	"rest-api-marketplace/internal/service"
+)
+
+const (
+	// oauthStateCookie binds a provider callback to the browser that started the flow, against login CSRF
+	oauthStateCookie = "oauth_state"
+	// oauthCookiePath limits the state cookie to the callback
+	oauthCookiePath = "/api/v1/users/oauth"
+)
+
+// oauthProvidersResponse represents the identity providers users can sign in with
+type oauthProvidersResponse struct {
+	Providers []string `json:"providers" example:"google,mock"`
+}
+
+// oauthStartResponse represents the provider URL a signed-in user is sent to for linking an account
+type oauthStartResponse struct {
+	AuthorizationURL string    `json:"authorization_url"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// @Summary List Identity Providers
+// @Description List identity providers users can sign in with
+// @Tags users
+// @Produce json
+// @Success 200 {object} oauthProvidersResponse
+// @Router /api/v1/users/oauth/providers [get]
+// listOAuthProviders handles GET /users/oauth/providers to list configured identity providers
+func (h *Handler) listOAuthProviders(c echo.Context) error {
+	return c.JSON(http.StatusOK, oauthProvidersResponse{Providers: h.services.Users.OAuthProviders()})
+}
+
+// @Summary Start External Sign In
+// @Description Redirect the browser to the identity provider to sign in with an OpenID Connect authorization code flow with PKCE. A short-lived cookie ties the callback to this browser
+// @Tags users
+// @Param provider path string true "Identity provider name"
+// @Success 302 "Redirect to the identity provider"
+// @Failure 404 {object} error "Unknown identity provider"
+// @Failure 500 {object} error "Failed to start sign-in"
+// @Router /api/v1/users/oauth/{provider}/start [get]
+// startOAuth handles GET /users/oauth/:provider/start to send the user to the identity provider
+func (h *Handler) startOAuth(c echo.Context) error {
+	start, err := h.services.Users.StartOAuth(c.Request().Context(), c.Param("provider"), nil)
+	if err != nil {
+		if errors.Is(err, entity.ErrUnknownProvider) {
+			return echo.NewHTTPError(http.StatusNotFound, "unknown identity provider")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to start sign-in")
+	}
+
+	setOAuthStateCookie(c, start)
+	return c.Redirect(http.StatusFound, start.AuthURL)
+}
+
+// @Summary External Sign In Callback
+// @Description The identity provider redirects the browser here after the user signed in. The user the provider account is linked to is signed in, a user with the same email verified on both sides gets the account linked, otherwise a new user without a password is registered. Users with two-factor authentication get a challenge token as with a password sign-in. When the flow was started to link an account, the linked identity is returned instead
+// @Tags users
+// @Produce json
+// @Param provider path string true "Identity provider name"
+// @Param code query string false "Authorization code"
+// @Param state query string true "State from the start of the flow"
+// @Param error query string false "Error reported by the provider"
+// @Success 200 {object} signInResponse
+// @Success 201 {object} identityResponse "Provider account linked to the user who started the flow"
+// @Failure 400 {object} error "Invalid or expired state, or the sign-in was denied at the provider"
+// @Failure 401 {object} error "The provider rejected the code or returned an invalid ID token"
+// @Failure 404 {object} error "Unknown identity provider"
+// @Failure 409 {object} error "Email or provider account belongs to another user, or a provider account is already linked"
+// @Failure 500 {object} error "Failed to sign in"
+// @Router /api/v1/users/oauth/{provider}/callback [get]
+// oauthCallback handles GET /users/oauth/:provider/callback to finish an external sign-in
+func (h *Handler) oauthCallback(c echo.Context) error {
+	state := c.QueryParam("state")
+
+	cookie, err := c.Cookie(oauthStateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid or expired external sign-in state, start again")
+	}
+	clearOAuthStateCookie(c)
+
+	if c.QueryParam("error") != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "external sign-in was denied or cancelled")
+	}
+
+	res, err := h.services.Users.CompleteOAuth(c.Request().Context(), c.Param("provider"), c.QueryParam("code"), state, clientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrUnknownProvider):
+			return echo.NewHTTPError(http.StatusNotFound, "unknown identity provider")
+		case errors.Is(err, entity.ErrInvalidOAuthState):
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid or expired external sign-in state, start again")
+		case errors.Is(err, entity.ErrExternalAuthFailed):
+			return echo.NewHTTPError(http.StatusUnauthorized, "external sign-in failed")
+		case errors.Is(err, entity.ErrEmailTaken):
+			return echo.NewHTTPError(http.StatusConflict, "a user with this email already exists, sign in and link the provider from your account")
+		case errors.Is(err, entity.ErrIdentityLinked):
+			return echo.NewHTTPError(http.StatusConflict, "this provider account is already linked to another user")
+		case errors.Is(err, entity.ErrProviderLinked):
+			return echo.NewHTTPError(http.StatusConflict, "an account of this provider is already linked")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to sign in")
+		}
+	}
+
+	if res.Linked != nil {
+		return c.JSON(http.StatusCreated, newIdentityResponse(*res.Linked))
+	}
+	return c.JSON(http.StatusOK, newSignInResponse(res.SignIn))
+}
+
+// @Summary List Linked Identities
+// @Description List identity provider accounts linked to the current user
+// @Tags users
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Success 200 {array} identityResponse
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 500 {object} error "Failed to get identities"
+// @Router /api/v1/users/me/identities [get]
+// listUserIdentities handles GET /users/me/identities to list linked identities of the current user
+func (h *Handler) listUserIdentities(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	identities, err := h.services.Users.GetIdentities(c.Request().Context(), userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get identities")
+	}
+
+	return c.JSON(http.StatusOK, newIdentityResponses(identities))
+}
+
+// @Summary Link Identity
+// @Description Start linking an identity provider account to the current user. The browser has to open the returned URL, the callback returns the linked identity. The response sets a short-lived cookie the callback checks, so it must be kept by the browser
+// @Tags users
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param provider path string true "Identity provider name"
+// @Success 200 {object} oauthStartResponse
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 404 {object} error "Unknown identity provider"
+// @Failure 500 {object} error "Failed to start linking"
+// @Router /api/v1/users/me/identities/{provider} [post]
+// linkUserIdentity handles POST /users/me/identities/:provider to start linking a provider account
+func (h *Handler) linkUserIdentity(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	start, err := h.services.Users.StartOAuth(c.Request().Context(), c.Param("provider"), &userID)
+	if err != nil {
+		if errors.Is(err, entity.ErrUnknownProvider) {
+			return echo.NewHTTPError(http.StatusNotFound, "unknown identity provider")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to start linking")
+	}
+
+	setOAuthStateCookie(c, start)
+	return c.JSON(http.StatusOK, oauthStartResponse{
+		AuthorizationURL: start.AuthURL,
+		ExpiresAt:        start.ExpiresAt,
+	})
+}
+
+// @Summary Unlink Identity
+// @Description Unlink an identity provider account from the current user. Users without a password can't unlink their last identity
+// @Tags users
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param id path int64 true "Identity ID"
+// @Success 204 "No content"
+// @Failure 400 {object} error "Invalid identity ID"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 404 {object} error "Identity not found"
+// @Failure 409 {object} error "The identity is the last way to sign in"
+// @Failure 500 {object} error "Failed to unlink identity"
+// @Router /api/v1/users/me/identities/{id} [delete]
+// unlinkUserIdentity handles DELETE /users/me/identities/:id to unlink an identity of the current user
+func (h *Handler) unlinkUserIdentity(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	identityID, err := h.parseIDFromPath(c, "id")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.services.Users.UnlinkIdentity(c.Request().Context(), userID, identityID); err != nil {
+		switch {
+		case errors.Is(err, entity.ErrIdentityNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "identity not found")
+		case errors.Is(err, entity.ErrLastSignInMethod):
+			return echo.NewHTTPError(http.StatusConflict, "set a password or link another provider before unlinking the last one")
+		case errors.Is(err, entity.ErrUserNotFound):
+			return echo.NewHTTPError(http.StatusUnauthorized, "user not found")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to unlink identity")
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// setOAuthStateCookie remembers the state of a started flow in the browser until it expires
+func setOAuthStateCookie(c echo.Context, start service.OAuthStart) {
+	c.SetCookie(&http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    start.State,
+		Path:     oauthCookiePath,
+		Expires:  start.ExpiresAt,
+		MaxAge:   int(time.Until(start.ExpiresAt).Seconds()),
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearOAuthStateCookie removes the state cookie once the callback used it
+func clearOAuthStateCookie(c echo.Context) {
+	c.SetCookie(&http.Cookie{
+		Name:     oauthStateCookie,
+		Path:     oauthCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
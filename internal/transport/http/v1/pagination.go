@@ -0,0 +1,117 @@
+package v1
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"rest-api-marketplace/internal/entity"
+)
+
+// adsPageResponse is a page of ads, next_cursor is passed as the cursor parameter to get the next page
+type adsPageResponse struct {
+	Items      []adResponse `json:"items"`
+	Total      *int64       `json:"total,omitempty"`
+	Page       int          `json:"page"`
+	Limit      int          `json:"limit"`
+	HasNext    bool         `json:"has_next"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	Links      pageLinks    `json:"links"`
+}
+
+// pageLinks holds URLs of the neighbouring pages, a link is empty if there is no such page
+type pageLinks struct {
+	Prev string `json:"prev,omitempty"`
+	Next string `json:"next,omitempty"`
+}
+
+// newAdsPageResponse builds the list envelope with navigation links relative to the request URL.
+// Pages fetched by cursor only link forward, because a cursor can't be followed backwards
+func newAdsPageResponse(requestURL *url.URL, adsPage *entity.AdsPage, page, limit int, byCursor bool) adsPageResponse {
+	res := adsPageResponse{
+		Items:      newAdWithAuthorResponses(adsPage.Items),
+		Total:      adsPage.Total,
+		Page:       page,
+		Limit:      limit,
+		HasNext:    adsPage.NextCursor != nil,
+		NextCursor: encodeCursor(adsPage.NextCursor),
+	}
+
+	if res.HasNext {
+		if byCursor {
+			res.Links.Next = pageLink(requestURL, "cursor", res.NextCursor)
+		} else {
+			res.Links.Next = pageLink(requestURL, "page", strconv.Itoa(page+1))
+		}
+	}
+	if !byCursor && page > 1 {
+		res.Links.Prev = pageLink(requestURL, "page", strconv.Itoa(page-1))
+	}
+
+	return res
+}
+
+// pageLink returns the request path and query with one query parameter replaced
+func pageLink(requestURL *url.URL, param, value string) string {
+	query := requestURL.Query()
+	query.Set(param, value)
+	return requestURL.Path + "?" + query.Encode()
+}
+
+// cursorPayload is the JSON content of an opaque cursor
+type cursorPayload struct {
+	SortBy  string  `json:"s,omitempty"`
+	SortDir string  `json:"d"`
+	Key     *string `json:"k"`
+	ID      int64   `json:"i"`
+}
+
+// encodeCursor turns a cursor into an opaque URL-safe string
+func encodeCursor(cursor *entity.AdsCursor) string {
+	if cursor == nil {
+		return ""
+	}
+
+	data, _ := json.Marshal(cursorPayload{
+		SortBy:  cursor.SortBy,
+		SortDir: cursor.SortDir,
+		Key:     cursor.Key,
+		ID:      cursor.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses an opaque cursor and checks that it was issued for the same sorting
+func decodeCursor(raw, sortBy, sortDir string) (*entity.AdsCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+
+	var payload cursorPayload
+	if err := json.Unmarshal(data, &payload); err != nil || payload.ID <= 0 {
+		return nil, errors.New("malformed cursor")
+	}
+
+	if payload.SortBy != sortBy || payload.SortDir != normalizeSortDir(sortDir) {
+		return nil, errors.New("cursor was issued for another sorting")
+	}
+
+	return &entity.AdsCursor{
+		SortBy:  payload.SortBy,
+		SortDir: payload.SortDir,
+		Key:     payload.Key,
+		ID:      payload.ID,
+	}, nil
+}
+
+// normalizeSortDir returns "asc" or "desc" the same way the ads repository treats sort_dir
+func normalizeSortDir(sortDir string) string {
+	if strings.ToLower(sortDir) == "asc" {
+		return "asc"
+	}
+	return "desc"
+}
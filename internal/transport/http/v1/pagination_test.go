@@ -0,0 +1,132 @@
+package v1
+
+import (
+	"encoding/base64"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"rest-api-marketplace/internal/entity"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	price := "1234.50"
+
+	tests := []struct {
+		name    string
+		cursor  entity.AdsCursor
+		sortBy  string
+		sortDir string
+	}{
+		{name: "date", cursor: entity.AdsCursor{SortBy: "date", SortDir: "desc", Key: strPtr("2026-01-01T12:00:00Z"), ID: 42}, sortBy: "date", sortDir: "desc"},
+		{name: "price ascending", cursor: entity.AdsCursor{SortBy: "price", SortDir: "asc", Key: &price, ID: 7}, sortBy: "price", sortDir: "ASC"},
+		{name: "ad without converted price", cursor: entity.AdsCursor{SortBy: "price", SortDir: "desc", ID: 3}, sortBy: "price", sortDir: ""},
+		{name: "default sorting", cursor: entity.AdsCursor{SortDir: "desc", Key: strPtr("x"), ID: 1}, sortBy: "", sortDir: "DESC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := encodeCursor(&tt.cursor)
+			if _, err := url.ParseQuery("cursor=" + raw); err != nil || url.QueryEscape(raw) != raw {
+				t.Errorf("encodeCursor() = %q is not URL-safe", raw)
+			}
+
+			got, err := decodeCursor(raw, tt.sortBy, tt.sortDir)
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.cursor) {
+				t.Errorf("decodeCursor() = %+v, want %+v", *got, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestEncodeNilCursor(t *testing.T) {
+	if got := encodeCursor(nil); got != "" {
+		t.Errorf("encodeCursor(nil) = %q, want empty", got)
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	valid := encodeCursor(&entity.AdsCursor{SortBy: "price", SortDir: "asc", Key: strPtr("10.00"), ID: 5})
+	encode := func(payload string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(payload))
+	}
+
+	tests := []struct {
+		name    string
+		raw     string
+		sortBy  string
+		sortDir string
+	}{
+		{name: "another sort key", raw: valid, sortBy: "date", sortDir: "asc"},
+		{name: "another direction", raw: valid, sortBy: "price", sortDir: "desc"},
+		{name: "empty", raw: "", sortBy: "price", sortDir: "asc"},
+		{name: "not base64", raw: "!!!", sortBy: "price", sortDir: "asc"},
+		{name: "padded base64", raw: base64.URLEncoding.EncodeToString([]byte(`{"s":"price","d":"asc","k":"1","i":5}`)), sortBy: "price", sortDir: "asc"},
+		{name: "not JSON", raw: encode("price:asc:5"), sortBy: "price", sortDir: "asc"},
+		{name: "zero ID", raw: encode(`{"s":"price","d":"asc","k":"1","i":0}`), sortBy: "price", sortDir: "asc"},
+		{name: "negative ID", raw: encode(`{"s":"price","d":"asc","k":"1","i":-1}`), sortBy: "price", sortDir: "asc"},
+		{name: "missing ID", raw: encode(`{"s":"price","d":"asc","k":"1"}`), sortBy: "price", sortDir: "asc"},
+		{name: "ID of a wrong type", raw: encode(`{"s":"price","d":"asc","k":"1","i":"5"}`), sortBy: "price", sortDir: "asc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := decodeCursor(tt.raw, tt.sortBy, tt.sortDir); err == nil {
+				t.Errorf("decodeCursor() = %+v, want an error", got)
+			}
+		})
+	}
+}
+
+func TestNewAdsPageResponseLinks(t *testing.T) {
+	requestURL, _ := url.Parse("/api/v1/ads?limit=10&page=2&sort_by=price")
+	next := &entity.AdsCursor{SortBy: "price", SortDir: "desc", Key: strPtr("5.00"), ID: 9}
+
+	tests := []struct {
+		name     string
+		next     *entity.AdsCursor
+		page     int
+		byCursor bool
+		wantPrev string
+		wantNext string
+	}{
+		{
+			name:     "middle page",
+			next:     next,
+			page:     2,
+			wantPrev: "/api/v1/ads?limit=10&page=1&sort_by=price",
+			wantNext: "/api/v1/ads?limit=10&page=3&sort_by=price",
+		},
+		{name: "last page", page: 2, wantPrev: "/api/v1/ads?limit=10&page=1&sort_by=price"},
+		{name: "first and last page", page: 1},
+		{
+			name:     "by cursor links forward only",
+			next:     next,
+			page:     2,
+			byCursor: true,
+			wantNext: "/api/v1/ads?cursor=" + encodeCursor(next) + "&limit=10&page=2&sort_by=price",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := newAdsPageResponse(requestURL, &entity.AdsPage{NextCursor: tt.next}, tt.page, 10, tt.byCursor)
+			if res.HasNext != (tt.next != nil) {
+				t.Errorf("HasNext = %v, want %v", res.HasNext, tt.next != nil)
+			}
+			if res.Links.Prev != tt.wantPrev {
+				t.Errorf("Links.Prev = %q, want %q", res.Links.Prev, tt.wantPrev)
+			}
+			if res.Links.Next != tt.wantNext {
+				t.Errorf("Links.Next = %q, want %q", res.Links.Next, tt.wantNext)
+			}
+		})
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
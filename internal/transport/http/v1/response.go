@@ -0,0 +1,310 @@
+package v1
+
+import (
+	"time"
+
+	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/pkg/auth"
+	"rest-api-marketplace/pkg/money"
+)
+
+// adResponse is the public shape of an ad, author and ownership fields are set only in read endpoints
+type adResponse struct {
+	ID             int64             `json:"id" example:"42"`
+	UserID         int64             `json:"user_id" example:"7"`
+	CategoryID     *int64            `json:"category_id" example:"3"`
+	Title          string            `json:"title" example:"Road bike"`
+	Description    string            `json:"description" example:"Aluminium frame, 21 speed"`
+	ImageURL       string            `json:"image_url"`
+	Images         []adImageResponse `json:"images"`
+	Price          money.Money       `json:"price"`
+	ConvertedPrice *money.Money      `json:"converted_price,omitempty"`
+	Status         string            `json:"status" enums:"draft,published,reserved,sold,archived,hidden" example:"published"`
+	CreatedAt      time.Time         `json:"created_at"`
+	DeletedAt      *time.Time        `json:"deleted_at,omitempty"`
+	AuthorLogin    string            `json:"author_login,omitempty" example:"john"`
+	IsOwner        *bool             `json:"is_owner,omitempty"`
+}
+
+// adImageResponse is the public shape of an image in an ad gallery
+type adImageResponse struct {
+	ID          int64                    `json:"id" example:"15"`
+	AdID        int64                    `json:"ad_id" example:"42"`
+	URL         string                   `json:"url" example:"/api/v1/media/ads/42/1f2e3d.jpg"`
+	ContentType string                   `json:"content_type" example:"image/jpeg"`
+	Size        int64                    `json:"size" example:"204800"`
+	Position    int                      `json:"position" example:"0"`
+	IsCover     bool                     `json:"is_cover"`
+	Variants    []adImageVariantResponse `json:"variants"`
+	CreatedAt   time.Time                `json:"created_at"`
+}
+
+// adImageVariantResponse is the public shape of a resized copy of an ad image
+type adImageVariantResponse struct {
+	Size   int    `json:"size" example:"400"`
+	Width  int    `json:"width" example:"400"`
+	Height int    `json:"height" example:"300"`
+	URL    string `json:"url" example:"/api/v1/media/ads/42/1f2e3d_400.jpg"`
+}
+
+// categoryResponse is the public shape of a category
+type categoryResponse struct {
+	ID        int64     `json:"id" example:"3"`
+	ParentID  *int64    `json:"parent_id" example:"1"`
+	Name      string    `json:"name" example:"Bicycles"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// categoryNodeResponse is the public shape of a category with its subcategories
+type categoryNodeResponse struct {
+	categoryResponse
+	Children []categoryNodeResponse `json:"children"`
+}
+
+// exchangeRateResponse is the public shape of an exchange rate to USD
+type exchangeRateResponse struct {
+	Currency  string    `json:"currency" example:"EUR"`
+	Rate      string    `json:"rate" example:"0.92"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// userResponse is the public shape of a user
+type userResponse struct {
+	ID            int64     `json:"id" example:"7"`
+	Login         string    `json:"login" example:"john"`
+	Email         string    `json:"email,omitempty" example:"john@example.com"`
+	EmailVerified bool      `json:"email_verified"`
+	Role          string    `json:"role" example:"user"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// sessionResponse is the public shape of a signed in device
+type sessionResponse struct {
+	ID         int64     `json:"id" example:"12"`
+	UserAgent  string    `json:"user_agent" example:"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)"`
+	IP         string    `json:"ip" example:"203.0.113.10"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// lockoutResponse is the public shape of an account lockout after failed sign-in attempts
+type lockoutResponse struct {
+	ID          int64     `json:"id" example:"3"`
+	IP          string    `json:"ip" example:"203.0.113.10"`
+	Failures    int       `json:"failures" example:"10"`
+	LockedUntil time.Time `json:"locked_until"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// identityResponse is the public shape of an identity provider account linked to a user
+type identityResponse struct {
+	ID        int64     `json:"id" example:"4"`
+	Provider  string    `json:"provider" example:"google"`
+	Subject   string    `json:"subject" example:"110248495921238986420"`
+	Email     string    `json:"email,omitempty" example:"jane@example.com"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// jwkResponse is the public shape of a token verification key in JSON Web Key format
+type jwkResponse struct {
+	KeyType   string `json:"kty" example:"OKP"`
+	KeyID     string `json:"kid" example:"2026-10"`
+	Use       string `json:"use" example:"sig"`
+	Algorithm string `json:"alg" example:"EdDSA"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty" example:"AQAB"`
+	Curve     string `json:"crv,omitempty" example:"Ed25519"`
+	X         string `json:"x,omitempty"`
+}
+
+// jwksResponse is the public shape of the set of token verification keys
+type jwksResponse struct {
+	Keys []jwkResponse `json:"keys"`
+}
+
+// newAdResponse maps an ad to its public shape
+func newAdResponse(ad entity.Ad) adResponse {
+	return adResponse{
+		ID:          ad.ID,
+		UserID:      ad.UserID,
+		CategoryID:  ad.CategoryID,
+		Title:       ad.Title,
+		Description: ad.Description,
+		ImageURL:    ad.ImageURL,
+		Images:      newAdImageResponses(ad.Images),
+		Price:       ad.Price,
+		Status:      string(ad.Status),
+		CreatedAt:   ad.CreatedAt,
+		DeletedAt:   ad.DeletedAt,
+	}
+}
+
+// newAdWithAuthorResponse maps an ad with author and ownership info to its public shape
+func newAdWithAuthorResponse(res entity.AdResponse) adResponse {
+	return adResponse{
+		ID:             res.ID,
+		UserID:         res.UserID,
+		CategoryID:     res.CategoryID,
+		Title:          res.Title,
+		Description:    res.Description,
+		ImageURL:       res.ImageURL,
+		Images:         newAdImageResponses(res.Images),
+		Price:          res.Price,
+		ConvertedPrice: res.ConvertedPrice,
+		Status:         string(res.Status),
+		CreatedAt:      res.CreatedAt,
+		AuthorLogin:    res.AuthorLogin,
+		IsOwner:        res.IsOwner,
+	}
+}
+
+// newAdWithAuthorResponses maps a list of ads with author info to their public shape
+func newAdWithAuthorResponses(items []entity.AdResponse) []adResponse {
+	res := make([]adResponse, len(items))
+	for i, item := range items {
+		res[i] = newAdWithAuthorResponse(item)
+	}
+	return res
+}
+
+// newAdImageResponse maps an ad image to its public shape
+func newAdImageResponse(image entity.AdImage) adImageResponse {
+	variants := make([]adImageVariantResponse, len(image.Variants))
+	for i, variant := range image.Variants {
+		variants[i] = adImageVariantResponse{
+			Size:   variant.Size,
+			Width:  variant.Width,
+			Height: variant.Height,
+			URL:    variant.URL,
+		}
+	}
+
+	return adImageResponse{
+		ID:          image.ID,
+		AdID:        image.AdID,
+		URL:         image.URL,
+		ContentType: image.ContentType,
+		Size:        image.Size,
+		Position:    image.Position,
+		IsCover:     image.IsCover,
+		Variants:    variants,
+		CreatedAt:   image.CreatedAt,
+	}
+}
+
+// newAdImageResponses maps an ad gallery to its public shape
+func newAdImageResponses(images []entity.AdImage) []adImageResponse {
+	res := make([]adImageResponse, len(images))
+	for i, image := range images {
+		res[i] = newAdImageResponse(image)
+	}
+	return res
+}
+
+// newCategoryResponse maps a category to its public shape
+func newCategoryResponse(category entity.Category) categoryResponse {
+	return categoryResponse{
+		ID:        category.ID,
+		ParentID:  category.ParentID,
+		Name:      category.Name,
+		CreatedAt: category.CreatedAt,
+	}
+}
+
+// newCategoryNodeResponses maps a category tree to its public shape
+func newCategoryNodeResponses(nodes []entity.CategoryNode) []categoryNodeResponse {
+	res := make([]categoryNodeResponse, len(nodes))
+	for i, node := range nodes {
+		res[i] = categoryNodeResponse{
+			categoryResponse: newCategoryResponse(node.Category),
+			Children:         newCategoryNodeResponses(node.Children),
+		}
+	}
+	return res
+}
+
+// newExchangeRateResponses maps exchange rates to their public shape
+func newExchangeRateResponses(rates []entity.ExchangeRate) []exchangeRateResponse {
+	res := make([]exchangeRateResponse, len(rates))
+	for i, rate := range rates {
+		res[i] = exchangeRateResponse{
+			Currency:  string(rate.Currency),
+			Rate:      rate.Rate,
+			UpdatedAt: rate.UpdatedAt,
+		}
+	}
+	return res
+}
+
+// newUserResponse maps a user to its public shape
+func newUserResponse(user entity.User) userResponse {
+	return userResponse{
+		ID:            user.ID,
+		Login:         user.Login,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified(),
+		Role:          string(user.Role),
+		CreatedAt:     user.CreatedAt,
+	}
+}
+
+// newLockoutResponses maps account lockouts to their public shape
+func newLockoutResponses(lockouts []entity.Lockout) []lockoutResponse {
+	res := make([]lockoutResponse, len(lockouts))
+	for i, lockout := range lockouts {
+		res[i] = lockoutResponse{
+			ID:          lockout.ID,
+			IP:          lockout.IP,
+			Failures:    lockout.Failures,
+			LockedUntil: lockout.LockedUntil,
+			CreatedAt:   lockout.CreatedAt,
+		}
+	}
+	return res
+}
+
+// newIdentityResponse maps a linked identity to its public shape
+func newIdentityResponse(identity entity.Identity) identityResponse {
+	return identityResponse{
+		ID:        identity.ID,
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		CreatedAt: identity.CreatedAt,
+	}
+}
+
+// newIdentityResponses maps linked identities to their public shape
+func newIdentityResponses(identities []entity.Identity) []identityResponse {
+	res := make([]identityResponse, len(identities))
+	for i, identity := range identities {
+		res[i] = newIdentityResponse(identity)
+	}
+	return res
+}
+
+// newSessionResponses maps sessions to their public shape
+func newSessionResponses(sessions []entity.Session) []sessionResponse {
+	res := make([]sessionResponse, len(sessions))
+	for i, session := range sessions {
+		res[i] = sessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+		}
+	}
+	return res
+}
+
+// newJWKSResponse maps token verification keys to their public shape
+func newJWKSResponse(set auth.JWKSet) jwksResponse {
+	res := jwksResponse{Keys: make([]jwkResponse, 0, len(set.Keys))}
+	for _, key := range set.Keys {
+		res.Keys = append(res.Keys, jwkResponse(key))
+	}
+	return res
+}
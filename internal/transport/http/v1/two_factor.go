@@ -0,0 +1,284 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/internal/middleware"
+	"rest-api-marketplace/internal/service"
+)
+
+// signInTwoFactorInput represents the request payload for the second step of a sign-in
+type signInTwoFactorInput struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required,max=32"`
+}
+
+// twoFactorCodeInput represents the request payload with a code from the authenticator app or a recovery code
+type twoFactorCodeInput struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+// signInResponse represents the result of the password step of a sign-in, either tokens or a two-factor challenge
+type signInResponse struct {
+	AccessToken        string     `json:"access_token,omitempty"`
+	RefreshToken       string     `json:"refresh_token,omitempty"`
+	TwoFactorRequired  bool       `json:"two_factor_required"`
+	ChallengeToken     string     `json:"challenge_token,omitempty"`
+	ChallengeExpiresAt *time.Time `json:"challenge_expires_at,omitempty"`
+}
+
+// totpEnrollmentResponse represents a new authenticator app secret waiting for confirmation
+type totpEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// recoveryCodesResponse represents single-use recovery codes, they are only shown once
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// newSignInResponse maps the result of the password step of a sign-in to its public shape
+func newSignInResponse(res service.SignInResult) signInResponse {
+	if res.TwoFactorRequired() {
+		return signInResponse{
+			TwoFactorRequired:  true,
+			ChallengeToken:     res.ChallengeToken,
+			ChallengeExpiresAt: &res.ChallengeExpiresAt,
+		}
+	}
+	return signInResponse{
+		AccessToken:  res.Tokens.AccessToken,
+		RefreshToken: res.Tokens.RefreshToken,
+	}
+}
+
+// @Summary Two-Factor Sign In
+// @Description Finish the sign-in of a user with two-factor authentication with a code from the authenticator app or a recovery code. The challenge token works once and stops working after 5 wrong codes. Wrong codes count as failed sign-ins of the login and are throttled the same way
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param input body signInTwoFactorInput true "Challenge token from sign-in and code"
+// @Success 200 {object} tokenResponse
+// @Failure 400 {object} error "Invalid request body"
+// @Failure 401 {object} error "Invalid code or invalid, expired or used challenge"
+// @Failure 429 {object} error "Too many wrong codes, the Retry-After header tells how many seconds to wait"
+// @Failure 500 {object} error "Failed to sign in"
+// @Router /api/v1/users/sign-in/2fa [post]
+// userSignInTwoFactor handles POST /users/sign-in/2fa to finish a sign-in with a second factor
+func (h *Handler) userSignInTwoFactor(c echo.Context) error {
+	var input signInTwoFactorInput
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return err
+	}
+
+	tokens, err := h.services.Users.SignInTwoFactor(c.Request().Context(), input.ChallengeToken, input.Code, clientInfo(c))
+	if err != nil {
+		var throttled *entity.ThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			return tooManyAttempts(c, throttled)
+		case errors.Is(err, entity.ErrInvalidTwoFactorCode):
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid two-factor authentication code")
+		case errors.Is(err, entity.ErrInvalidChallenge):
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired two-factor challenge, sign in again")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to sign in")
+		}
+	}
+
+	return c.JSON(http.StatusOK, tokenResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	})
+}
+
+// @Summary Enroll TOTP
+// @Description Generate an authenticator app secret for the current user. Two-factor authentication is enabled once the secret is confirmed with a code, enrolling again before that replaces the secret
+// @Tags users
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Success 200 {object} totpEnrollmentResponse
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 409 {object} error "Two-factor authentication is already enabled"
+// @Failure 500 {object} error "Failed to enroll"
+// @Router /api/v1/users/me/2fa/totp [post]
+// enrollUserTOTP handles POST /users/me/2fa/totp to start two-factor authentication enrollment
+func (h *Handler) enrollUserTOTP(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	enrollment, err := h.services.Users.EnrollTOTP(c.Request().Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrTwoFactorEnabled):
+			return echo.NewHTTPError(http.StatusConflict, "two-factor authentication is already enabled")
+		case errors.Is(err, entity.ErrUserNotFound):
+			return echo.NewHTTPError(http.StatusUnauthorized, "user not found")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to enroll")
+		}
+	}
+
+	return c.JSON(http.StatusOK, totpEnrollmentResponse{
+		Secret:     enrollment.Secret,
+		OTPAuthURI: enrollment.URI,
+	})
+}
+
+// @Summary Confirm TOTP
+// @Description Enable two-factor authentication of the current user with a code from the authenticator app. The response holds recovery codes, they are only shown once
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param input body twoFactorCodeInput true "Code from the authenticator app"
+// @Success 200 {object} recoveryCodesResponse
+// @Failure 400 {object} error "Invalid request body or code"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 404 {object} error "No enrollment to confirm"
+// @Failure 409 {object} error "Two-factor authentication is already enabled"
+// @Failure 500 {object} error "Failed to confirm"
+// @Router /api/v1/users/me/2fa/totp/confirm [post]
+// confirmUserTOTP handles POST /users/me/2fa/totp/confirm to enable two-factor authentication
+func (h *Handler) confirmUserTOTP(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	var input twoFactorCodeInput
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return err
+	}
+
+	codes, err := h.services.Users.ConfirmTOTP(c.Request().Context(), userID, input.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrInvalidTwoFactorCode):
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid two-factor authentication code")
+		case errors.Is(err, entity.ErrTwoFactorNotEnabled):
+			return echo.NewHTTPError(http.StatusNotFound, "no two-factor enrollment to confirm")
+		case errors.Is(err, entity.ErrTwoFactorEnabled):
+			return echo.NewHTTPError(http.StatusConflict, "two-factor authentication is already enabled")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to confirm")
+		}
+	}
+
+	return c.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Disable TOTP
+// @Description Turn two-factor authentication of the current user off with a code from the authenticator app or a recovery code
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param input body twoFactorCodeInput true "Code from the authenticator app or a recovery code"
+// @Success 204 "No content"
+// @Failure 400 {object} error "Invalid request body or code"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 404 {object} error "Two-factor authentication is not enabled"
+// @Failure 429 {object} error "Too many wrong codes, the Retry-After header tells how many seconds to wait"
+// @Failure 500 {object} error "Failed to disable"
+// @Router /api/v1/users/me/2fa/totp [delete]
+// disableUserTOTP handles DELETE /users/me/2fa/totp to turn two-factor authentication off
+func (h *Handler) disableUserTOTP(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	var input twoFactorCodeInput
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return err
+	}
+
+	if err := h.services.Users.DisableTOTP(c.Request().Context(), userID, input.Code); err != nil {
+		var throttled *entity.ThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			return tooManyAttempts(c, throttled)
+		case errors.Is(err, entity.ErrInvalidTwoFactorCode):
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid two-factor authentication code")
+		case errors.Is(err, entity.ErrTwoFactorNotEnabled):
+			return echo.NewHTTPError(http.StatusNotFound, "two-factor authentication is not enabled")
+		case errors.Is(err, entity.ErrUserNotFound):
+			return echo.NewHTTPError(http.StatusUnauthorized, "user not found")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to disable")
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary Regenerate Recovery Codes
+// @Description Replace all recovery codes of the current user with new ones, confirmed with a code from the authenticator app or a recovery code
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param input body twoFactorCodeInput true "Code from the authenticator app or a recovery code"
+// @Success 200 {object} recoveryCodesResponse
+// @Failure 400 {object} error "Invalid request body or code"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 404 {object} error "Two-factor authentication is not enabled"
+// @Failure 429 {object} error "Too many wrong codes, the Retry-After header tells how many seconds to wait"
+// @Failure 500 {object} error "Failed to regenerate recovery codes"
+// @Router /api/v1/users/me/2fa/recovery-codes [post]
+// regenerateUserRecoveryCodes handles POST /users/me/2fa/recovery-codes to replace recovery codes
+func (h *Handler) regenerateUserRecoveryCodes(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	var input twoFactorCodeInput
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return err
+	}
+
+	codes, err := h.services.Users.RegenerateRecoveryCodes(c.Request().Context(), userID, input.Code)
+	if err != nil {
+		var throttled *entity.ThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			return tooManyAttempts(c, throttled)
+		case errors.Is(err, entity.ErrInvalidTwoFactorCode):
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid two-factor authentication code")
+		case errors.Is(err, entity.ErrTwoFactorNotEnabled):
+			return echo.NewHTTPError(http.StatusNotFound, "two-factor authentication is not enabled")
+		case errors.Is(err, entity.ErrUserNotFound):
+			return echo.NewHTTPError(http.StatusUnauthorized, "user not found")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to regenerate recovery codes")
+		}
+	}
+
+	return c.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
@@ -2,12 +2,16 @@ package v1
 
 import (
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 
 	"rest-api-marketplace/internal/entity"
+	"rest-api-marketplace/internal/middleware"
 	"rest-api-marketplace/internal/service"
+	"rest-api-marketplace/pkg/auth"
 )
 
 // initUsersRoutes registers user-related routes under /users
@@ -16,14 +20,75 @@ func (h *Handler) initUsersRoutes(api *echo.Group) {
 	{
 		users.POST("/sign-up", h.userSignUp)
 		users.POST("/sign-in", h.userSignIn)
+		users.POST("/sign-in/2fa", h.userSignInTwoFactor)
 		users.POST("/auth/refresh", h.userRefresh)
+		users.POST("/verify-email", h.verifyUserEmail)
+		users.POST("/password/forgot", h.forgotUserPassword)
+		users.POST("/password/reset", h.resetUserPassword)
+		users.GET("/oauth/providers", h.listOAuthProviders)
+		users.GET("/oauth/:provider/start", h.startOAuth)
+		users.GET("/oauth/:provider/callback", h.oauthCallback)
+
+		authMiddleware := middleware.JWTAuth(h.tokenManager, h.revocations)
+		users.POST("/sign-out", h.userSignOut, authMiddleware)
+		users.POST("/sign-out-all", h.userSignOutAll, authMiddleware)
+
+		me := users.Group("/me", authMiddleware)
+		me.GET("/sessions", h.listUserSessions)
+		me.DELETE("/sessions/:id", h.revokeUserSession)
+		me.GET("/lockouts", h.listUserLockouts)
+		me.GET("/identities", h.listUserIdentities)
+		me.POST("/identities/:provider", h.linkUserIdentity)
+		me.DELETE("/identities/:id", h.unlinkUserIdentity)
+		me.POST("/email", h.setUserEmail)
+		me.PUT("/password", h.changeUserPassword)
+		me.POST("/2fa/totp", h.enrollUserTOTP)
+		me.POST("/2fa/totp/confirm", h.confirmUserTOTP)
+		me.DELETE("/2fa/totp", h.disableUserTOTP)
+		me.POST("/2fa/recovery-codes", h.regenerateUserRecoveryCodes)
+
+		users.PUT("/:id/role", h.setUserRole, authMiddleware, middleware.RequireRole(entity.RoleAdmin))
 	}
 }
 
-// userInput represents the request payload for sign-up and sign-in
+// userInput represents the request payload for sign-in
 type userInput struct {
 	Login    string `json:"login" validate:"required,min=3,max=64"`
-	Password string `json:"password" validate:"required,min=8,max=64"`
+	Password string `json:"password" validate:"required,max=128"`
+}
+
+// signUpInput represents the request payload for sign-up
+type signUpInput struct {
+	Login    string `json:"login" validate:"required,min=3,max=64"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,max=128"`
+}
+
+// emailInput represents the request payload for changing the email
+type emailInput struct {
+	Email string `json:"email" validate:"required,email,max=254"`
+}
+
+// verifyEmailInput represents the request payload for confirming an email
+type verifyEmailInput struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// forgotPasswordInput represents the request payload for asking a password reset link
+type forgotPasswordInput struct {
+	Email string `json:"email" validate:"required,email,max=254"`
+}
+
+// resetPasswordInput represents the request payload for setting a new password with a reset token
+type resetPasswordInput struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,max=128"`
+}
+
+// changePasswordInput represents the request payload for changing the password of the current user
+type changePasswordInput struct {
+	OldPassword string `json:"old_password" validate:"required,max=128"`
+	NewPassword string `json:"new_password" validate:"required,max=128"`
 }
 
 // tokenResponse represents JWT access and refresh tokens returned to the client
@@ -32,25 +97,30 @@ type tokenResponse struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// roleInput represents the request payload for changing a user role
+type roleInput struct {
+	Role string `json:"role" validate:"required,oneof=user moderator admin"`
+}
+
 // refreshInput represents the request payload for refreshing tokens
 type refreshInput struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
 // @Summary User Sign Up
-// @Description Register a new user
+// @Description Register a new user, a verification link is sent to the email. The password must follow the password policy, the error tells which rule it breaks. The response is the same when the email already belongs to a user, the owner of the email is told about the attempt by email instead
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param user body userInput true "User credentials for registration"
-// @Success 201 {object} entity.User
-// @Failure 400 {object} error "Invalid request body"
+// @Param user body signUpInput true "User credentials for registration"
+// @Success 202 "Accepted"
+// @Failure 400 {object} error "Invalid request body or password rejected by the password policy"
 // @Failure 409 {object} error "User with this login already exists"
 // @Failure 500 {object} error "Failed to create user"
 // @Router /api/v1/users/sign-up [post]
 // userSignUp handles user registration
 func (h *Handler) userSignUp(c echo.Context) error {
-	var input userInput
+	var input signUpInput
 	if err := c.Bind(&input); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
 	}
@@ -59,13 +129,17 @@ func (h *Handler) userSignUp(c echo.Context) error {
 		return err
 	}
 
-	user, err := h.services.Users.SignUp(c.Request().Context(), service.UserInput{
+	err := h.services.Users.SignUp(c.Request().Context(), service.UserInput{
 		Login:    input.Login,
+		Email:    input.Email,
 		Password: input.Password,
 	})
 
 	if err != nil {
+		var policyErr *entity.PasswordPolicyError
 		switch {
+		case errors.As(err, &policyErr):
+			return echo.NewHTTPError(http.StatusBadRequest, policyErr.Reason)
 		case errors.Is(err, entity.ErrUserExists):
 			return echo.NewHTTPError(http.StatusConflict, "user with this login already exists")
 		case errors.Is(err, entity.ErrInvalidInput):
@@ -75,18 +149,19 @@ func (h *Handler) userSignUp(c echo.Context) error {
 		}
 	}
 
-	return c.JSON(http.StatusCreated, user)
+	return c.NoContent(http.StatusAccepted)
 }
 
 // @Summary User Sign In
-// @Description User sign-in
+// @Description User sign-in. Users with two-factor authentication get a challenge token instead of tokens, the sign-in is finished with POST /users/sign-in/2fa. Failed attempts slow down further ones for the login and the client IP and eventually lock them out for a while
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param user body userInput true "User credentials for login"
-// @Success 200 {object} tokenResponse
+// @Success 200 {object} signInResponse
 // @Failure 400 {object} error "Invalid request body"
 // @Failure 401 {object} error "Invalid login or password"
+// @Failure 429 {object} error "Too many failed attempts, the Retry-After header tells how many seconds to wait"
 // @Failure 500 {object} error "Failed to sign in"
 // @Router /api/v1/users/sign-in [post]
 // userSignIn handles user login and returns JWT tokens
@@ -100,13 +175,16 @@ func (h *Handler) userSignIn(c echo.Context) error {
 		return err
 	}
 
-	tokens, err := h.services.Users.SignIn(c.Request().Context(), service.UserInput{
+	res, err := h.services.Users.SignIn(c.Request().Context(), service.UserInput{
 		Login:    input.Login,
 		Password: input.Password,
-	})
+	}, clientInfo(c))
 
 	if err != nil {
+		var throttled *entity.ThrottledError
 		switch {
+		case errors.As(err, &throttled):
+			return tooManyAttempts(c, throttled)
 		case errors.Is(err, entity.ErrInvalidCreds):
 			return echo.NewHTTPError(http.StatusUnauthorized, "invalid login or password")
 		default:
@@ -114,21 +192,18 @@ func (h *Handler) userSignIn(c echo.Context) error {
 		}
 	}
 
-	return c.JSON(http.StatusOK, tokenResponse{
-		AccessToken:  tokens.AccessToken,
-		RefreshToken: tokens.RefreshToken,
-	})
+	return c.JSON(http.StatusOK, newSignInResponse(res))
 }
 
 // @Summary Refresh Tokens
-// @Description Refresh JWT access and refresh tokens
+// @Description Exchange a refresh token for a new token pair, the old refresh token stops working. Reusing it revokes the session
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param user body refreshInput true "Refresh token"
 // @Success 200 {object} tokenResponse
 // @Failure 400 {object} error "Invalid request body"
-// @Failure 401 {object} error "Invalid or expired refresh token"
+// @Failure 401 {object} error "Invalid, expired or reused refresh token"
 // @Failure 500 {object} error "Internal server error""
 // @Router /api/v1/users/auth/refresh [post]
 // userRefresh handles refreshing JWT tokens using a valid refresh token
@@ -142,11 +217,13 @@ func (h *Handler) userRefresh(c echo.Context) error {
 		return err
 	}
 
-	tokens, err := h.services.Users.RefreshTokens(c.Request().Context(), input.RefreshToken)
+	tokens, err := h.services.Users.RefreshTokens(c.Request().Context(), input.RefreshToken, clientInfo(c))
 	if err != nil {
 		switch {
-		case errors.Is(err, entity.ErrUserNotFound) || errors.Is(err, entity.ErrInvalidInput):
+		case errors.Is(err, entity.ErrSessionNotFound) || errors.Is(err, entity.ErrInvalidInput):
 			return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired refresh token")
+		case errors.Is(err, entity.ErrRefreshTokenReused):
+			return echo.NewHTTPError(http.StatusUnauthorized, "refresh token was already used, the session is revoked")
 		default:
 			return echo.NewHTTPError(http.StatusInternalServerError, "internal server error")
 		}
@@ -157,3 +234,385 @@ func (h *Handler) userRefresh(c echo.Context) error {
 		RefreshToken: tokens.RefreshToken,
 	})
 }
+
+// @Summary User Sign Out
+// @Description Sign out on the current device, the access token and the refresh token of its session stop working
+// @Tags users
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Success 204 "No content"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 500 {object} error "Failed to sign out"
+// @Router /api/v1/users/sign-out [post]
+// userSignOut handles POST /users/sign-out to end the current session
+func (h *Handler) userSignOut(c echo.Context) error {
+	claims, ok := c.Get(middleware.CtxTokenClaims).(*auth.TokenClaims)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	if err := h.services.Users.SignOut(c.Request().Context(), claims); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to sign out")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary User Sign Out Everywhere
+// @Description Sign out on all devices, every access and refresh token issued to the user so far stops working
+// @Tags users
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Success 204 "No content"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 500 {object} error "Failed to sign out"
+// @Router /api/v1/users/sign-out-all [post]
+// userSignOutAll handles POST /users/sign-out-all to end all sessions of the current user
+func (h *Handler) userSignOutAll(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	if err := h.services.Users.SignOutAll(c.Request().Context(), userID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to sign out")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary Verify Email
+// @Description Confirm the email of a user with the token from the verification email
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param token body verifyEmailInput true "Verification token"
+// @Success 204 "No content"
+// @Failure 400 {object} error "Invalid, expired or outdated token"
+// @Failure 500 {object} error "Failed to verify email"
+// @Router /api/v1/users/verify-email [post]
+// verifyUserEmail handles POST /users/verify-email to confirm an email
+func (h *Handler) verifyUserEmail(c echo.Context) error {
+	var input verifyEmailInput
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return err
+	}
+
+	if err := h.services.Users.VerifyEmail(c.Request().Context(), input.Token); err != nil {
+		if errors.Is(err, entity.ErrInvalidVerificationToken) {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid or expired verification token")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to verify email")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary Set User Email
+// @Description Change the email of the current user and send a verification link to it. Sending the current unverified email again resends the link
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param email body emailInput true "New email"
+// @Success 202 "Accepted"
+// @Failure 400 {object} error "Invalid request body or email"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 409 {object} error "Email is already used"
+// @Failure 500 {object} error "Failed to set email"
+// @Router /api/v1/users/me/email [post]
+// setUserEmail handles POST /users/me/email to change the email of the current user
+func (h *Handler) setUserEmail(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	var input emailInput
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return err
+	}
+
+	if err := h.services.Users.SetEmail(c.Request().Context(), userID, input.Email); err != nil {
+		switch {
+		case errors.Is(err, entity.ErrEmailTaken):
+			return echo.NewHTTPError(http.StatusConflict, "email is already used by another user")
+		case errors.Is(err, entity.ErrInvalidInput):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to set email")
+		}
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// @Summary Forgot Password
+// @Description Send a single-use password reset link to the email if it is a verified email of a user. The response is the same for unknown emails
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param email body forgotPasswordInput true "Email of the account"
+// @Success 202 "Accepted"
+// @Failure 400 {object} error "Invalid request body or email"
+// @Failure 500 {object} error "Failed to send password reset link"
+// @Router /api/v1/users/password/forgot [post]
+// forgotUserPassword handles POST /users/password/forgot to send a password reset link
+func (h *Handler) forgotUserPassword(c echo.Context) error {
+	var input forgotPasswordInput
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return err
+	}
+
+	if err := h.services.Users.ForgotPassword(c.Request().Context(), input.Email); err != nil {
+		if errors.Is(err, entity.ErrInvalidInput) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to send password reset link")
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// @Summary Reset Password
+// @Description Set a new password with a token from a password reset link. The password must follow the password policy, the token works once and all sessions of the user are ended
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param input body resetPasswordInput true "Reset token and new password"
+// @Success 204 "No content"
+// @Failure 400 {object} error "Invalid request body or token, or password rejected by the password policy"
+// @Failure 500 {object} error "Failed to reset password"
+// @Router /api/v1/users/password/reset [post]
+// resetUserPassword handles POST /users/password/reset to set a new password with a reset token
+func (h *Handler) resetUserPassword(c echo.Context) error {
+	var input resetPasswordInput
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return err
+	}
+
+	if err := h.services.Users.ResetPassword(c.Request().Context(), input.Token, input.Password); err != nil {
+		var policyErr *entity.PasswordPolicyError
+		switch {
+		case errors.Is(err, entity.ErrInvalidResetToken):
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid or expired password reset token")
+		case errors.As(err, &policyErr):
+			return echo.NewHTTPError(http.StatusBadRequest, policyErr.Reason)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to reset password")
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary Change Password
+// @Description Change the password of the current user. The new password must follow the password policy. All sessions of the user are ended, including the current one, so the user has to sign in again
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param input body changePasswordInput true "Current and new password"
+// @Success 204 "No content"
+// @Failure 400 {object} error "Invalid request body or new password rejected by the password policy"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 403 {object} error "Current password is wrong"
+// @Failure 500 {object} error "Failed to change password"
+// @Router /api/v1/users/me/password [put]
+// changeUserPassword handles PUT /users/me/password to change the password of the current user
+func (h *Handler) changeUserPassword(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	var input changePasswordInput
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return err
+	}
+
+	if err := h.services.Users.ChangePassword(c.Request().Context(), userID, input.OldPassword, input.NewPassword); err != nil {
+		var policyErr *entity.PasswordPolicyError
+		switch {
+		case errors.Is(err, entity.ErrInvalidCreds):
+			return echo.NewHTTPError(http.StatusForbidden, "current password is wrong")
+		case errors.Is(err, entity.ErrUserNotFound):
+			return echo.NewHTTPError(http.StatusUnauthorized, "user not found")
+		case errors.As(err, &policyErr):
+			return echo.NewHTTPError(http.StatusBadRequest, policyErr.Reason)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to change password")
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary List User Sessions
+// @Description List devices the current user is signed in on
+// @Tags users
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Success 200 {array} sessionResponse
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 500 {object} error "Failed to get sessions"
+// @Router /api/v1/users/me/sessions [get]
+// listUserSessions handles GET /users/me/sessions to list active sessions of the current user
+func (h *Handler) listUserSessions(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	sessions, err := h.services.Users.GetSessions(c.Request().Context(), userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get sessions")
+	}
+
+	return c.JSON(http.StatusOK, newSessionResponses(sessions))
+}
+
+// @Summary Revoke User Session
+// @Description Sign the current user out on another device, its refresh token stops working
+// @Tags users
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param id path int64 true "Session ID"
+// @Success 204 "No content"
+// @Failure 400 {object} error "Invalid session ID"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 404 {object} error "Session not found"
+// @Failure 500 {object} error "Failed to revoke session"
+// @Router /api/v1/users/me/sessions/{id} [delete]
+// revokeUserSession handles DELETE /users/me/sessions/:id to revoke a session of the current user
+func (h *Handler) revokeUserSession(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	sessionID, err := h.parseIDFromPath(c, "id")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.services.Users.RevokeSession(c.Request().Context(), userID, sessionID); err != nil {
+		if errors.Is(err, entity.ErrSessionNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "session not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke session")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary List User Lockouts
+// @Description List the latest lockouts of the current user account after too many failed sign-in attempts
+// @Tags users
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Success 200 {array} lockoutResponse
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 500 {object} error "Failed to get lockouts"
+// @Router /api/v1/users/me/lockouts [get]
+// listUserLockouts handles GET /users/me/lockouts to list lockouts of the current user account
+func (h *Handler) listUserLockouts(c echo.Context) error {
+	userID, ok := c.Get(middleware.CtxUserID).(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	lockouts, err := h.services.Users.GetLockouts(c.Request().Context(), userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get lockouts")
+	}
+
+	return c.JSON(http.StatusOK, newLockoutResponses(lockouts))
+}
+
+// @Summary Set User Role
+// @Description Make a user a moderator or an admin or take the role away, admins only. The change is recorded in the audit log and takes effect on the next token refresh of the user
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param id path int64 true "User ID"
+// @Param role body roleInput true "New role"
+// @Success 200 {object} userResponse
+// @Failure 400 {object} error "Invalid request body or user ID"
+// @Failure 401 {object} error "Unauthorized"
+// @Failure 403 {object} error "Forbidden"
+// @Failure 404 {object} error "User not found"
+// @Failure 500 {object} error "Failed to set role"
+// @Router /api/v1/users/{id}/role [put]
+// setUserRole handles PUT /users/:id/role to change the role of a user
+func (h *Handler) setUserRole(c echo.Context) error {
+	actor, ok := h.actor(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid user context")
+	}
+
+	userID, err := h.parseIDFromPath(c, "id")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var input roleInput
+	if err := c.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return err
+	}
+
+	user, err := h.services.Users.SetRole(c.Request().Context(), actor, userID, entity.Role(input.Role))
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrForbidden):
+			return echo.NewHTTPError(http.StatusForbidden, "you can't change the role of this user")
+		case errors.Is(err, entity.ErrUserNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "user not found")
+		case errors.Is(err, entity.ErrInvalidInput):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to set role")
+		}
+	}
+
+	return c.JSON(http.StatusOK, newUserResponse(*user))
+}
+
+// tooManyAttempts answers a throttled attempt with 429 and a Retry-After header in whole seconds
+func tooManyAttempts(c echo.Context, throttled *entity.ThrottledError) error {
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	return echo.NewHTTPError(http.StatusTooManyRequests, "too many failed sign-in attempts, try again later")
+}
+
+// clientInfo extracts the device description of a session from the request
+func clientInfo(c echo.Context) service.ClientInfo {
+	return service.ClientInfo{
+		UserAgent: c.Request().UserAgent(),
+		IP:        c.RealIP(),
+	}
+}
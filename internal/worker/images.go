@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"rest-api-marketplace/internal/service"
+)
+
+// ImagePool generates ad image variants in the background with a fixed number of workers.
+// Images whose jobs were dropped or failed are picked up again by a periodic sweep
+type ImagePool struct {
+	jobs          chan int64
+	logger        *slog.Logger
+	workers       int
+	sweepInterval time.Duration
+	done          chan struct{}
+}
+
+// NewImagePool creates a new ImagePool instance
+func NewImagePool(logger *slog.Logger, workers, queueSize int, sweepInterval time.Duration) *ImagePool {
+	return &ImagePool{
+		jobs:          make(chan int64, queueSize),
+		logger:        logger,
+		workers:       workers,
+		sweepInterval: sweepInterval,
+		done:          make(chan struct{}),
+	}
+}
+
+// Enqueue schedules an image for processing without blocking, the job is dropped if the queue is full
+func (p *ImagePool) Enqueue(imageID int64) {
+	select {
+	case p.jobs <- imageID:
+	default:
+		p.logger.Warn("image queue is full, job postponed until next sweep", slog.Int64("image_id", imageID))
+	}
+}
+
+// Run starts the workers and the sweeper, it blocks until the context is canceled and all workers finish their current job
+func (p *ImagePool) Run(ctx context.Context, ads service.Ads) {
+	const op = "worker.ImagePool.Run"
+	defer close(p.done)
+
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case imageID := <-p.jobs:
+					// a started job is finished on shutdown, queued ones are left for the sweep after restart
+					if err := ads.GenerateImageVariants(context.WithoutCancel(ctx), imageID); err != nil {
+						p.logger.Error("failed to process image", slog.String("op", op), slog.Int64("image_id", imageID), slog.String("error", err.Error()))
+					}
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(p.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		p.sweep(ctx, ads)
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Wait blocks until Run returns, so images being processed are finished before the application exits
+func (p *ImagePool) Wait() {
+	<-p.done
+}
+
+// sweep enqueues images still waiting for variants when the queue is idle
+func (p *ImagePool) sweep(ctx context.Context, ads service.Ads) {
+	if len(p.jobs) > 0 {
+		return
+	}
+
+	ids, err := ads.PendingImages(ctx, cap(p.jobs))
+	if err != nil {
+		return
+	}
+
+	for _, id := range ids {
+		p.Enqueue(id)
+	}
+}
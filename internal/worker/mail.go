@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"rest-api-marketplace/pkg/mailer"
+)
+
+// errMailQueueFull is returned by MailQueue.Send when no more emails can be queued
+var errMailQueueFull = errors.New("mail queue is full")
+
+// MailQueue implements mailer.Mailer by sending emails in the background with a fixed number of workers,
+// so requests don't wait for the mail server. Every email has a bounded time to be sent, failures are logged
+type MailQueue struct {
+	mailer  mailer.Mailer
+	msgs    chan mailer.Message
+	logger  *slog.Logger
+	workers int
+	timeout time.Duration
+	done    chan struct{}
+}
+
+// NewMailQueue creates a new MailQueue sending through m
+func NewMailQueue(m mailer.Mailer, logger *slog.Logger, workers, queueSize int, timeout time.Duration) *MailQueue {
+	return &MailQueue{
+		mailer:  m,
+		msgs:    make(chan mailer.Message, queueSize),
+		logger:  logger,
+		workers: workers,
+		timeout: timeout,
+		done:    make(chan struct{}),
+	}
+}
+
+// Send queues the email without blocking, it fails if the queue is full
+func (q *MailQueue) Send(_ context.Context, msg mailer.Message) error {
+	select {
+	case q.msgs <- msg:
+		return nil
+	default:
+		return errMailQueueFull
+	}
+}
+
+// Run starts the workers, it blocks until the context is canceled and the emails queued by then are sent
+func (q *MailQueue) Run(ctx context.Context) {
+	defer close(q.done)
+
+	var wg sync.WaitGroup
+	for i := 0; i < q.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					q.drain()
+					return
+				case msg := <-q.msgs:
+					q.send(msg)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Wait blocks until Run returns, so queued emails are sent before the application exits
+func (q *MailQueue) Wait() {
+	<-q.done
+}
+
+// drain sends the emails left in the queue
+func (q *MailQueue) drain() {
+	for {
+		select {
+		case msg := <-q.msgs:
+			q.send(msg)
+		default:
+			return
+		}
+	}
+}
+
+// send delivers one email within the timeout
+func (q *MailQueue) send(msg mailer.Message) {
+	const op = "worker.MailQueue.send"
+
+	ctx, cancel := context.WithTimeout(context.Background(), q.timeout)
+	defer cancel()
+
+	if err := q.mailer.Send(ctx, msg); err != nil {
+		q.logger.Error("failed to send email", slog.String("op", op), slog.String("subject", msg.Subject), slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,49 @@
+// Package worker provides background jobs running alongside the HTTP server
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"rest-api-marketplace/internal/service"
+)
+
+// AdsPurger periodically removes soft-deleted ads whose retention period has passed
+type AdsPurger struct {
+	ads       service.Ads
+	logger    *slog.Logger
+	interval  time.Duration
+	retention time.Duration
+}
+
+// NewAdsPurger creates a new AdsPurger instance
+func NewAdsPurger(ads service.Ads, logger *slog.Logger, interval, retention time.Duration) *AdsPurger {
+	return &AdsPurger{
+		ads:       ads,
+		logger:    logger,
+		interval:  interval,
+		retention: retention,
+	}
+}
+
+// Run purges deleted ads on every tick until the context is canceled
+func (p *AdsPurger) Run(ctx context.Context) {
+	const op = "worker.AdsPurger.Run"
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := p.ads.PurgeDeleted(ctx, p.retention)
+		if err == nil && purged > 0 {
+			p.logger.Info("purged deleted ads", slog.String("op", op), slog.Int64("count", purged))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS categories (
+    id              BIGSERIAL PRIMARY KEY,
+    parent_id       BIGINT,
+    name            VARCHAR(100) NOT NULL,
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(parent_id) REFERENCES categories (id) ON DELETE RESTRICT
+);
+
+ALTER TABLE ads ADD COLUMN IF NOT EXISTS category_id BIGINT REFERENCES categories (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id);
+CREATE INDEX IF NOT EXISTS idx_ads_category_id ON ads(category_id);
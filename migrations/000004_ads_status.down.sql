@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_ads_status;
+
+ALTER TABLE ads DROP COLUMN IF EXISTS status;
@@ -0,0 +1,4 @@
+ALTER TABLE ads ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'published', 'reserved', 'sold', 'archived'));
+
+CREATE INDEX IF NOT EXISTS idx_ads_status ON ads(status);
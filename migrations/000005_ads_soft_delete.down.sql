@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_ads_deleted_at;
+
+DELETE FROM ads WHERE deleted_at IS NOT NULL;
+ALTER TABLE ads DROP COLUMN IF EXISTS deleted_at;
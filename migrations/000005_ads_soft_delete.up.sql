@@ -0,0 +1,3 @@
+ALTER TABLE ads ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_ads_deleted_at ON ads(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_ad_images_ad_id;
+
+DROP TABLE IF EXISTS ad_images;
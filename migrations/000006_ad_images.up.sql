@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS ad_images (
+    id              BIGSERIAL PRIMARY KEY,
+    ad_id           BIGINT NOT NULL,
+    storage_key     VARCHAR(255) NOT NULL UNIQUE,
+    content_type    VARCHAR(50) NOT NULL,
+    size            BIGINT NOT NULL,
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(ad_id) REFERENCES ads (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_ad_images_ad_id ON ad_images(ad_id);
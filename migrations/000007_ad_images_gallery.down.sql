@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_ad_images_cover;
+DROP INDEX IF EXISTS idx_ad_images_ad_id_position;
+CREATE INDEX IF NOT EXISTS idx_ad_images_ad_id ON ad_images(ad_id);
+
+ALTER TABLE ad_images DROP COLUMN IF EXISTS is_cover;
+ALTER TABLE ad_images DROP COLUMN IF EXISTS position;
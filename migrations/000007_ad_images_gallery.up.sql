@@ -0,0 +1,14 @@
+ALTER TABLE ad_images ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0;
+ALTER TABLE ad_images ADD COLUMN IF NOT EXISTS is_cover BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE ad_images i
+SET position = o.position, is_cover = (o.position = 0)
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY ad_id ORDER BY id) - 1 AS position
+    FROM ad_images
+) o
+WHERE i.id = o.id;
+
+DROP INDEX IF EXISTS idx_ad_images_ad_id;
+CREATE INDEX IF NOT EXISTS idx_ad_images_ad_id_position ON ad_images(ad_id, position);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ad_images_cover ON ad_images(ad_id) WHERE is_cover;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_ad_images_variants_pending;
+
+ALTER TABLE ad_images DROP COLUMN IF EXISTS variants_failed_at;
+ALTER TABLE ad_images DROP COLUMN IF EXISTS variants_generated_at;
+
+DROP TABLE IF EXISTS ad_image_variants;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS ad_image_variants (
+    id              BIGSERIAL PRIMARY KEY,
+    image_id        BIGINT NOT NULL,
+    size            INT NOT NULL,
+    width           INT NOT NULL,
+    height          INT NOT NULL,
+    storage_key     VARCHAR(255) NOT NULL UNIQUE,
+    FOREIGN KEY(image_id) REFERENCES ad_images (id) ON DELETE CASCADE,
+    UNIQUE(image_id, size)
+);
+
+ALTER TABLE ad_images ADD COLUMN IF NOT EXISTS variants_generated_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE ad_images ADD COLUMN IF NOT EXISTS variants_failed_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_ad_images_variants_pending ON ad_images(id) WHERE variants_generated_at IS NULL AND variants_failed_at IS NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE ads DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE ads ALTER COLUMN price TYPE DECIMAL(10,2) USING price / 100.0;
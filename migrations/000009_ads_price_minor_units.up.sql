@@ -0,0 +1,5 @@
+ALTER TABLE ads ALTER COLUMN price TYPE BIGINT USING (price * 100)::BIGINT;
+
+ALTER TABLE ads ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE ads ALTER COLUMN currency DROP DEFAULT;
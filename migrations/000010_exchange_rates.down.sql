@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_ads_currency;
+
+DROP TABLE IF EXISTS exchange_rates;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    currency        CHAR(3) PRIMARY KEY,
+    rate            NUMERIC(24,12) NOT NULL CHECK (rate > 0),
+    minor_units     SMALLINT NOT NULL,
+    updated_at      TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO exchange_rates (currency, rate, minor_units) VALUES ('USD', 1, 2) ON CONFLICT (currency) DO NOTHING;
+
+CREATE INDEX IF NOT EXISTS idx_ads_currency ON ads(currency);
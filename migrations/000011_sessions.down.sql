@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS refresh_token VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS refresh_expires_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE users u
+SET refresh_token = s.refresh_token, refresh_expires_at = s.expires_at
+FROM (
+    SELECT DISTINCT ON (user_id) user_id, refresh_token, expires_at
+    FROM sessions
+    ORDER BY user_id, last_used_at DESC
+) s
+WHERE s.user_id = u.id;
+
+DROP INDEX IF EXISTS idx_sessions_user_id;
+
+DROP TABLE IF EXISTS sessions;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS sessions (
+    id              BIGSERIAL PRIMARY KEY,
+    user_id         BIGINT NOT NULL,
+    refresh_token   VARCHAR(255) NOT NULL UNIQUE,
+    user_agent      VARCHAR(512) NOT NULL DEFAULT '',
+    ip              VARCHAR(45) NOT NULL DEFAULT '',
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_used_at    TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+INSERT INTO sessions (user_id, refresh_token, expires_at, created_at, last_used_at)
+SELECT id, refresh_token, refresh_expires_at, COALESCE(last_visit_at, CURRENT_TIMESTAMP), COALESCE(last_visit_at, CURRENT_TIMESTAMP)
+FROM users
+WHERE refresh_token IS NOT NULL AND refresh_expires_at > CURRENT_TIMESTAMP;
+
+ALTER TABLE users DROP COLUMN IF EXISTS refresh_token;
+ALTER TABLE users DROP COLUMN IF EXISTS refresh_expires_at;
@@ -0,0 +1,8 @@
+-- plaintext refresh tokens can't be recovered from hashes, so all sessions are signed out
+DELETE FROM sessions;
+
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS refresh_token VARCHAR(255) NOT NULL UNIQUE;
+
+DROP INDEX IF EXISTS idx_refresh_tokens_session_id;
+
+DROP TABLE IF EXISTS refresh_tokens;
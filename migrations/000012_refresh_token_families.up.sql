@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id              BIGSERIAL PRIMARY KEY,
+    session_id      BIGINT NOT NULL,
+    token_hash      CHAR(64) NOT NULL UNIQUE,
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    rotated_at      TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY(session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON refresh_tokens(session_id);
+
+INSERT INTO refresh_tokens (session_id, token_hash, created_at)
+SELECT id, encode(sha256(convert_to(refresh_token, 'UTF8')), 'hex'), last_used_at
+FROM sessions;
+
+ALTER TABLE sessions DROP COLUMN IF EXISTS refresh_token;
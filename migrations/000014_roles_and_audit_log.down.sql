@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS audit_log;
+
+UPDATE ads SET status = 'archived' WHERE status = 'hidden';
+ALTER TABLE ads DROP CONSTRAINT IF EXISTS ads_status_check;
+ALTER TABLE ads ADD CONSTRAINT ads_status_check
+    CHECK (status IN ('draft', 'published', 'reserved', 'sold', 'archived'));
+
+ALTER TABLE users DROP COLUMN IF EXISTS role;
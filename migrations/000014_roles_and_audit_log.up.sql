@@ -0,0 +1,21 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator', 'admin'));
+
+ALTER TABLE ads DROP CONSTRAINT IF EXISTS ads_status_check;
+ALTER TABLE ads ADD CONSTRAINT ads_status_check
+    CHECK (status IN ('draft', 'published', 'reserved', 'sold', 'archived', 'hidden'));
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id              BIGSERIAL PRIMARY KEY,
+    actor_id        BIGINT,
+    actor_role      VARCHAR(16) NOT NULL,
+    action          VARCHAR(64) NOT NULL,
+    target_type     VARCHAR(32) NOT NULL,
+    target_id       BIGINT NOT NULL,
+    details         JSONB,
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(actor_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log(actor_id);
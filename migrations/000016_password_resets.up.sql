@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS password_resets (
+    token_hash      CHAR(64) PRIMARY KEY,
+    user_id         BIGINT NOT NULL,
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_user_id ON password_resets(user_id);
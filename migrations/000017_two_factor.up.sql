@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id         BIGINT PRIMARY KEY,
+    secret          VARCHAR(64) NOT NULL,
+    last_step       BIGINT NOT NULL DEFAULT 0,
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    confirmed_at    TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY(user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id              BIGSERIAL PRIMARY KEY,
+    user_id         BIGINT NOT NULL,
+    code_hash       CHAR(64) NOT NULL,
+    used_at         TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY(user_id) REFERENCES users (id) ON DELETE CASCADE,
+    UNIQUE (user_id, code_hash)
+);
+
+CREATE TABLE IF NOT EXISTS two_factor_challenges (
+    token_hash      CHAR(64) PRIMARY KEY,
+    user_id         BIGINT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_two_factor_challenges_user_id ON two_factor_challenges(user_id);
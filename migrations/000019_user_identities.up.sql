@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+    id              BIGSERIAL PRIMARY KEY,
+    user_id         BIGINT NOT NULL,
+    provider        VARCHAR(50) NOT NULL,
+    subject         VARCHAR(255) NOT NULL,
+    email           VARCHAR(254),
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT user_identities_provider_subject_key UNIQUE (provider, subject),
+    CONSTRAINT user_identities_user_id_provider_key UNIQUE (user_id, provider)
+);
+
+CREATE TABLE IF NOT EXISTS oauth_states (
+    state_hash      CHAR(64) PRIMARY KEY,
+    provider        VARCHAR(50) NOT NULL,
+    code_verifier   VARCHAR(128) NOT NULL,
+    nonce           VARCHAR(64) NOT NULL,
+    user_id         BIGINT,
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_states_expires_at ON oauth_states(expires_at);
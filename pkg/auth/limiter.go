@@ -0,0 +1,337 @@
+package auth
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Attempts is the failed sign-in history of a key, a login or a client IP
+type Attempts struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// AttemptStore keeps failed sign-in attempts, shared between instances when it is backed by a database
+type AttemptStore interface {
+	// Update changes the attempts of the key atomically and returns the new ones, concurrent updates
+	// of the key wait for each other. fn gets the current attempts, zero Attempts if there are none,
+	// with failures older than window before now forgotten. Zero Attempts returned by fn delete the key
+	Update(ctx context.Context, key string, now time.Time, window time.Duration, fn func(Attempts) Attempts) (Attempts, error)
+	// Reset forgets the attempts of the key
+	Reset(ctx context.Context, key string) error
+}
+
+// LimitPolicy describes how failed attempts of a key are slowed down and locked out
+type LimitPolicy struct {
+	// FreeAttempts is how many failures are allowed without waiting
+	FreeAttempts int
+	// BaseDelay is the wait after the first failure beyond the free ones, it doubles with every next failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxFailures locks the key out for LockoutDuration once reached, zero disables lockouts
+	MaxFailures     int
+	LockoutDuration time.Duration
+	// Window is how long failures are remembered after the last one
+	Window time.Duration
+}
+
+// delay returns how long to wait after the last of the failures
+func (p LimitPolicy) delay(failures int) time.Duration {
+	over := failures - p.FreeAttempts
+	if over <= 0 || p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < over && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// wait returns how long attempts have to wait after the failures so far, zero if the next one can go now
+func (p LimitPolicy) wait(attempts Attempts, now time.Time) time.Duration {
+	wait := attempts.LockedUntil.Sub(now)
+	if attempts.Failures > 0 {
+		wait = max(wait, attempts.LastFailure.Add(p.delay(attempts.Failures)).Sub(now))
+	}
+	return max(wait, 0)
+}
+
+// Failure describes a failed sign-in attempt after it was counted
+type Failure struct {
+	LoginFailures int
+	// LoginLocked and IPLocked are set when this attempt locked the key out until LockedUntil
+	LoginLocked bool
+	IPLocked    bool
+	LockedUntil time.Time
+}
+
+// LoginLimiter slows down password guessing with exponential backoff and temporary lockouts,
+// tracking failed sign-in attempts per login and per client IP
+type LoginLimiter struct {
+	store AttemptStore
+	login LimitPolicy
+	ip    LimitPolicy
+	now   func() time.Time
+}
+
+// NewLoginLimiter creates a LoginLimiter with separate policies for logins and client IPs
+func NewLoginLimiter(store AttemptStore, login, ip LimitPolicy) *LoginLimiter {
+	return &LoginLimiter{store: store, login: login, ip: ip, now: time.Now}
+}
+
+// Attempt is a sign-in attempt counted as failed by Reserve before the credentials are checked,
+// so concurrent guesses can't all get through before any of them is counted
+type Attempt struct {
+	keys []reservedKey
+}
+
+// reservedKey is an attempt counted for a key, with what it replaced to undo it
+type reservedKey struct {
+	limitKey
+	failures    int
+	lastFailure time.Time
+}
+
+// Reserve counts a sign-in attempt to the login from the IP as failed ahead of checking the credentials.
+// If the login or the IP has to wait, nothing is counted and the wait is returned instead.
+// The attempt is then settled with Fail, Pass, Succeed or Release
+func (l *LoginLimiter) Reserve(ctx context.Context, login, ip string) (*Attempt, time.Duration, error) {
+	now := l.now()
+
+	attempt := &Attempt{}
+	for _, k := range l.keys(login, ip) {
+		var (
+			reserved reservedKey
+			wait     time.Duration
+		)
+		_, err := l.store.Update(ctx, k.key, now, k.policy.Window, func(attempts Attempts) Attempts {
+			reserved = reservedKey{limitKey: k, lastFailure: attempts.LastFailure}
+			if wait = k.policy.wait(attempts, now); wait > 0 {
+				return attempts
+			}
+
+			attempts.Failures++
+			attempts.LastFailure = now
+			reserved.failures = attempts.Failures
+			return attempts
+		})
+		if err == nil && wait == 0 {
+			attempt.keys = append(attempt.keys, reserved)
+			continue
+		}
+
+		// the keys reserved so far must not count an attempt that never happened
+		if releaseErr := l.Release(ctx, attempt); releaseErr != nil && err == nil {
+			err = releaseErr
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		return nil, wait, nil
+	}
+
+	return attempt, 0, nil
+}
+
+// Fail keeps a reserved attempt counted as failed and locks the login or the IP out once they reach their limit
+func (l *LoginLimiter) Fail(ctx context.Context, attempt *Attempt) (Failure, error) {
+	now := l.now()
+
+	var res Failure
+	for _, k := range attempt.keys {
+		if k.login {
+			res.LoginFailures = k.failures
+		}
+		if k.policy.MaxFailures <= 0 || k.failures < k.policy.MaxFailures {
+			continue
+		}
+
+		until := now.Add(k.policy.LockoutDuration)
+		_, err := l.store.Update(ctx, k.key, now, k.policy.Window, func(attempts Attempts) Attempts {
+			if until.After(attempts.LockedUntil) {
+				attempts.LockedUntil = until
+			}
+			return attempts
+		})
+		if err != nil {
+			return Failure{}, err
+		}
+
+		res.LockedUntil = until
+		if k.login {
+			res.LoginLocked = true
+		} else {
+			res.IPLocked = true
+		}
+	}
+
+	return res, nil
+}
+
+// Succeed forgets failed attempts of the login after a successful sign-in.
+// The IP keeps its history, a single success must not unlock guessing passwords of other users,
+// only the reserved attempt is taken back
+func (l *LoginLimiter) Succeed(ctx context.Context, attempt *Attempt) error {
+	for _, k := range attempt.keys {
+		if !k.login {
+			if err := l.release(ctx, k); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := l.store.Reset(ctx, k.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pass settles an attempt whose password was right while the sign-in still needs a second factor.
+// The IP attempt is taken back, the login one stays counted until the sign-in succeeds,
+// so knowing the password doesn't give unlimited tries at the second factor
+func (l *LoginLimiter) Pass(ctx context.Context, attempt *Attempt) error {
+	for _, k := range attempt.keys {
+		if k.login {
+			continue
+		}
+		if err := l.release(ctx, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Release takes a reserved attempt back when the credentials could not be checked at all
+func (l *LoginLimiter) Release(ctx context.Context, attempt *Attempt) error {
+	for _, k := range attempt.keys {
+		if err := l.release(ctx, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// release uncounts the attempt reserved for the key, the previous failure time
+// is restored unless other attempts were counted in the meantime
+func (l *LoginLimiter) release(ctx context.Context, k reservedKey) error {
+	_, err := l.store.Update(ctx, k.key, l.now(), k.policy.Window, func(attempts Attempts) Attempts {
+		if attempts.Failures == 0 {
+			return attempts
+		}
+		if attempts.Failures == k.failures {
+			attempts.LastFailure = k.lastFailure
+		}
+		attempts.Failures--
+		if attempts.Failures == 0 {
+			attempts.LastFailure = time.Time{}
+		}
+		return attempts
+	})
+	return err
+}
+
+// limitKey pairs a store key with the policy it is limited by
+type limitKey struct {
+	key    string
+	policy LimitPolicy
+	login  bool
+}
+
+// keys returns the store keys of the attempt, an unknown IP is not tracked
+func (l *LoginLimiter) keys(login, ip string) []limitKey {
+	keys := []limitKey{{key: loginKey(login), policy: l.login, login: true}}
+	if ip = strings.TrimSpace(ip); ip != "" {
+		keys = append(keys, limitKey{key: ipKey(ip), policy: l.ip})
+	}
+	return keys
+}
+
+// maxIPLength is the longest textual IPv6 address
+const maxIPLength = 45
+
+// ipKey returns the store key of a client IP. Addresses are normalized so one IP is always one key,
+// anything else is cut to the length of an address to fit the store
+func ipKey(ip string) string {
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		return "ip:" + addr.Unmap().String()
+	}
+	if len(ip) > maxIPLength {
+		ip = ip[:maxIPLength]
+	}
+	return "ip:" + ip
+}
+
+// loginKey returns the store key of a login
+func loginKey(login string) string {
+	return "login:" + login
+}
+
+// memoryPruneInterval limits how often MemoryAttemptStore looks for forgotten keys
+const memoryPruneInterval = time.Minute
+
+// MemoryAttemptStore keeps failed attempts in memory, it suits a single instance
+type MemoryAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]Attempts
+	prunedAt time.Time
+}
+
+// NewMemoryAttemptStore creates an in-memory attempt store
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{attempts: make(map[string]Attempts)}
+}
+
+// Update changes the attempts of the key under the lock of the store and returns the new ones
+func (s *MemoryAttemptStore) Update(_ context.Context, key string, now time.Time, window time.Duration, fn func(Attempts) Attempts) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(now, window)
+
+	attempts := s.attempts[key]
+	if now.Sub(attempts.LastFailure) > window {
+		attempts.Failures = 0
+		attempts.LastFailure = time.Time{}
+	}
+
+	attempts = fn(attempts)
+	if attempts == (Attempts{}) {
+		delete(s.attempts, key)
+	} else {
+		s.attempts[key] = attempts
+	}
+
+	return attempts, nil
+}
+
+// Reset forgets the attempts of the key
+func (s *MemoryAttemptStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+// prune drops keys that are neither locked nor failed within the window, the caller must hold the lock
+func (s *MemoryAttemptStore) prune(now time.Time, window time.Duration) {
+	if now.Sub(s.prunedAt) < memoryPruneInterval {
+		return
+	}
+	s.prunedAt = now
+
+	for key, attempts := range s.attempts {
+		if now.Sub(attempts.LastFailure) > window && attempts.LockedUntil.Before(now) {
+			delete(s.attempts, key)
+		}
+	}
+}
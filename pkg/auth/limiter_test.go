@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimitPolicyDelay(t *testing.T) {
+	policy := LimitPolicy{FreeAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	tests := []struct {
+		name     string
+		policy   LimitPolicy
+		failures int
+		want     time.Duration
+	}{
+		{name: "no failures", policy: policy, failures: 0, want: 0},
+		{name: "last free attempt", policy: policy, failures: 3, want: 0},
+		{name: "first paid failure waits base delay", policy: policy, failures: 4, want: time.Second},
+		{name: "second paid failure doubles", policy: policy, failures: 5, want: 2 * time.Second},
+		{name: "third paid failure doubles again", policy: policy, failures: 6, want: 4 * time.Second},
+		{name: "fourth paid failure", policy: policy, failures: 7, want: 8 * time.Second},
+		{name: "capped at max delay", policy: policy, failures: 8, want: 10 * time.Second},
+		{name: "stays capped", policy: policy, failures: 1000, want: 10 * time.Second},
+		{name: "no base delay", policy: LimitPolicy{FreeAttempts: 1, MaxDelay: time.Minute}, failures: 5, want: 0},
+		{name: "max delay below base delay", policy: LimitPolicy{BaseDelay: 10 * time.Second, MaxDelay: 5 * time.Second}, failures: 1, want: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.delay(tt.failures); got != tt.want {
+				t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitPolicyWait(t *testing.T) {
+	policy := LimitPolicy{FreeAttempts: 1, BaseDelay: time.Second, MaxDelay: time.Minute}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		attempts Attempts
+		want     time.Duration
+	}{
+		{name: "no attempts", attempts: Attempts{}, want: 0},
+		{name: "free failure", attempts: Attempts{Failures: 1, LastFailure: now}, want: 0},
+		{name: "delay not passed", attempts: Attempts{Failures: 3, LastFailure: now.Add(-time.Second)}, want: time.Second},
+		{name: "delay passed", attempts: Attempts{Failures: 3, LastFailure: now.Add(-5 * time.Second)}, want: 0},
+		{name: "locked out", attempts: Attempts{LockedUntil: now.Add(time.Minute)}, want: time.Minute},
+		{name: "lockout expired", attempts: Attempts{LockedUntil: now.Add(-time.Second)}, want: 0},
+		{
+			name:     "longest of lockout and delay",
+			attempts: Attempts{Failures: 3, LastFailure: now, LockedUntil: now.Add(time.Second)},
+			want:     2 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.wait(tt.attempts, now); got != tt.want {
+				t.Errorf("wait() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryAttemptStoreWindow(t *testing.T) {
+	const window = time.Hour
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		saved Attempts
+		now   time.Time
+		want  Attempts
+	}{
+		{
+			name:  "failures within the window are kept",
+			saved: Attempts{Failures: 2, LastFailure: start},
+			now:   start.Add(window),
+			want:  Attempts{Failures: 2, LastFailure: start},
+		},
+		{
+			name:  "failures after the window are forgotten",
+			saved: Attempts{Failures: 2, LastFailure: start},
+			now:   start.Add(window + time.Second),
+			want:  Attempts{},
+		},
+		{
+			name:  "lockout outlives forgotten failures",
+			saved: Attempts{Failures: 2, LastFailure: start, LockedUntil: start.Add(2 * window)},
+			now:   start.Add(window + time.Second),
+			want:  Attempts{LockedUntil: start.Add(2 * window)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryAttemptStore()
+
+			if _, err := store.Update(ctx, "login:john", start, window, func(Attempts) Attempts { return tt.saved }); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+
+			var got Attempts
+			if _, err := store.Update(ctx, "login:john", tt.now, window, func(a Attempts) Attempts {
+				got = a
+				return a
+			}); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Update() got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryAttemptStorePrune(t *testing.T) {
+	const window = time.Hour
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryAttemptStore()
+
+	set := func(key string, attempts Attempts) {
+		if _, err := store.Update(ctx, key, start, window, func(Attempts) Attempts { return attempts }); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+	set("login:old", Attempts{Failures: 1, LastFailure: start})
+	set("login:locked", Attempts{Failures: 1, LastFailure: start, LockedUntil: start.Add(3 * window)})
+	set("login:zero", Attempts{})
+
+	// any update after the window prunes forgotten keys
+	later := start.Add(2 * window)
+	if _, err := store.Update(ctx, "login:other", later, window, func(a Attempts) Attempts { return a }); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if _, ok := store.attempts["login:old"]; ok {
+		t.Error("key with forgotten failures was not pruned")
+	}
+	if _, ok := store.attempts["login:locked"]; !ok {
+		t.Error("locked key was pruned")
+	}
+	if _, ok := store.attempts["login:zero"]; ok {
+		t.Error("zero attempts were stored")
+	}
+	if _, ok := store.attempts["login:other"]; ok {
+		t.Error("zero attempts were stored")
+	}
+}
+
+func TestLoginLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := LimitPolicy{
+		FreeAttempts:    2,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		MaxFailures:     4,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+
+	limiter := NewLoginLimiter(NewMemoryAttemptStore(), policy, LimitPolicy{Window: time.Hour})
+	limiter.now = func() time.Time { return now }
+
+	fail := func() Failure {
+		t.Helper()
+		attempt, wait, err := limiter.Reserve(ctx, "john", "192.0.2.1")
+		if err != nil || wait != 0 {
+			t.Fatalf("Reserve() wait = %v, error = %v", wait, err)
+		}
+		res, err := limiter.Fail(ctx, attempt)
+		if err != nil {
+			t.Fatalf("Fail() error = %v", err)
+		}
+		return res
+	}
+
+	fail()
+	fail()
+	if res := fail(); res.LoginFailures != 3 || res.LoginLocked {
+		t.Fatalf("third failure = %+v, want 3 failures and no lockout", res)
+	}
+
+	if _, wait, _ := limiter.Reserve(ctx, "john", "192.0.2.1"); wait != time.Second {
+		t.Fatalf("Reserve() wait = %v, want %v", wait, time.Second)
+	}
+
+	now = now.Add(time.Second)
+	res := fail()
+	if !res.LoginLocked || res.IPLocked || !res.LockedUntil.Equal(now.Add(policy.LockoutDuration)) {
+		t.Fatalf("fourth failure = %+v, want the login locked out", res)
+	}
+
+	now = now.Add(10 * time.Minute)
+	if _, wait, _ := limiter.Reserve(ctx, "john", "192.0.2.1"); wait != 5*time.Minute {
+		t.Fatalf("Reserve() wait = %v, want %v", wait, 5*time.Minute)
+	}
+
+	now = now.Add(5 * time.Minute)
+	attempt, wait, err := limiter.Reserve(ctx, "john", "192.0.2.1")
+	if err != nil || wait != 0 {
+		t.Fatalf("Reserve() after lockout wait = %v, error = %v", wait, err)
+	}
+	if err := limiter.Succeed(ctx, attempt); err != nil {
+		t.Fatalf("Succeed() error = %v", err)
+	}
+
+	if res := fail(); res.LoginFailures != 1 {
+		t.Errorf("failure after success = %+v, want 1 failure", res)
+	}
+}
+
+func TestLoginLimiterRelease(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryAttemptStore()
+	policy := LimitPolicy{Window: time.Hour}
+
+	limiter := NewLoginLimiter(store, policy, policy)
+	limiter.now = func() time.Time { return now }
+
+	attempt, _, err := limiter.Reserve(ctx, "john", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+	if len(store.attempts) != 2 {
+		t.Fatalf("Reserve() counted %d keys, want 2", len(store.attempts))
+	}
+
+	if err := limiter.Release(ctx, attempt); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if len(store.attempts) != 0 {
+		t.Errorf("Release() left %v", store.attempts)
+	}
+}
+
+func TestIPKey(t *testing.T) {
+	tests := []struct {
+		name string
+		ip   string
+		want string
+	}{
+		{name: "IPv4", ip: "192.0.2.1", want: "ip:192.0.2.1"},
+		{name: "IPv4-mapped IPv6", ip: "::ffff:192.0.2.1", want: "ip:192.0.2.1"},
+		{name: "IPv6 is normalized", ip: "2001:DB8:0:0:0:0:0:1", want: "ip:2001:db8::1"},
+		{name: "not an address", ip: "unknown", want: "ip:unknown"},
+		{name: "long garbage is cut", ip: string(make([]byte, 100)), want: "ip:" + string(make([]byte, maxIPLength))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ipKey(tt.ip); got != tt.want {
+				t.Errorf("ipKey(%q) = %q, want %q", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
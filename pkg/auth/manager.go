@@ -3,9 +3,13 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -13,70 +17,204 @@ import (
 
 // TokenManager defines methods for creating and parsing tokens
 type TokenManager interface {
-	NewJWTToken(userID int64, ttl time.Duration) (string, error)
-	ParseJWTToken(accessToken string) (int64, error)
+	NewJWTToken(userID, sessionID int64, role string, ttl time.Duration) (string, error)
+	ParseJWTToken(accessToken string) (*TokenClaims, error)
 	NewRefreshToken() (string, error)
+	JWKS() JWKSet
 }
 
-// Manager implements TokenManager using HMAC signing
+// Manager implements TokenManager. Tokens are signed with the first asymmetric key and carry its kid,
+// the other keys only verify tokens so they can be rotated out without signing everyone out.
+// The legacy HMAC secret signs tokens when no asymmetric key is configured and verifies tokens without kid
 type Manager struct {
-	signingKey string
+	hmacSecret []byte
+	signingKey *Key
+	keys       map[string]*Key
+	claims     ClaimsOptions
 }
 
-// NewManager creates a new Manager with the given signing key
-func NewManager(signingKey string) (*Manager, error) {
-	if signingKey == "" {
-		return nil, errors.New("empty signing key")
+// ClaimsOptions holds registered claims put into every token and enforced on parse
+type ClaimsOptions struct {
+	// Issuer is the iss claim, tokens of other issuers are rejected
+	Issuer string
+	// Audience is the aud claim, a token must be meant for at least one of these audiences
+	Audience []string
+	// Leeway is the allowed clock skew when checking exp, nbf and iat
+	Leeway time.Duration
+}
+
+// NewManager creates a new Manager with the legacy HMAC secret, asymmetric keys and claims options,
+// the first key signs tokens
+func NewManager(hmacSecret string, keys []*Key, claims ClaimsOptions) (*Manager, error) {
+	if hmacSecret == "" && len(keys) == 0 {
+		return nil, errors.New("neither signing secret nor signing keys are set")
+	}
+	if claims.Issuer == "" {
+		return nil, errors.New("empty issuer")
+	}
+	if len(claims.Audience) == 0 {
+		return nil, errors.New("empty audience")
+	}
+	if claims.Leeway < 0 {
+		return nil, errors.New("negative leeway")
+	}
+
+	m := &Manager{
+		hmacSecret: []byte(hmacSecret),
+		keys:       make(map[string]*Key, len(keys)),
+		claims:     claims,
+	}
+
+	for i, key := range keys {
+		if _, ok := m.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		if i == 0 {
+			if !key.CanSign() {
+				return nil, fmt.Errorf("signing key %q has no private part", key.ID)
+			}
+			m.signingKey = key
+		}
+		m.keys[key.ID] = key
 	}
-	return &Manager{signingKey: signingKey}, nil
+
+	return m, nil
 }
 
-// TokenClaims defines custom JWT claims including user ID
+// TokenClaims defines custom JWT claims including user ID, role and the session the token was issued for.
+// The registered ID claim (jti) identifies the token for revocation
 type TokenClaims struct {
 	jwt.RegisteredClaims
-	UserID int64 `json:"user_id"`
+	UserID    int64  `json:"user_id"`
+	SessionID int64  `json:"sid,omitempty"`
+	Role      string `json:"role,omitempty"`
 }
 
-// NewJWTToken generates a signed JWT token with user ID and expiration time
-func (m *Manager) NewJWTToken(userID int64, ttl time.Duration) (string, error) {
+// NewJWTToken generates a signed JWT token with a unique ID, user and session IDs, user role and expiration time
+func (m *Manager) NewJWTToken(userID, sessionID int64, role string, ttl time.Duration) (string, error) {
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", fmt.Errorf("token id: %w", err)
+	}
+
+	now := time.Now()
 	claims := TokenClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        tokenID,
+			Issuer:    m.claims.Issuer,
+			Subject:   strconv.FormatInt(userID, 10),
+			Audience:  m.claims.Audience,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
+		Role:      role,
+	}
+
+	if m.signingKey == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(m.hmacSecret)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(m.signingKey))
+	token := jwt.NewWithClaims(m.signingKey.Method, claims)
+	token.Header["kid"] = m.signingKey.ID
+	return token.SignedString(m.signingKey.PrivateKey)
 }
 
-// ParseJWTToken validates a JWT token and extracts the user ID from claims
-func (m *Manager) ParseJWTToken(accessToken string) (int64, error) {
-	token, err := jwt.ParseWithClaims(accessToken, &TokenClaims{},
-		func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("invalid signing method: %v", token.Header["alg"])
-			}
-			return []byte(m.signingKey), nil
-		})
+// ParseJWTToken validates a JWT token and returns its claims, the verification key is selected by kid.
+// Issuer, audience, expiration, not before and issued at claims are enforced with the configured leeway
+func (m *Manager) ParseJWTToken(accessToken string) (*TokenClaims, error) {
+	token, err := jwt.ParseWithClaims(accessToken, &TokenClaims{}, m.verificationKey,
+		jwt.WithIssuer(m.claims.Issuer),
+		jwt.WithAudience(m.claims.Audience...),
+		jwt.WithLeeway(m.claims.Leeway),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
 	if err != nil {
-		return 0, fmt.Errorf("invalid token: %w", err)
+		return nil, fmt.Errorf("invalid token: %w", err)
 	}
 
 	claims, ok := token.Claims.(*TokenClaims)
 	if !ok || !token.Valid {
-		return 0, fmt.Errorf("invalid token claims: %w", err)
+		return nil, errors.New("invalid token claims")
+	}
+
+	if claims.ID == "" {
+		return nil, errors.New("invalid token claims: missing jti")
+	}
+	if claims.Subject != strconv.FormatInt(claims.UserID, 10) {
+		return nil, errors.New("invalid token claims: subject doesn't match user")
 	}
 
-	return claims.UserID, nil
+	return claims, nil
+}
+
+// verificationKey selects the key a token must be verified with and checks that the token uses its algorithm
+func (m *Manager) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if len(m.hmacSecret) == 0 {
+			return nil, errors.New("missing kid header")
+		}
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("invalid signing method: %v", token.Header["alg"])
+		}
+		return m.hmacSecret, nil
+	}
+
+	key, ok := m.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("invalid signing method: %v", token.Header["alg"])
+	}
+	return key.PublicKey, nil
+}
+
+// JWKS returns public parts of all asymmetric keys, the HMAC secret is never published
+func (m *Manager) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(m.keys))}
+	for _, key := range m.keys {
+		set.Keys = append(set.Keys, key.JWK())
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
 }
 
 // NewRefreshToken generates a secure random refresh token
 func (m *Manager) NewRefreshToken() (string, error) {
+	return NewRandomToken()
+}
+
+// NewRandomToken generates a secure random opaque token, e.g. for refresh tokens and email links
+func NewRandomToken() (string, error) {
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
 	return base64.URLEncoding.EncodeToString(b), nil
 }
+
+// newTokenID generates a random unique token ID for the jti claim
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashRefreshToken returns the hex SHA-256 of a refresh token, random tokens don't need a slow hash
+func HashRefreshToken(refreshToken string) string {
+	return HashToken(refreshToken)
+}
+
+// HashToken returns the hex SHA-256 of a random opaque token, only this hash is stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
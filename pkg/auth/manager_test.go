@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "marketplace"
+	testAudience = "marketplace-api"
+	testLeeway   = 30 * time.Second
+	testSecret   = "legacy-secret"
+)
+
+// newTestKey generates an Ed25519 signing key with the kid
+func newTestKey(t *testing.T, id string) *Key {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+
+	key, err := ParseKeyPEM(id, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("ParseKeyPEM() error = %v", err)
+	}
+	return key
+}
+
+// newTestManager returns a manager signing with an Ed25519 key and accepting legacy HS256 tokens
+func newTestManager(t *testing.T) (*Manager, *Key) {
+	t.Helper()
+
+	key := newTestKey(t, "2026-10")
+	m, err := NewManager(testSecret, []*Key{key}, ClaimsOptions{
+		Issuer:   testIssuer,
+		Audience: []string{testAudience},
+		Leeway:   testLeeway,
+	})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	return m, key
+}
+
+// validClaims returns claims the test manager accepts, tests break one claim at a time
+func validClaims(now time.Time) TokenClaims {
+	return TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "token-id",
+			Issuer:    testIssuer,
+			Subject:   "42",
+			Audience:  jwt.ClaimStrings{testAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		UserID: 42,
+		Role:   "user",
+	}
+}
+
+func TestManagerRoundTrip(t *testing.T) {
+	m, key := newTestManager(t)
+
+	token, err := m.NewJWTToken(42, 7, "admin", time.Hour)
+	if err != nil {
+		t.Fatalf("NewJWTToken() error = %v", err)
+	}
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &TokenClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified() error = %v", err)
+	}
+	if parsed.Header["kid"] != key.ID || parsed.Method.Alg() != "EdDSA" {
+		t.Errorf("token header = %v, want kid %q and EdDSA", parsed.Header, key.ID)
+	}
+
+	claims, err := m.ParseJWTToken(token)
+	if err != nil {
+		t.Fatalf("ParseJWTToken() error = %v", err)
+	}
+	if claims.UserID != 42 || claims.SessionID != 7 || claims.Role != "admin" || claims.Subject != "42" {
+		t.Errorf("claims = %+v", claims)
+	}
+	if claims.Issuer != testIssuer || len(claims.Audience) != 1 || claims.Audience[0] != testAudience {
+		t.Errorf("iss = %q, aud = %v", claims.Issuer, claims.Audience)
+	}
+	if claims.ID == "" || claims.NotBefore == nil || claims.IssuedAt == nil {
+		t.Errorf("jti, nbf or iat missing: %+v", claims.RegisteredClaims)
+	}
+}
+
+func TestManagerParseJWTTokenClaims(t *testing.T) {
+	m, key := newTestManager(t)
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		modify  func(c *TokenClaims)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(c *TokenClaims) {}},
+		{name: "another issuer", modify: func(c *TokenClaims) { c.Issuer = "staging" }, wantErr: true},
+		{name: "missing issuer", modify: func(c *TokenClaims) { c.Issuer = "" }, wantErr: true},
+		{name: "another audience", modify: func(c *TokenClaims) { c.Audience = jwt.ClaimStrings{"billing"} }, wantErr: true},
+		{name: "one of audiences", modify: func(c *TokenClaims) { c.Audience = jwt.ClaimStrings{"billing", testAudience} }},
+		{name: "missing audience", modify: func(c *TokenClaims) { c.Audience = nil }, wantErr: true},
+		{name: "expired within leeway", modify: func(c *TokenClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-testLeeway / 2)) }},
+		{name: "expired beyond leeway", modify: func(c *TokenClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-2 * testLeeway)) }, wantErr: true},
+		{name: "missing expiration", modify: func(c *TokenClaims) { c.ExpiresAt = nil }, wantErr: true},
+		{name: "not before within leeway", modify: func(c *TokenClaims) { c.NotBefore = jwt.NewNumericDate(now.Add(testLeeway / 2)) }},
+		{name: "not before beyond leeway", modify: func(c *TokenClaims) { c.NotBefore = jwt.NewNumericDate(now.Add(2 * testLeeway)) }, wantErr: true},
+		{name: "issued in the future within leeway", modify: func(c *TokenClaims) { c.IssuedAt = jwt.NewNumericDate(now.Add(testLeeway / 2)) }},
+		{name: "issued in the future beyond leeway", modify: func(c *TokenClaims) { c.IssuedAt = jwt.NewNumericDate(now.Add(2 * testLeeway)) }, wantErr: true},
+		{name: "missing jti", modify: func(c *TokenClaims) { c.ID = "" }, wantErr: true},
+		{name: "subject of another user", modify: func(c *TokenClaims) { c.Subject = "43" }, wantErr: true},
+		{name: "missing subject", modify: func(c *TokenClaims) { c.Subject = "" }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims(now)
+			tt.modify(&claims)
+
+			token := jwt.NewWithClaims(key.Method, claims)
+			token.Header["kid"] = key.ID
+			signed, err := token.SignedString(key.PrivateKey)
+			if err != nil {
+				t.Fatalf("SignedString() error = %v", err)
+			}
+
+			_, err = m.ParseJWTToken(signed)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseJWTToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestManagerParseJWTTokenKeys(t *testing.T) {
+	m, key := newTestManager(t)
+	other := newTestKey(t, "other")
+	claims := validClaims(time.Now())
+
+	sign := func(method jwt.SigningMethod, kid string, secret any) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(secret)
+		if err != nil {
+			t.Fatalf("SignedString() error = %v", err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "signing key", token: sign(key.Method, key.ID, key.PrivateKey)},
+		{name: "legacy HS256 without kid", token: sign(jwt.SigningMethodHS256, "", []byte(testSecret))},
+		{name: "HS256 with a wrong secret", token: sign(jwt.SigningMethodHS256, "", []byte("guess")), wantErr: true},
+		{name: "unknown kid", token: sign(other.Method, other.ID, other.PrivateKey), wantErr: true},
+		{name: "known kid signed by another key", token: sign(other.Method, key.ID, other.PrivateKey), wantErr: true},
+		{name: "HS256 with the kid of an asymmetric key", token: sign(jwt.SigningMethodHS256, key.ID, []byte(testSecret)), wantErr: true},
+		{name: "alg none", token: sign(jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType), wantErr: true},
+		{name: "HS512 without kid", token: sign(jwt.SigningMethodHS512, "", []byte(testSecret)), wantErr: true},
+		{name: "garbage", token: "not.a.token", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.ParseJWTToken(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseJWTToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestManagerRejectsTokensOfAnotherEnvironment(t *testing.T) {
+	key := newTestKey(t, "shared")
+	newManager := func(issuer, audience string) *Manager {
+		m, err := NewManager("", []*Key{key}, ClaimsOptions{Issuer: issuer, Audience: []string{audience}})
+		if err != nil {
+			t.Fatalf("NewManager() error = %v", err)
+		}
+		return m
+	}
+
+	staging := newManager("staging", "marketplace")
+	prod := newManager("prod", "marketplace")
+
+	token, err := staging.NewJWTToken(1, 1, "admin", time.Hour)
+	if err != nil {
+		t.Fatalf("NewJWTToken() error = %v", err)
+	}
+	if _, err := prod.ParseJWTToken(token); err == nil || !strings.Contains(err.Error(), "issuer") {
+		t.Errorf("ParseJWTToken() of a staging token in prod error = %v, want an issuer error", err)
+	}
+}
+
+func TestNewManagerValidatesOptions(t *testing.T) {
+	key := newTestKey(t, "k")
+	valid := ClaimsOptions{Issuer: testIssuer, Audience: []string{testAudience}}
+
+	tests := []struct {
+		name   string
+		secret string
+		keys   []*Key
+		claims ClaimsOptions
+	}{
+		{name: "no secret or keys", claims: valid},
+		{name: "empty issuer", keys: []*Key{key}, claims: ClaimsOptions{Audience: []string{testAudience}}},
+		{name: "empty audience", keys: []*Key{key}, claims: ClaimsOptions{Issuer: testIssuer}},
+		{name: "negative leeway", keys: []*Key{key}, claims: ClaimsOptions{Issuer: testIssuer, Audience: []string{testAudience}, Leeway: -time.Second}},
+		{name: "duplicate kid", keys: []*Key{key, key}, claims: valid},
+		{name: "public signing key", keys: []*Key{{ID: "pub", Method: key.Method, PublicKey: key.PublicKey}}, claims: valid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewManager(tt.secret, tt.keys, tt.claims); err == nil {
+				t.Error("NewManager() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RevocationStore keeps track of access tokens that must stop working before they expire
+type RevocationStore interface {
+	// Revoke denies a single token by its ID until it expires
+	Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error
+	// RevokeUser denies all tokens of the user issued up to the given time, until expiresAt.
+	// Token issue times have whole seconds, so the whole second of issuedBefore is revoked,
+	// tokens that must keep working have to be issued after it, see RevokeAllTokens
+	RevokeUser(ctx context.Context, userID int64, issuedBefore, expiresAt time.Time) error
+	// IsRevoked reports whether a token with the given claims was revoked
+	IsRevoked(ctx context.Context, claims *TokenClaims) (bool, error)
+}
+
+// RevokeAllTokens denies every token of the user issued so far, for ttl, the lifetime of the tokens.
+// The current second is revoked as a whole, so it returns once the second is over
+// and tokens issued to the user afterwards keep working
+func RevokeAllTokens(ctx context.Context, store RevocationStore, userID int64, ttl time.Duration) error {
+	now := time.Now()
+	if err := store.RevokeUser(ctx, userID, now, now.Add(ttl)); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(time.Until(now.Truncate(time.Second).Add(time.Second)))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// userRevocation denies tokens of a user issued in the second of issuedBefore or earlier
+type userRevocation struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+// MemoryRevocationStore keeps revocations in memory and writes them through to an optional fallback store.
+// Tokens unknown to memory are looked up in the fallback, so revocations made by other instances are honored
+type MemoryRevocationStore struct {
+	mu       sync.RWMutex
+	tokens   map[string]time.Time
+	users    map[int64]userRevocation
+	fallback RevocationStore
+}
+
+// NewMemoryRevocationStore creates an in-memory revocation store, fallback may be nil
+func NewMemoryRevocationStore(fallback RevocationStore) *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		tokens:   make(map[string]time.Time),
+		users:    make(map[int64]userRevocation),
+		fallback: fallback,
+	}
+}
+
+// Revoke denies a single token by its ID until it expires
+func (s *MemoryRevocationStore) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	if s.fallback != nil {
+		if err := s.fallback.Revoke(ctx, tokenID, expiresAt); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(time.Now())
+	s.tokens[tokenID] = expiresAt
+	return nil
+}
+
+// RevokeUser denies all tokens of the user issued up to the end of the given second, until expiresAt
+func (s *MemoryRevocationStore) RevokeUser(ctx context.Context, userID int64, issuedBefore, expiresAt time.Time) error {
+	issuedBefore = issuedBefore.Truncate(time.Second)
+
+	if s.fallback != nil {
+		if err := s.fallback.RevokeUser(ctx, userID, issuedBefore, expiresAt); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(time.Now())
+	s.users[userID] = userRevocation{issuedBefore: issuedBefore, expiresAt: expiresAt}
+	return nil
+}
+
+// IsRevoked reports whether a token was revoked, asking the fallback store if memory doesn't know the token
+func (s *MemoryRevocationStore) IsRevoked(ctx context.Context, claims *TokenClaims) (bool, error) {
+	if s.isRevokedInMemory(claims) {
+		return true, nil
+	}
+	if s.fallback == nil {
+		return false, nil
+	}
+
+	revoked, err := s.fallback.IsRevoked(ctx, claims)
+	if err != nil || !revoked {
+		return false, err
+	}
+
+	if claims.ExpiresAt != nil {
+		s.mu.Lock()
+		s.tokens[claims.ID] = claims.ExpiresAt.Time
+		s.mu.Unlock()
+	}
+	return true, nil
+}
+
+// isRevokedInMemory checks the token ID and the user-wide revocation kept in memory
+func (s *MemoryRevocationStore) isRevokedInMemory(claims *TokenClaims) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.tokens[claims.ID]; ok && claims.ID != "" {
+		return true
+	}
+
+	user, ok := s.users[claims.UserID]
+	if !ok || claims.IssuedAt == nil {
+		return ok
+	}
+	return !claims.IssuedAt.After(user.issuedBefore)
+}
+
+// prune drops revocations of tokens that have already expired, the caller must hold the lock
+func (s *MemoryRevocationStore) prune(now time.Time) {
+	for id, expiresAt := range s.tokens {
+		if expiresAt.Before(now) {
+			delete(s.tokens, id)
+		}
+	}
+	for id, user := range s.users {
+		if user.expiresAt.Before(now) {
+			delete(s.users, id)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// claimsAt returns claims of a token of the user issued at the moment
+func claimsAt(id string, userID int64, issuedAt time.Time) *TokenClaims {
+	claims := &TokenClaims{UserID: userID}
+	claims.ID = id
+	claims.ExpiresAt = jwt.NewNumericDate(issuedAt.Add(time.Hour))
+	if !issuedAt.IsZero() {
+		claims.IssuedAt = jwt.NewNumericDate(issuedAt)
+	}
+	return claims
+}
+
+func TestMemoryRevocationStoreRevokeUser(t *testing.T) {
+	// the revocation happens in the middle of a second, token issue times have whole seconds
+	revokedAt := time.Now().Truncate(time.Second).Add(500 * time.Millisecond)
+	second := revokedAt.Truncate(time.Second)
+
+	tests := []struct {
+		name   string
+		claims *TokenClaims
+		want   bool
+	}{
+		{name: "issued a second before", claims: claimsAt("a", 1, second.Add(-time.Second)), want: true},
+		{name: "issued in the same second", claims: claimsAt("b", 1, second), want: true},
+		{name: "issued in the next second", claims: claimsAt("c", 1, second.Add(time.Second)), want: false},
+		{name: "without issue time", claims: claimsAt("d", 1, time.Time{}), want: true},
+		{name: "of another user", claims: claimsAt("e", 2, second.Add(-time.Second)), want: false},
+	}
+
+	store := NewMemoryRevocationStore(nil)
+	if err := store.RevokeUser(context.Background(), 1, revokedAt, revokedAt.Add(time.Hour)); err != nil {
+		t.Fatalf("RevokeUser() error = %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.IsRevoked(context.Background(), tt.claims)
+			if err != nil {
+				t.Fatalf("IsRevoked() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsRevoked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryRevocationStoreRevoke(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewMemoryRevocationStore(nil)
+
+	if err := store.Revoke(ctx, "revoked", now.Add(time.Hour)); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		claims *TokenClaims
+		want   bool
+	}{
+		{name: "revoked token", claims: claimsAt("revoked", 1, now), want: true},
+		{name: "other token", claims: claimsAt("other", 1, now), want: false},
+		{name: "token without ID", claims: claimsAt("", 1, now), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.IsRevoked(ctx, tt.claims)
+			if err != nil {
+				t.Fatalf("IsRevoked() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsRevoked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryRevocationStoreFallback(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	// another instance revoked the token through the shared store
+	shared := NewMemoryRevocationStore(nil)
+	if err := shared.Revoke(ctx, "elsewhere", now.Add(time.Hour)); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+
+	store := NewMemoryRevocationStore(shared)
+	if got, err := store.IsRevoked(ctx, claimsAt("elsewhere", 1, now)); err != nil || !got {
+		t.Errorf("IsRevoked() of a token revoked in the fallback = %v, %v, want true", got, err)
+	}
+	if got, err := store.IsRevoked(ctx, claimsAt("valid", 1, now)); err != nil || got {
+		t.Errorf("IsRevoked() of a valid token = %v, %v, want false", got, err)
+	}
+
+	// revocations are written through to the fallback
+	if err := store.RevokeUser(ctx, 7, now, now.Add(time.Hour)); err != nil {
+		t.Fatalf("RevokeUser() error = %v", err)
+	}
+	if got, err := shared.IsRevoked(ctx, claimsAt("old", 7, now.Add(-time.Minute))); err != nil || !got {
+		t.Errorf("fallback IsRevoked() after RevokeUser = %v, %v, want true", got, err)
+	}
+}
+
+func TestRevokeAllTokens(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRevocationStore(nil)
+
+	before := time.Now()
+	if err := RevokeAllTokens(ctx, store, 1, time.Hour); err != nil {
+		t.Fatalf("RevokeAllTokens() error = %v", err)
+	}
+	after := time.Now()
+
+	if after.Truncate(time.Second).Equal(before.Truncate(time.Second)) {
+		t.Fatalf("RevokeAllTokens() returned within the revoked second %v", before.Truncate(time.Second))
+	}
+
+	if got, _ := store.IsRevoked(ctx, claimsAt("old", 1, before)); !got {
+		t.Error("token issued before the revocation is not revoked")
+	}
+	if got, _ := store.IsRevoked(ctx, claimsAt("new", 1, after)); got {
+		t.Error("token issued after RevokeAllTokens returned is revoked")
+	}
+}
@@ -0,0 +1,120 @@
+// Package breached checks passwords against lists of passwords exposed in data breaches.
+// Lookups follow the k-anonymity model of the Pwned Passwords range API: the SHA-1 hash of a password
+// is split into a 5 character prefix, which selects a bucket, and a suffix compared within the bucket
+package breached
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// PrefixLength is the length of the hash prefix selecting a bucket
+const PrefixLength = 5
+
+// Source returns the hash suffixes of breached passwords whose upper-case hex SHA-1 hash starts with the prefix
+type Source interface {
+	Range(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Contains reports whether the password is in the source, only the hash prefix is passed to it
+func Contains(ctx context.Context, src Source, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := src.Range(ctx, digest[:PrefixLength])
+	if err != nil {
+		return false, err
+	}
+
+	suffix := digest[PrefixLength:]
+	for _, s := range suffixes {
+		if s == suffix {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// List is an in-memory Source of breached password hashes grouped into prefix buckets
+type List struct {
+	buckets map[string][]string
+	size    int
+}
+
+// LoadFile reads a breached password list from a file, see Load for the format
+func LoadFile(path string) (*List, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open breached passwords: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return Load(f)
+}
+
+// Load reads a breached password list, one entry per line. An entry is either a hex SHA-1 hash,
+// optionally followed by ":count" as in Pwned Passwords downloads, or a plain password.
+// Empty lines and lines starting with # are skipped
+func Load(r io.Reader) (*List, error) {
+	list := &List{buckets: make(map[string][]string)}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		digest, ok := parseHash(line)
+		if !ok {
+			sum := sha1.Sum([]byte(line))
+			digest = strings.ToUpper(hex.EncodeToString(sum[:]))
+		}
+
+		prefix := digest[:PrefixLength]
+		list.buckets[prefix] = append(list.buckets[prefix], digest[PrefixLength:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read breached passwords: %w", err)
+	}
+
+	for prefix, suffixes := range list.buckets {
+		slices.Sort(suffixes)
+		suffixes = slices.Compact(suffixes)
+		list.buckets[prefix] = suffixes
+		list.size += len(suffixes)
+	}
+
+	return list, nil
+}
+
+// Range returns the hash suffixes of the bucket
+func (l *List) Range(_ context.Context, prefix string) ([]string, error) {
+	return l.buckets[strings.ToUpper(prefix)], nil
+}
+
+// Len returns the number of distinct password hashes in the list
+func (l *List) Len() int {
+	return l.size
+}
+
+// parseHash returns the upper-case digest of a "HASH" or "HASH:count" line
+func parseHash(line string) (string, bool) {
+	digest, _, _ := strings.Cut(line, ":")
+	if len(digest) != sha1.Size*2 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", false
+	}
+	return strings.ToUpper(digest), true
+}
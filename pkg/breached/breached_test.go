@@ -0,0 +1,138 @@
+package breached
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// digest returns the upper-case hex SHA-1 hash of the password
+func digest(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantLen  int
+		breached []string
+		safe     []string
+	}{
+		{
+			name:     "SHA-1 hashes",
+			input:    "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8\n" + digest("123456") + "\n",
+			wantLen:  2,
+			breached: []string{"password", "123456"},
+			safe:     []string{"Password", "1234567"},
+		},
+		{
+			name:     "lower-case SHA-1 hashes",
+			input:    "5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8\n",
+			wantLen:  1,
+			breached: []string{"password"},
+		},
+		{
+			name:     "hash:count lines",
+			input:    "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:10434004\r\n" + digest("qwerty") + ":3946737\r\n",
+			wantLen:  2,
+			breached: []string{"password", "qwerty"},
+			safe:     []string{"10434004"},
+		},
+		{
+			name:     "plain-text lines",
+			input:    "password\nletmein\n",
+			wantLen:  2,
+			breached: []string{"password", "letmein"},
+			safe:     []string{"letmein\n", " password"},
+		},
+		{
+			name: "mixed lines, comments and duplicates",
+			input: "# top passwords\n" +
+				"\n" +
+				"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3\n" +
+				"password\n" +
+				digest("dragon") + "\n" +
+				"monkey\n",
+			wantLen:  3,
+			breached: []string{"password", "dragon", "monkey"},
+			safe:     []string{"# top passwords", ""},
+		},
+		{
+			name:     "not quite a hash is a plain password",
+			input:    "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD\nZZAA61E4C9B93F3F0682250B6CF8331B7EE68FD8\n",
+			wantLen:  2,
+			breached: []string{"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD", "ZZAA61E4C9B93F3F0682250B6CF8331B7EE68FD8"},
+			safe:     []string{"password"},
+		},
+		{
+			name:    "empty list",
+			input:   "",
+			wantLen: 0,
+			safe:    []string{"password"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := Load(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if list.Len() != tt.wantLen {
+				t.Errorf("Len() = %d, want %d", list.Len(), tt.wantLen)
+			}
+
+			for _, password := range tt.breached {
+				if ok, err := Contains(context.Background(), list, password); err != nil || !ok {
+					t.Errorf("Contains(%q) = %v, %v, want true", password, ok, err)
+				}
+			}
+			for _, password := range tt.safe {
+				if ok, err := Contains(context.Background(), list, password); err != nil || ok {
+					t.Errorf("Contains(%q) = %v, %v, want false", password, ok, err)
+				}
+			}
+		})
+	}
+}
+
+func TestListRange(t *testing.T) {
+	list, err := Load(strings.NewReader("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8\n5BAA6FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF\n"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		prefix string
+		want   []string
+	}{
+		{
+			name:   "bucket is sorted",
+			prefix: "5BAA6",
+			want:   []string{"1E4C9B93F3F0682250B6CF8331B7EE68FD8", "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF"},
+		},
+		{
+			name:   "lower-case prefix",
+			prefix: "5baa6",
+			want:   []string{"1E4C9B93F3F0682250B6CF8331B7EE68FD8", "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF"},
+		},
+		{name: "empty bucket", prefix: "00000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := list.Range(context.Background(), tt.prefix)
+			if err != nil {
+				t.Fatalf("Range() error = %v", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Range() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package hash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2idPrefix starts every hash encoded by Argon2idHasher
+const argon2idPrefix = "$argon2id$"
+
+// Argon2Params holds Argon2id cost parameters
+type Argon2Params struct {
+	// Memory is the memory used by one hash in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follows the OWASP recommendation of 19 MiB of memory, 2 iterations and 1 thread
+var DefaultArgon2Params = Argon2Params{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// MaxArgon2Params are the highest parameters hashes are made or checked with. Stored hashes are not trusted
+// to be sane, one with a huge cost would take the memory or CPU of the server on every sign-in attempt
+var MaxArgon2Params = Argon2Params{
+	Memory:      256 * 1024,
+	Iterations:  16,
+	Parallelism: 16,
+	SaltLength:  64,
+	KeyLength:   64,
+}
+
+// Argon2idHasher implements PasswordHasher using Argon2id, hashes are encoded in the PHC string format:
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>
+type Argon2idHasher struct {
+	params Argon2Params
+	// slots limits how many hashes are computed at once, each takes params.Memory KiB
+	slots chan struct{}
+}
+
+// NewArgon2idHasher creates a new Argon2idHasher with the given parameters, capped at MaxArgon2Params.
+// At most concurrency hashes are computed at once, others wait, zero means one per CPU
+func NewArgon2idHasher(params Argon2Params, concurrency int) *Argon2idHasher {
+	params.Memory = min(params.Memory, MaxArgon2Params.Memory)
+	params.Iterations = min(params.Iterations, MaxArgon2Params.Iterations)
+	params.Parallelism = min(params.Parallelism, MaxArgon2Params.Parallelism)
+	params.SaltLength = min(params.SaltLength, MaxArgon2Params.SaltLength)
+	params.KeyLength = min(params.KeyLength, MaxArgon2Params.KeyLength)
+
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	return &Argon2idHasher{params: params, slots: make(chan struct{}, concurrency)}
+}
+
+// Hash generates an Argon2id hash for the given password with a random salt
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("generate salt: %w", err)
+	}
+
+	key := h.key(password, salt, h.params)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		h.params.Memory,
+		h.params.Iterations,
+		h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Check compares a plaintext password with a hashed password, using the parameters encoded in the hash
+func (h *Argon2idHasher) Check(password, hashedPassword string) bool {
+	params, salt, key, err := decodeArgon2id(hashedPassword)
+	if err != nil {
+		return false
+	}
+
+	other := h.key(password, salt, params)
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+// key derives the Argon2id key of the password once a slot is free
+func (h *Argon2idHasher) key(password string, salt []byte, params Argon2Params) []byte {
+	h.slots <- struct{}{}
+	defer func() {
+		<-h.slots
+	}()
+
+	return argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+}
+
+// NeedsRehash reports whether the hash isn't an Argon2id hash of the configured parameters
+func (h *Argon2idHasher) NeedsRehash(hashedPassword string) bool {
+	params, _, _, err := decodeArgon2id(hashedPassword)
+	if err != nil {
+		return true
+	}
+	return params != h.params
+}
+
+// Identify reports whether the hash is an Argon2id hash
+func (h *Argon2idHasher) Identify(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, argon2idPrefix)
+}
+
+// decodeArgon2id parses a PHC-encoded Argon2id hash
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2Params{}, nil, nil, errors.New("not an argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("parse version: %w", err)
+	}
+	if version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("parse parameters: %w", err)
+	}
+	if params.Iterations == 0 || params.Parallelism == 0 {
+		return Argon2Params{}, nil, nil, errors.New("invalid parameters")
+	}
+	if params.Memory > MaxArgon2Params.Memory || params.Iterations > MaxArgon2Params.Iterations ||
+		params.Parallelism > MaxArgon2Params.Parallelism {
+		return Argon2Params{}, nil, nil, errors.New("parameters above the limit")
+	}
+
+	if base64.RawStdEncoding.DecodedLen(len(parts[4])) > int(MaxArgon2Params.SaltLength) ||
+		base64.RawStdEncoding.DecodedLen(len(parts[5])) > int(MaxArgon2Params.KeyLength) {
+		return Argon2Params{}, nil, nil, errors.New("salt or key above the limit")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("decode salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, errors.New("decode key")
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
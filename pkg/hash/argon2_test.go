@@ -0,0 +1,171 @@
+package hash
+
+import (
+	"strings"
+	"testing"
+)
+
+// testArgon2Params keeps the tests fast, they are far below anything fit for production
+var testArgon2Params = Argon2Params{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestArgon2idHasherRoundTrip(t *testing.T) {
+	h := NewArgon2idHasher(testArgon2Params, 1)
+
+	tests := []struct {
+		name     string
+		password string
+	}{
+		{name: "ascii", password: "correct horse battery staple"},
+		{name: "unicode", password: "пароль-密码-🔑"},
+		{name: "empty", password: ""},
+		{name: "long", password: strings.Repeat("a", 1000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hashed, err := h.Hash(tt.password)
+			if err != nil {
+				t.Fatalf("Hash() error = %v", err)
+			}
+			if !strings.HasPrefix(hashed, "$argon2id$v=19$m=64,t=1,p=1$") {
+				t.Errorf("Hash() = %q, want a PHC string with the parameters", hashed)
+			}
+			if !h.Identify(hashed) {
+				t.Errorf("Identify(%q) = false", hashed)
+			}
+			if !h.Check(tt.password, hashed) {
+				t.Error("Check() with the right password = false")
+			}
+			if h.Check(tt.password+"x", hashed) {
+				t.Error("Check() with a wrong password = true")
+			}
+			if h.NeedsRehash(hashed) {
+				t.Error("NeedsRehash() of a fresh hash = true")
+			}
+		})
+	}
+}
+
+func TestArgon2idHasherSaltsEveryHash(t *testing.T) {
+	h := NewArgon2idHasher(testArgon2Params, 1)
+
+	first, err := h.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	second, err := h.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if first == second {
+		t.Error("two hashes of the same password are equal")
+	}
+}
+
+func TestArgon2idHasherNeedsRehash(t *testing.T) {
+	h := NewArgon2idHasher(testArgon2Params, 1)
+
+	stronger := testArgon2Params
+	stronger.Iterations = 2
+	old, err := NewArgon2idHasher(stronger, 1).Hash("password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		hashed string
+		want   bool
+	}{
+		{name: "other parameters", hashed: old, want: true},
+		{name: "bcrypt hash", hashed: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy", want: true},
+		{name: "garbage", hashed: "garbage", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.NeedsRehash(tt.hashed); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// hashes made with other parameters are still checked with their own ones
+	if !h.Check("password", old) {
+		t.Error("Check() of a hash with other parameters = false")
+	}
+}
+
+func TestDecodeArgon2id(t *testing.T) {
+	const (
+		salt = "c29tZXNhbHRzb21lc2FsdA"
+		key  = "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	)
+	longSalt := strings.Repeat("A", 100)
+	longKey := strings.Repeat("A", 100)
+
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr bool
+	}{
+		{name: "valid", encoded: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$" + key},
+		{name: "empty", encoded: "", wantErr: true},
+		{name: "argon2i", encoded: "$argon2i$v=19$m=64,t=1,p=1$" + salt + "$" + key, wantErr: true},
+		{name: "missing key", encoded: "$argon2id$v=19$m=64,t=1,p=1$" + salt, wantErr: true},
+		{name: "extra part", encoded: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$" + key + "$", wantErr: true},
+		{name: "old version", encoded: "$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key, wantErr: true},
+		{name: "bad version", encoded: "$argon2id$version$m=64,t=1,p=1$" + salt + "$" + key, wantErr: true},
+		{name: "bad parameters", encoded: "$argon2id$v=19$m=64;t=1;p=1$" + salt + "$" + key, wantErr: true},
+		{name: "zero iterations", encoded: "$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + key, wantErr: true},
+		{name: "zero parallelism", encoded: "$argon2id$v=19$m=64,t=1,p=0$" + salt + "$" + key, wantErr: true},
+		{name: "negative memory", encoded: "$argon2id$v=19$m=-1,t=1,p=1$" + salt + "$" + key, wantErr: true},
+		{name: "memory above the limit", encoded: "$argon2id$v=19$m=262145,t=1,p=1$" + salt + "$" + key, wantErr: true},
+		{name: "huge memory", encoded: "$argon2id$v=19$m=4294967295,t=1,p=1$" + salt + "$" + key, wantErr: true},
+		{name: "iterations above the limit", encoded: "$argon2id$v=19$m=64,t=17,p=1$" + salt + "$" + key, wantErr: true},
+		{name: "parallelism above the limit", encoded: "$argon2id$v=19$m=64,t=1,p=17$" + salt + "$" + key, wantErr: true},
+		{name: "parallelism overflow", encoded: "$argon2id$v=19$m=64,t=1,p=256$" + salt + "$" + key, wantErr: true},
+		{name: "salt above the limit", encoded: "$argon2id$v=19$m=64,t=1,p=1$" + longSalt + "$" + key, wantErr: true},
+		{name: "key above the limit", encoded: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$" + longKey, wantErr: true},
+		{name: "salt not base64", encoded: "$argon2id$v=19$m=64,t=1,p=1$!!!!$" + key, wantErr: true},
+		{name: "key not base64", encoded: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$!!!!", wantErr: true},
+		{name: "empty key", encoded: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := decodeArgon2id(tt.encoded)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeArgon2id() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// Check must refuse whatever can't be decoded instead of computing it
+			if tt.wantErr && NewArgon2idHasher(testArgon2Params, 1).Check("password", tt.encoded) {
+				t.Error("Check() = true")
+			}
+		})
+	}
+}
+
+func TestNewArgon2idHasherCapsParams(t *testing.T) {
+	h := NewArgon2idHasher(Argon2Params{
+		Memory:      MaxArgon2Params.Memory * 2,
+		Iterations:  MaxArgon2Params.Iterations * 2,
+		Parallelism: MaxArgon2Params.Parallelism * 2,
+		SaltLength:  MaxArgon2Params.SaltLength * 2,
+		KeyLength:   MaxArgon2Params.KeyLength * 2,
+	}, 0)
+
+	if h.params != MaxArgon2Params {
+		t.Errorf("params = %+v, want %+v", h.params, MaxArgon2Params)
+	}
+	if cap(h.slots) == 0 {
+		t.Error("zero concurrency left no slots")
+	}
+}
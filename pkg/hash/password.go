@@ -1,7 +1,9 @@
-// Package hash provides password hashing and verification using bcrypt
+// Package hash provides password hashing and verification using bcrypt or Argon2id
 package hash
 
 import (
+	"strings"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -9,8 +11,13 @@ import (
 type PasswordHasher interface {
 	Hash(password string) (string, error)
 	Check(password, hashedPassword string) bool
+	// NeedsRehash reports whether the hash was made with another algorithm or parameters than Hash uses now
+	NeedsRehash(hashedPassword string) bool
 }
 
+// BcryptMaxPasswordBytes is the longest password bcrypt hashes, Hash fails for longer ones
+const BcryptMaxPasswordBytes = 72
+
 // BcryptHasher implements PasswordHasher using bcrypt algorithm
 type BcryptHasher struct {
 	cost int
@@ -38,3 +45,22 @@ func (h *BcryptHasher) Check(password, hashedPassword string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	return err == nil
 }
+
+// NeedsRehash reports whether the hash isn't a bcrypt hash of the configured cost
+func (h *BcryptHasher) NeedsRehash(hashedPassword string) bool {
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	if err != nil {
+		return true
+	}
+	if h.cost == 0 {
+		return cost != bcrypt.DefaultCost
+	}
+	return cost != h.cost
+}
+
+// Identify reports whether the hash is a bcrypt hash
+func (h *BcryptHasher) Identify(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, "$2a$") ||
+		strings.HasPrefix(hashedPassword, "$2b$") ||
+		strings.HasPrefix(hashedPassword, "$2y$")
+}
@@ -0,0 +1,51 @@
+package hash
+
+// Scheme is a PasswordHasher that recognizes hashes encoded by its algorithm
+type Scheme interface {
+	PasswordHasher
+	Identify(hashedPassword string) bool
+}
+
+// Registry hashes new passwords with the current scheme and checks hashes of any registered scheme,
+// so stored hashes keep working after the algorithm changes and can be upgraded on the next sign-in
+type Registry struct {
+	current Scheme
+	schemes []Scheme
+}
+
+// NewRegistry creates a Registry that hashes with current and also checks hashes of others
+func NewRegistry(current Scheme, others ...Scheme) *Registry {
+	return &Registry{current: current, schemes: append([]Scheme{current}, others...)}
+}
+
+// Hash hashes the password with the current scheme
+func (r *Registry) Hash(password string) (string, error) {
+	return r.current.Hash(password)
+}
+
+// Check compares a plaintext password with a hash of any registered scheme
+func (r *Registry) Check(password, hashedPassword string) bool {
+	scheme := r.identify(hashedPassword)
+	if scheme == nil {
+		return false
+	}
+	return scheme.Check(password, hashedPassword)
+}
+
+// NeedsRehash reports whether the hash wasn't made by the current scheme with its current parameters
+func (r *Registry) NeedsRehash(hashedPassword string) bool {
+	if !r.current.Identify(hashedPassword) {
+		return true
+	}
+	return r.current.NeedsRehash(hashedPassword)
+}
+
+// identify returns the scheme that encoded the hash, nil if no registered scheme did
+func (r *Registry) identify(hashedPassword string) Scheme {
+	for _, scheme := range r.schemes {
+		if scheme.Identify(hashedPassword) {
+			return scheme
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileOutbox implements Mailer by writing every email as an .eml file into a directory instead of sending it.
+// It is meant for development and tests, where emails are read from the directory
+type FileOutbox struct {
+	dir  string
+	from string
+}
+
+// NewFileOutbox creates a new FileOutbox writing into dir
+func NewFileOutbox(dir, from string) (*FileOutbox, error) {
+	if dir == "" {
+		return nil, errors.New("empty outbox directory")
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create outbox directory: %w", err)
+	}
+	return &FileOutbox{dir: dir, from: from}, nil
+}
+
+// Send writes the email into the outbox atomically, file names sort by the time emails were sent
+func (o *FileOutbox) Send(_ context.Context, msg Message) error {
+	now := time.Now().UTC()
+
+	data, err := render(o.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("generate file name: %w", err)
+	}
+	name := now.Format("20060102T150405.000000000") + "-" + hex.EncodeToString(suffix) + ".eml"
+
+	tmp, err := os.CreateTemp(o.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write email: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(o.dir, name)); err != nil {
+		return fmt.Errorf("move email into outbox: %w", err)
+	}
+
+	return nil
+}
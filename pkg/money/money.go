@@ -0,0 +1,188 @@
+// Package money provides exact monetary amounts stored as integer minor units
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Errors returned while parsing monetary amounts
+var (
+	ErrInvalidAmount   = errors.New("invalid monetary amount")
+	ErrUnknownCurrency = errors.New("unknown currency")
+	ErrTooManyDecimals = errors.New("too many decimal places for currency")
+	ErrOverflow        = errors.New("monetary amount is out of range")
+)
+
+// Currency is an ISO 4217 currency code
+type Currency string
+
+// DefaultCurrency is used for amounts stored before currencies were introduced
+const DefaultCurrency Currency = "USD"
+
+// exponents holds the number of minor unit digits of supported currencies
+var exponents = map[Currency]int{
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"CHF": 2,
+	"CNY": 2,
+	"RUB": 2,
+	"UAH": 2,
+	"KZT": 2,
+	"BYN": 2,
+	"PLN": 2,
+	"TRY": 2,
+	"INR": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+}
+
+// Exponent returns the number of minor unit digits of the currency
+func (c Currency) Exponent() (int, error) {
+	exp, ok := exponents[c]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, string(c))
+	}
+	return exp, nil
+}
+
+// ParseCurrency validates a currency code, lowercase codes are accepted
+func ParseCurrency(s string) (Currency, error) {
+	c := Currency(strings.ToUpper(strings.TrimSpace(s)))
+	if _, err := c.Exponent(); err != nil {
+		return "", err
+	}
+	return c, nil
+}
+
+// Money is an amount in minor units (e.g. cents) of a currency
+type Money struct {
+	Amount   int64    `json:"amount" swaggertype:"string" example:"1234.50"`
+	Currency Currency `json:"currency" swaggertype:"string" example:"USD"`
+}
+
+// New creates Money from minor units
+func New(amount int64, currency Currency) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Parse strictly parses a decimal string such as "1234.50" into Money.
+// Signs, exponents and more fractional digits than the currency has are rejected
+func Parse(s string, currency Currency) (Money, error) {
+	exp, err := currency.Exponent()
+	if err != nil {
+		return Money{}, err
+	}
+
+	intPart, fracPart, hasDot := strings.Cut(s, ".")
+	if intPart == "" || !isDigits(intPart) || (hasDot && (fracPart == "" || !isDigits(fracPart))) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	if len(fracPart) > exp {
+		fracPart = strings.TrimRight(fracPart, "0")
+		if len(fracPart) > exp {
+			return Money{}, fmt.Errorf("%w: %s allows %d", ErrTooManyDecimals, currency, exp)
+		}
+	}
+
+	digits := strings.TrimLeft(intPart+fracPart+strings.Repeat("0", exp-len(fracPart)), "0")
+	if digits == "" {
+		return Money{Currency: currency}, nil
+	}
+
+	amount, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrOverflow, s)
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// String returns the amount as a decimal string without the currency, e.g. "1234.50"
+func (m Money) String() string {
+	exp := exponents[m.Currency]
+
+	sign := ""
+	amount := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign = "-"
+		amount = uint64(-(m.Amount + 1)) + 1
+	}
+
+	digits := strconv.FormatUint(amount, 10)
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// IsNegative reports whether the amount is below zero
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// moneyJSON is the wire format of Money, the amount is a decimal string to keep it exact
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// MarshalJSON encodes Money as {"amount":"1234.50","currency":"USD"}
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{
+		Amount:   m.String(),
+		Currency: string(m.Currency),
+	})
+}
+
+// UnmarshalJSON decodes Money from an object with a currency and an amount
+// given either as a decimal string or as a plain JSON number
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw moneyJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidAmount, err)
+	}
+
+	currency, err := ParseCurrency(raw.Currency)
+	if err != nil {
+		return err
+	}
+
+	amount := bytes.TrimSpace(raw.Amount)
+	if len(amount) > 0 && amount[0] == '"' {
+		var s string
+		if err := json.Unmarshal(amount, &s); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidAmount, err)
+		}
+		amount = []byte(s)
+	}
+
+	parsed, err := Parse(string(amount), currency)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+// isDigits reports whether s consists of ASCII digits only
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
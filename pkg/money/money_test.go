@@ -0,0 +1,227 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		currency Currency
+		want     int64
+		wantErr  error
+	}{
+		{name: "whole units", input: "1234", currency: "USD", want: 123400},
+		{name: "cents", input: "1234.50", currency: "USD", want: 123450},
+		{name: "one fractional digit", input: "1.5", currency: "USD", want: 150},
+		{name: "smallest unit", input: "0.01", currency: "USD", want: 1},
+		{name: "zero", input: "0", currency: "USD", want: 0},
+		{name: "zero with decimals", input: "0.00", currency: "USD", want: 0},
+		{name: "leading zeros", input: "007.10", currency: "USD", want: 710},
+		{name: "trailing zeros beyond exponent", input: "1.500", currency: "USD", want: 150},
+		{name: "largest amount", input: "92233720368547758.07", currency: "USD", want: math.MaxInt64},
+
+		{name: "zero exponent", input: "500", currency: "JPY", want: 500},
+		{name: "zero exponent with zero decimals", input: "500.00", currency: "JPY", want: 500},
+		{name: "zero exponent with decimals", input: "500.5", currency: "JPY", wantErr: ErrTooManyDecimals},
+		{name: "three digit exponent", input: "1.234", currency: "KWD", want: 1234},
+		{name: "three digit exponent with trailing zero", input: "1.2340", currency: "KWD", want: 1234},
+		{name: "three digit exponent with too many decimals", input: "1.2345", currency: "KWD", wantErr: ErrTooManyDecimals},
+		{name: "too many decimals", input: "1.234", currency: "USD", wantErr: ErrTooManyDecimals},
+
+		{name: "empty", input: "", currency: "USD", wantErr: ErrInvalidAmount},
+		{name: "minus sign", input: "-1", currency: "USD", wantErr: ErrInvalidAmount},
+		{name: "plus sign", input: "+1", currency: "USD", wantErr: ErrInvalidAmount},
+		{name: "exponent", input: "1e3", currency: "USD", wantErr: ErrInvalidAmount},
+		{name: "trailing dot", input: "1.", currency: "USD", wantErr: ErrInvalidAmount},
+		{name: "leading dot", input: ".5", currency: "USD", wantErr: ErrInvalidAmount},
+		{name: "comma", input: "1,5", currency: "USD", wantErr: ErrInvalidAmount},
+		{name: "two dots", input: "1.2.3", currency: "USD", wantErr: ErrInvalidAmount},
+		{name: "spaces", input: " 1", currency: "USD", wantErr: ErrInvalidAmount},
+		{name: "hex", input: "0x10", currency: "USD", wantErr: ErrInvalidAmount},
+		{name: "letters", input: "abc", currency: "USD", wantErr: ErrInvalidAmount},
+		{name: "non-ASCII digits", input: "١٢٣", currency: "USD", wantErr: ErrInvalidAmount},
+
+		{name: "overflow by one", input: "92233720368547758.08", currency: "USD", wantErr: ErrOverflow},
+		{name: "overflow of zero exponent", input: "9223372036854775808", currency: "JPY", wantErr: ErrOverflow},
+		{name: "huge amount", input: "100000000000000000000000000000", currency: "USD", wantErr: ErrOverflow},
+
+		{name: "unknown currency", input: "1", currency: "XXX", wantErr: ErrUnknownCurrency},
+		{name: "lowercase currency", input: "1", currency: "usd", wantErr: ErrUnknownCurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input, tt.currency)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Parse(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			if got != New(tt.want, tt.currency) {
+				t.Errorf("Parse(%q) = %+v, want %d %s", tt.input, got, tt.want, tt.currency)
+			}
+		})
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		name  string
+		money Money
+		want  string
+	}{
+		{name: "cents", money: New(123450, "USD"), want: "1234.50"},
+		{name: "below one unit", money: New(5, "USD"), want: "0.05"},
+		{name: "zero", money: New(0, "USD"), want: "0.00"},
+		{name: "negative", money: New(-5, "USD"), want: "-0.05"},
+		{name: "negative units", money: New(-12345, "USD"), want: "-123.45"},
+		{name: "zero exponent", money: New(500, "JPY"), want: "500"},
+		{name: "zero exponent zero", money: New(0, "JPY"), want: "0"},
+		{name: "three digit exponent", money: New(1234, "KWD"), want: "1.234"},
+		{name: "three digit exponent below one unit", money: New(7, "KWD"), want: "0.007"},
+		{name: "max int64", money: New(math.MaxInt64, "USD"), want: "92233720368547758.07"},
+		{name: "min int64", money: New(math.MinInt64, "USD"), want: "-92233720368547758.08"},
+		{name: "min int64 zero exponent", money: New(math.MinInt64, "JPY"), want: "-9223372036854775808"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.money.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCurrency(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Currency
+		wantErr bool
+	}{
+		{input: "USD", want: "USD"},
+		{input: "eur", want: "EUR"},
+		{input: " jpy ", want: "JPY"},
+		{input: "XXX", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseCurrency(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCurrency(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCurrency(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		money Money
+		want  string
+	}{
+		{name: "cents", money: New(123450, "USD"), want: `{"amount":"1234.50","currency":"USD"}`},
+		{name: "zero exponent", money: New(500, "JPY"), want: `{"amount":"500","currency":"JPY"}`},
+		{name: "max int64", money: New(math.MaxInt64, "USD"), want: `{"amount":"92233720368547758.07","currency":"USD"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.money)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Money
+		wantErr error
+	}{
+		{name: "string amount", input: `{"amount":"1234.50","currency":"USD"}`, want: New(123450, "USD")},
+		{name: "number amount", input: `{"amount":1234.5,"currency":"USD"}`, want: New(123450, "USD")},
+		{name: "integer number amount", input: `{"amount":500,"currency":"JPY"}`, want: New(500, "JPY")},
+		{name: "large number stays exact", input: `{"amount":92233720368547758.07,"currency":"USD"}`, want: New(math.MaxInt64, "USD")},
+		{name: "lowercase currency", input: `{"amount":"1.234","currency":"kwd"}`, want: New(1234, "KWD")},
+		{name: "number with exponent", input: `{"amount":1e3,"currency":"USD"}`, wantErr: ErrInvalidAmount},
+		{name: "negative number", input: `{"amount":-1,"currency":"USD"}`, wantErr: ErrInvalidAmount},
+		{name: "negative string", input: `{"amount":"-1","currency":"USD"}`, wantErr: ErrInvalidAmount},
+		{name: "too many decimals", input: `{"amount":"1.5","currency":"JPY"}`, wantErr: ErrTooManyDecimals},
+		{name: "overflow", input: `{"amount":"92233720368547758.08","currency":"USD"}`, wantErr: ErrOverflow},
+		{name: "missing amount", input: `{"currency":"USD"}`, wantErr: ErrInvalidAmount},
+		{name: "null amount", input: `{"amount":null,"currency":"USD"}`, wantErr: ErrInvalidAmount},
+		{name: "boolean amount", input: `{"amount":true,"currency":"USD"}`, wantErr: ErrInvalidAmount},
+		{name: "missing currency", input: `{"amount":"1"}`, wantErr: ErrUnknownCurrency},
+		{name: "unknown currency", input: `{"amount":"1","currency":"XXX"}`, wantErr: ErrUnknownCurrency},
+		{name: "not an object", input: `"1234.50"`, wantErr: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Money
+			err := json.Unmarshal([]byte(tt.input), &got)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Unmarshal(%s) error = %v, want %v", tt.input, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal(%s) error = %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("Unmarshal(%s) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	tests := []Money{
+		New(0, "USD"),
+		New(1, "USD"),
+		New(123450, "EUR"),
+		New(500, "JPY"),
+		New(1, "KWD"),
+		New(math.MaxInt64, "USD"),
+		New(math.MaxInt64, "JPY"),
+	}
+
+	for _, want := range tests {
+		t.Run(want.String()+" "+string(want.Currency), func(t *testing.T) {
+			data, err := json.Marshal(want)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			var got Money
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal(%s) error = %v", data, err)
+			}
+			if got != want {
+				t.Errorf("round trip of %+v = %+v", want, got)
+			}
+		})
+	}
+}
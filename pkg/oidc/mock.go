@@ -0,0 +1,254 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"rest-api-marketplace/pkg/auth"
+)
+
+const (
+	// MockDefaultEmail is the account MockProvider signs in when the authorization request has no login_hint
+	MockDefaultEmail = "mock.user@example.com"
+	// mockCodeTTL is how long MockProvider authorization codes can be redeemed
+	mockCodeTTL = time.Minute
+	// mockTokenTTL is the lifetime of MockProvider ID tokens
+	mockTokenTTL = 5 * time.Minute
+)
+
+// MockProvider is a minimal OpenID Connect provider for local development and tests, served in-process
+// under the path of its issuer URL. It signs anyone in without asking: the account is the email
+// in the login_hint parameter of the authorization request, MockDefaultEmail without it.
+// Only the client it was created for is accepted and PKCE is required
+type MockProvider struct {
+	cfg      Config
+	basePath string
+	key      *auth.Key
+	now      func() time.Time
+
+	mu    sync.Mutex
+	codes map[string]mockGrant
+}
+
+// mockGrant is an issued authorization code waiting to be redeemed
+type mockGrant struct {
+	email         string
+	nonce         string
+	codeChallenge string
+	expiresAt     time.Time
+}
+
+// NewMockProvider creates a MockProvider for the client registration, it signs ID tokens with a fresh RSA key
+func NewMockProvider(cfg Config) (*MockProvider, error) {
+	u, err := url.Parse(cfg.Issuer)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid issuer %q", cfg.Issuer)
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("generate signing key: %w", err)
+	}
+
+	return &MockProvider{
+		cfg:      cfg,
+		basePath: strings.TrimSuffix(u.Path, "/"),
+		key: &auth.Key{
+			ID:         "mock",
+			Method:     jwt.SigningMethodRS256,
+			PrivateKey: privateKey,
+			PublicKey:  &privateKey.PublicKey,
+		},
+		now:   time.Now,
+		codes: make(map[string]mockGrant),
+	}, nil
+}
+
+// ServeHTTP serves the discovery document, the authorization, token and key set endpoints
+func (m *MockProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimPrefix(r.URL.Path, m.basePath) {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, map[string]any{
+			"issuer":                                m.cfg.Issuer,
+			"authorization_endpoint":                m.cfg.Issuer + "/authorize",
+			"token_endpoint":                        m.cfg.Issuer + "/token",
+			"jwks_uri":                              m.cfg.Issuer + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{m.key.Method.Alg()},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	case "/authorize":
+		m.authorize(w, r)
+	case "/token":
+		m.token(w, r)
+	case "/jwks":
+		writeJSON(w, http.StatusOK, auth.JWKSet{Keys: []auth.JWK{m.key.JWK()}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authorize signs the user in right away and redirects back to the client with an authorization code
+func (m *MockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != m.cfg.ClientID || q.Get("redirect_uri") != m.cfg.RedirectURL {
+		http.Error(w, "unknown client or redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	redirect, _ := url.Parse(m.cfg.RedirectURL)
+	params := redirect.Query()
+	params.Set("state", q.Get("state"))
+
+	switch {
+	case q.Get("response_type") != "code":
+		params.Set("error", "unsupported_response_type")
+	case q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256":
+		params.Set("error", "invalid_request")
+		params.Set("error_description", "S256 code challenge is required")
+	default:
+		email := strings.ToLower(strings.TrimSpace(q.Get("login_hint")))
+		if email == "" {
+			email = MockDefaultEmail
+		}
+
+		code, err := auth.NewRandomToken()
+		if err != nil {
+			http.Error(w, "failed to issue code", http.StatusInternalServerError)
+			return
+		}
+
+		m.mu.Lock()
+		m.prune()
+		m.codes[code] = mockGrant{
+			email:         email,
+			nonce:         q.Get("nonce"),
+			codeChallenge: q.Get("code_challenge"),
+			expiresAt:     m.now().Add(mockCodeTTL),
+		}
+		m.mu.Unlock()
+
+		params.Set("code", code)
+	}
+
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token redeems an authorization code once, checking the client credentials and the PKCE code verifier
+func (m *MockProvider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, Error{Code: "invalid_request"})
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != m.cfg.ClientID || clientSecret != m.cfg.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, Error{Code: "invalid_client"})
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, Error{Code: "unsupported_grant_type"})
+		return
+	}
+
+	m.mu.Lock()
+	grant, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	now := m.now()
+	switch {
+	case !ok || now.After(grant.expiresAt):
+		writeJSON(w, http.StatusBadRequest, Error{Code: "invalid_grant", Description: "unknown, used or expired code"})
+		return
+	case r.PostForm.Get("redirect_uri") != m.cfg.RedirectURL:
+		writeJSON(w, http.StatusBadRequest, Error{Code: "invalid_grant", Description: "redirect_uri mismatch"})
+		return
+	case CodeChallenge(r.PostForm.Get("code_verifier")) != grant.codeChallenge:
+		writeJSON(w, http.StatusBadRequest, Error{Code: "invalid_grant", Description: "code_verifier mismatch"})
+		return
+	}
+
+	name, _, _ := strings.Cut(grant.email, "@")
+	claims := jwt.MapClaims{
+		"iss":                m.cfg.Issuer,
+		"sub":                mockSubject(grant.email),
+		"aud":                m.cfg.ClientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(mockTokenTTL).Unix(),
+		"nonce":              grant.nonce,
+		"email":              grant.email,
+		"email_verified":     true,
+		"name":               name,
+		"preferred_username": name,
+	}
+
+	token := jwt.NewWithClaims(m.key.Method, claims)
+	token.Header["kid"] = m.key.ID
+	idToken, err := token.SignedString(m.key.PrivateKey)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, Error{Code: "server_error"})
+		return
+	}
+
+	accessToken, err := auth.NewRandomToken()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, Error{Code: "server_error"})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(mockTokenTTL.Seconds()),
+		"id_token":     idToken,
+	})
+}
+
+// prune drops expired codes, the caller must hold the lock
+func (m *MockProvider) prune() {
+	now := m.now()
+	for code, grant := range m.codes {
+		if now.After(grant.expiresAt) {
+			delete(m.codes, code)
+		}
+	}
+}
+
+// mockSubject derives a stable subject from the email, so the same email is always the same account
+func mockSubject(email string) string {
+	sum := sha256.Sum256([]byte(email))
+	return hex.EncodeToString(sum[:12])
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
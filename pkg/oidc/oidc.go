@@ -0,0 +1,491 @@
+// Package oidc implements the OpenID Connect authorization code flow with PKCE
+// for signing users in with external identity providers
+package oidc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"rest-api-marketplace/pkg/auth"
+)
+
+const (
+	// clockSkew is the allowed difference between provider and service clocks when checking ID tokens
+	clockSkew = time.Minute
+	// keysRefreshInterval limits how often signing keys are refetched for an unknown kid
+	keysRefreshInterval = time.Minute
+	// maxResponseSize limits provider responses read into memory
+	maxResponseSize = 1 << 20
+)
+
+// ErrInvalidIDToken means the ID token of the provider failed verification
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// Error is an error response of the provider token endpoint, e.g. for an expired or reused code
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *Error) Error() string {
+	if e.Description != "" {
+		return "oidc: " + e.Code + ": " + e.Description
+	}
+	return "oidc: " + e.Code
+}
+
+// Config holds the registration of the service at a provider
+type Config struct {
+	// Issuer is the provider URL, metadata is discovered at Issuer/.well-known/openid-configuration
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback URL of the service registered at the provider
+	RedirectURL string
+	// Scopes are requested besides openid
+	Scopes []string
+}
+
+// Identity is an account at the provider taken from verified ID token claims
+type Identity struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// metadata is the part of the provider discovery document the flow needs
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Client signs users in with one provider. Provider metadata is discovered on first use,
+// signing keys are refetched when an ID token is signed with an unknown key
+type Client struct {
+	cfg  Config
+	http *http.Client
+
+	mu          sync.Mutex
+	meta        *metadata
+	keys        map[string]*auth.Key
+	keysFetched time.Time
+	// fetching is the key set download in progress, nil if there is none
+	fetching *keysFetch
+}
+
+// keysFetch is a key set download other callbacks wait for instead of starting their own
+type keysFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// NewClient creates a Client for the provider, a nil httpClient is replaced with one with a timeout
+func NewClient(cfg Config, httpClient *http.Client) (*Client, error) {
+	if cfg.Issuer == "" {
+		return nil, errors.New("empty issuer")
+	}
+	if cfg.ClientID == "" {
+		return nil, errors.New("empty client id")
+	}
+	if cfg.RedirectURL == "" {
+		return nil, errors.New("empty redirect url")
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Client{cfg: cfg, http: httpClient}, nil
+}
+
+// NewCodeVerifier generates a random PKCE code verifier
+func NewCodeVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge returns the S256 PKCE code challenge of the verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the provider URL the user is sent to for signing in.
+// The provider echoes state back to the redirect URL and puts nonce into the ID token
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	meta, err := c.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("parse authorization endpoint: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", c.cfg.ClientID)
+	q.Set("redirect_uri", c.cfg.RedirectURL)
+	q.Set("scope", strings.Join(append([]string{"openid"}, c.cfg.Scopes...), " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// Exchange redeems the authorization code for an ID token and returns the identity from its verified claims.
+// It fails with an *Error if the provider rejects the code and with ErrInvalidIDToken if the token is not valid
+func (c *Client) Exchange(ctx context.Context, code, codeVerifier, nonce string) (Identity, error) {
+	meta, err := c.metadata(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.cfg.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, fmt.Errorf("create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// client_secret_basic, the credentials are form-encoded first as RFC 6749 requires
+	req.SetBasicAuth(url.QueryEscape(c.cfg.ClientID), url.QueryEscape(c.cfg.ClientSecret))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("token request: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return Identity{}, fmt.Errorf("read token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr Error
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Code != "" {
+			return Identity{}, &oauthErr
+		}
+		return Identity{}, fmt.Errorf("token request: unexpected status %d", resp.StatusCode)
+	}
+
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return Identity{}, fmt.Errorf("decode token response: %w", err)
+	}
+	if tokenResp.IDToken == "" {
+		return Identity{}, fmt.Errorf("%w: no id_token in token response", ErrInvalidIDToken)
+	}
+
+	return c.verify(ctx, meta, tokenResp.IDToken, nonce)
+}
+
+// idTokenClaims are the ID token claims the flow reads
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string       `json:"nonce"`
+	AuthorizedParty   string       `json:"azp"`
+	Email             string       `json:"email"`
+	EmailVerified     flexibleBool `json:"email_verified"`
+	Name              string       `json:"name"`
+	PreferredUsername string       `json:"preferred_username"`
+}
+
+// flexibleBool accepts both true and "true", some providers send booleans as strings
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", `"true"`:
+		*b = true
+	case "false", `"false"`, "null":
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean %s", data)
+	}
+	return nil
+}
+
+// verify checks the ID token signature, issuer, audience, lifetime and nonce
+func (c *Client) verify(ctx context.Context, meta *metadata, rawToken, nonce string) (Identity, error) {
+	var claims idTokenClaims
+	_, err := jwt.ParseWithClaims(rawToken, &claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return c.verificationKey(ctx, meta, token)
+		},
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(c.cfg.ClientID),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if claims.Subject == "" {
+		return Identity{}, fmt.Errorf("%w: missing sub", ErrInvalidIDToken)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return Identity{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	if claims.AuthorizedParty != "" && claims.AuthorizedParty != c.cfg.ClientID {
+		return Identity{}, fmt.Errorf("%w: token was issued to another client", ErrInvalidIDToken)
+	}
+
+	return Identity{
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     bool(claims.EmailVerified),
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
+
+// verificationKey selects the provider key the token must be verified with and checks that the token uses its algorithm
+func (c *Client) verificationKey(ctx context.Context, meta *metadata, token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, err := c.key(ctx, meta, kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("invalid signing method: %v", token.Header["alg"])
+	}
+	return key.PublicKey, nil
+}
+
+// key returns the provider signing key by kid, refetching the key set once it may have been rotated.
+// The key set is downloaded without holding the lock, so a slow provider only delays the callbacks
+// that need its new keys, and concurrent callbacks share a single download.
+// A token without kid is accepted only when the provider has a single key
+func (c *Client) key(ctx context.Context, meta *metadata, kid string) (*auth.Key, error) {
+	for {
+		c.mu.Lock()
+		if key, ok := c.lookupKey(kid); ok {
+			c.mu.Unlock()
+			return key, nil
+		}
+
+		if fetch := c.fetching; fetch != nil {
+			c.mu.Unlock()
+			select {
+			case <-fetch.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if fetch.err != nil {
+				return nil, fetch.err
+			}
+			continue
+		}
+
+		if time.Since(c.keysFetched) < keysRefreshInterval {
+			c.mu.Unlock()
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+
+		fetch := &keysFetch{done: make(chan struct{})}
+		c.fetching = fetch
+		c.mu.Unlock()
+
+		keys, err := c.fetchKeys(ctx, meta.JWKSURI)
+
+		c.mu.Lock()
+		if err == nil {
+			c.keys, c.keysFetched = keys, time.Now()
+		}
+		c.fetching = nil
+		c.mu.Unlock()
+
+		fetch.err = err
+		close(fetch.done)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// lookupKey finds a fetched key, the caller must hold the lock
+func (c *Client) lookupKey(kid string) (*auth.Key, bool) {
+	if kid == "" {
+		if len(c.keys) != 1 {
+			return nil, false
+		}
+		for _, key := range c.keys {
+			return key, true
+		}
+	}
+	key, ok := c.keys[kid]
+	return key, ok
+}
+
+// fetchKeys downloads the provider key set, keys of unsupported types and encryption keys are skipped
+func (c *Client) fetchKeys(ctx context.Context, jwksURI string) (map[string]*auth.Key, error) {
+	var set auth.JWKSet
+	if err := c.getJSON(ctx, jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("fetch signing keys: %w", err)
+	}
+
+	keys := make(map[string]*auth.Key, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(jwk)
+		if err != nil {
+			continue
+		}
+		keys[key.ID] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("fetch signing keys: no supported keys")
+	}
+	return keys, nil
+}
+
+// parseJWK converts an RSA or Ed25519 public key in JSON Web Key format
+func parseJWK(jwk auth.JWK) (*auth.Key, error) {
+	key := &auth.Key{ID: jwk.KeyID}
+
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("decode n: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid e")
+		}
+
+		key.PublicKey = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		key.Method = jwt.SigningMethodRS256
+		if jwk.Algorithm != "" {
+			method, ok := jwt.GetSigningMethod(jwk.Algorithm).(*jwt.SigningMethodRSA)
+			if !ok {
+				return nil, fmt.Errorf("unsupported algorithm %q", jwk.Algorithm)
+			}
+			key.Method = method
+		}
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid x")
+		}
+		key.PublicKey, key.Method = ed25519.PublicKey(x), jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.KeyType)
+	}
+
+	return key, nil
+}
+
+// metadata returns the discovered provider metadata, discovering it on first use.
+// Discovery runs without the lock, callbacks racing on first use may each discover once
+func (c *Client) metadata(ctx context.Context) (*metadata, error) {
+	c.mu.Lock()
+	meta := c.meta
+	c.mu.Unlock()
+	if meta != nil {
+		return meta, nil
+	}
+
+	meta, err := c.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.meta == nil {
+		c.meta = meta
+	}
+	return c.meta, nil
+}
+
+// discover downloads the provider metadata and checks it belongs to the configured issuer
+func (c *Client) discover(ctx context.Context) (*metadata, error) {
+
+	var meta metadata
+	discoveryURL := strings.TrimSuffix(c.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := c.getJSON(ctx, discoveryURL, &meta); err != nil {
+		return nil, fmt.Errorf("discover provider: %w", err)
+	}
+
+	if meta.Issuer != c.cfg.Issuer {
+		return nil, fmt.Errorf("discover provider: issuer %q doesn't match %q", meta.Issuer, c.cfg.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("discover provider: incomplete metadata")
+	}
+
+	return &meta, nil
+}
+
+// getJSON fetches a JSON document
+func (c *Client) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
@@ -0,0 +1,22 @@
+// Package storage provides pluggable backends for storing uploaded files
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Errors returned by storage backends
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Storage defines methods for saving, reading and removing objects by key
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}